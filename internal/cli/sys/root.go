@@ -1,6 +1,7 @@
 package sys
 
 import (
+	"github.com/rumorsflow/rumors/v2/internal/cli/sys/site"
 	"github.com/rumorsflow/rumors/v2/internal/cli/sys/user"
 	"github.com/spf13/cobra"
 )
@@ -8,6 +9,7 @@ import (
 func NewRootCommand() *cobra.Command {
 	cmd := &cobra.Command{Use: "sys"}
 
+	cmd.AddCommand(site.NewRootCommand())
 	cmd.AddCommand(user.NewRootCommand())
 
 	return cmd
@@ -0,0 +1,138 @@
+package site
+
+import (
+	"context"
+	"github.com/goccy/go-json"
+	"github.com/roadrunner-server/errors"
+	"github.com/rumorsflow/rumors/v2/internal/common"
+	"github.com/rumorsflow/rumors/v2/internal/container"
+	"github.com/rumorsflow/rumors/v2/internal/db"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/internal/task"
+	"github.com/rumorsflow/rumors/v2/pkg/config"
+	"github.com/rumorsflow/rumors/v2/pkg/repository"
+	"github.com/spf13/cobra"
+	"os"
+	"time"
+)
+
+const discoverPluginName = "discover_site"
+
+type DiscoverDTO struct {
+	Domain  string
+	Accept  bool
+	Timeout time.Duration
+}
+
+type DiscoverPlugin struct {
+	dto        DiscoverDTO
+	discoverer *task.Discoverer
+	siteRepo   repository.WriteRepository[*entity.Site]
+	jobRepo    repository.WriteRepository[*entity.Job]
+}
+
+func (p *DiscoverPlugin) Init(cfg config.Configurer, uow common.UnitOfWork) error {
+	const op = errors.Op("discover_site_plugin_init")
+
+	fetcherCfg, err := task.LoadFetcherConfig(cfg)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	fetcher, err := task.NewFetcher(fetcherCfg, nil)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	siteRepo, err := uow.Repository((*entity.Site)(nil))
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	jobRepo, err := uow.Repository((*entity.Job)(nil))
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	p.discoverer = task.NewDiscoverer(fetcher)
+	p.siteRepo = siteRepo.(repository.ReadWriteRepository[*entity.Site])
+	p.jobRepo = jobRepo.(repository.ReadWriteRepository[*entity.Job])
+
+	return nil
+}
+
+func (p *DiscoverPlugin) Serve() chan error {
+	errCh := make(chan error, 1)
+
+	go p.exec(errCh)
+
+	return errCh
+}
+
+func (p *DiscoverPlugin) Stop(context.Context) error {
+	return nil
+}
+
+func (p *DiscoverPlugin) Name() string {
+	return discoverPluginName
+}
+
+func (p *DiscoverPlugin) exec(ch chan<- error) {
+	const op = errors.Op("discover_site_command")
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.dto.Timeout)
+	defer cancel()
+
+	discovery, err := p.discoverer.Discover(ctx, p.dto.Domain)
+	if err != nil {
+		ch <- errors.E(op, err)
+		return
+	}
+
+	if p.dto.Accept {
+		if err = p.siteRepo.Save(ctx, discovery.Site); err != nil {
+			ch <- errors.E(op, err)
+			return
+		}
+
+		for _, job := range discovery.Jobs {
+			if err = p.jobRepo.Save(ctx, job); err != nil {
+				ch <- errors.E(op, err)
+				return
+			}
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	if err = encoder.Encode(discovery); err != nil {
+		ch <- errors.E(op, err)
+		return
+	}
+
+	ch <- common.Success
+}
+
+func NewDiscoverCommand() *cobra.Command {
+	var dto DiscoverDTO
+
+	cmd := &cobra.Command{
+		Use:   "discover [domain]",
+		Short: "Discover site feeds and sitemaps",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dto.Domain = args[0]
+
+			return cmd.Context().Value("container").(*container.Container).Run(
+				&db.Plugin{},
+				&DiscoverPlugin{dto: dto},
+			)
+		},
+	}
+
+	cmd.Flags().BoolVar(&dto.Accept, "accept", false, "Save the proposed site and jobs")
+	cmd.Flags().DurationVar(&dto.Timeout, "timeout", time.Minute, "Discovery timeout")
+
+	return cmd
+}
@@ -0,0 +1,11 @@
+package site
+
+import "github.com/spf13/cobra"
+
+func NewRootCommand() *cobra.Command {
+	cmd := &cobra.Command{Use: "site"}
+
+	cmd.AddCommand(NewDiscoverCommand())
+
+	return cmd
+}
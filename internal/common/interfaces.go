@@ -24,16 +24,19 @@ type Client interface {
 	EnqueueTgCmd(ctx context.Context, message *tgbotapi.Message, updateID int)
 	EnqueueTgMemberNew(ctx context.Context, member *tgbotapi.Chat, updateID int)
 	EnqueueTgMemberEdit(ctx context.Context, member *tgbotapi.ChatMemberUpdated, updateID int)
+	EnqueueTgPost(ctx context.Context, message *tgbotapi.Message, updateID int)
 	Enqueue(ctx context.Context, name string, data any, opts ...asynq.Option) error
 }
 
 type Pub interface {
 	Telegram(ctx context.Context, message any)
 	Articles(ctx context.Context, articles []model.Article)
+	ArticleUpdated(ctx context.Context, update model.ArticleUpdate)
 }
 
 type Sub interface {
 	All(ctx context.Context) *redis.PubSub
 	Telegram(ctx context.Context) *redis.PubSub
 	Articles(ctx context.Context) *redis.PubSub
+	ArticleUpdated(ctx context.Context) *redis.PubSub
 }
@@ -65,12 +65,16 @@ func ArticleBeforeSave(entity *entity.Article) (bson.M, error) {
 	delete(set, "site_id")
 	delete(set, "source")
 	delete(set, "link")
+	delete(set, "original_link")
 	delete(set, "pub_date")
 
 	insert := data["$setOnInsert"].(bson.M)
 	insert["site_id"] = entity.SiteID
 	insert["source"] = entity.Source
 	insert["link"] = entity.Link
+	if entity.OriginalLink != "" {
+		insert["original_link"] = entity.OriginalLink
+	}
 	insert["pub_date"] = entity.PubDate
 
 	return data, err
@@ -87,3 +91,16 @@ func ChatBeforeSave(entity *entity.Chat) (bson.M, error) {
 
 	return data, err
 }
+
+func JobBeforeSave(entity *entity.Job) (bson.M, error) {
+	data, err := BeforeSave(entity)
+	if err != nil {
+		return data, err
+	}
+
+	if entity.StateOnly() {
+		delete(data["$set"].(bson.M), "updated_at")
+	}
+
+	return data, err
+}
@@ -7,6 +7,15 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+const (
+	// JobRunTTL is how long job run history is kept.
+	JobRunTTL = 30 * 24 * time.Hour
+
+	// FilteredArticleTTL is how long the items rejected by the content rules are kept for audit.
+	FilteredArticleTTL = 30 * 24 * time.Hour
 )
 
 func SiteIndexes(indexView mongo.IndexView) error {
@@ -26,11 +35,25 @@ func SiteIndexes(indexView mongo.IndexView) error {
 func ArticleIndexes(indexView mongo.IndexView) error {
 	if _, err := indexView.CreateMany(context.Background(), []mongo.IndexModel{
 		{Keys: bson.D{{"link", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"original_link", 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{"content_hash", 1}, {"created_at", -1}}},
 		{Keys: bson.D{{"pub_date", 1}}},
 		{Keys: bson.D{{"created_at", 1}}},
 		{Keys: bson.D{{"updated_at", 1}}},
 		{Keys: bson.D{{"site_id", 1}, {"lang", 1}}},
 		{Keys: bson.D{{"source", 1}, {"lang", 1}}},
+		{Keys: bson.D{{"categories", 1}}},
+	}); err != nil {
+		return fmt.Errorf("%s %w", repository.OpIndexes, err)
+	}
+	return nil
+}
+
+func FilteredArticleIndexes(indexView mongo.IndexView) error {
+	if _, err := indexView.CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{"site_id", 1}, {"created_at", -1}}},
+		{Keys: bson.D{{"job_id", 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{"created_at", 1}}, Options: options.Index().SetExpireAfterSeconds(int32(FilteredArticleTTL.Seconds()))},
 	}); err != nil {
 		return fmt.Errorf("%s %w", repository.OpIndexes, err)
 	}
@@ -65,6 +88,17 @@ func JobIndexes(indexView mongo.IndexView) error {
 	return nil
 }
 
+func JobRunIndexes(indexView mongo.IndexView) error {
+	if _, err := indexView.CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{"job_id", 1}, {"started_at", -1}}},
+		{Keys: bson.D{{"site_id", 1}}},
+		{Keys: bson.D{{"created_at", 1}}, Options: options.Index().SetExpireAfterSeconds(int32(JobRunTTL.Seconds()))},
+	}); err != nil {
+		return fmt.Errorf("%s %w", repository.OpIndexes, err)
+	}
+	return nil
+}
+
 func SysUserIndexes(indexView mongo.IndexView) error {
 	if _, err := indexView.CreateMany(context.Background(), []mongo.IndexModel{
 		{Keys: bson.D{{"username", 1}}, Options: options.Index().SetUnique(true)},
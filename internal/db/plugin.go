@@ -58,6 +58,17 @@ func (p *Plugin) Init(cfg config.Configurer) error {
 		)
 	}))
 
+	p.resolvers.Store((*entity.FilteredArticle)(nil), newResolver[*entity.FilteredArticle](func() (repository.ReadWriteRepository[*entity.FilteredArticle], error) {
+		return NewRepository[*entity.FilteredArticle](
+			database,
+			entity.FilteredArticleCollection,
+			WithEntityFactory(repository.Factory[*entity.FilteredArticle]()),
+			WithBeforeSave(BeforeSave[*entity.FilteredArticle]),
+			WithAfterSave(AfterSave[*entity.FilteredArticle]),
+			WithIndexes[*entity.FilteredArticle](FilteredArticleIndexes),
+		)
+	}))
+
 	p.resolvers.Store((*entity.Chat)(nil), newResolver[*entity.Chat](func() (repository.ReadWriteRepository[*entity.Chat], error) {
 		return NewRepository[*entity.Chat](
 			database,
@@ -74,12 +85,23 @@ func (p *Plugin) Init(cfg config.Configurer) error {
 			database,
 			entity.JobCollection,
 			WithEntityFactory(repository.Factory[*entity.Job]()),
-			WithBeforeSave(BeforeSave[*entity.Job]),
+			WithBeforeSave(JobBeforeSave),
 			WithAfterSave(AfterSave[*entity.Job]),
 			WithIndexes[*entity.Job](JobIndexes),
 		)
 	}))
 
+	p.resolvers.Store((*entity.JobRun)(nil), newResolver[*entity.JobRun](func() (repository.ReadWriteRepository[*entity.JobRun], error) {
+		return NewRepository[*entity.JobRun](
+			database,
+			entity.JobRunCollection,
+			WithEntityFactory(repository.Factory[*entity.JobRun]()),
+			WithBeforeSave(BeforeSave[*entity.JobRun]),
+			WithAfterSave(AfterSave[*entity.JobRun]),
+			WithIndexes[*entity.JobRun](JobRunIndexes),
+		)
+	}))
+
 	p.resolvers.Store((*entity.SysUser)(nil), newResolver[*entity.SysUser](func() (repository.ReadWriteRepository[*entity.SysUser], error) {
 		return NewRepository[*entity.SysUser](
 			database,
@@ -194,6 +194,29 @@ func (r *Repository[T]) Save(ctx context.Context, entity T) (err error) {
 	return nil
 }
 
+func (r *Repository[T]) Update(ctx context.Context, entity T) (err error) {
+	id := entity.EntityID()
+	if id == uuid.Nil {
+		return fmt.Errorf("%s %w", repository.OpUpdate, repository.ErrMissingEntityID)
+	}
+
+	var update bson.M
+
+	if r.beforeSave == nil {
+		update = bson.M{"$set": entity}
+	} else if update, err = r.beforeSave(entity); err != nil {
+		return repoErr(repository.OpUpdate, fmt.Errorf("failed before save due to error: %w", err), id)
+	}
+
+	delete(update, "$setOnInsert")
+
+	if _, err = mongodb.Update(ctx, r.collection, bson.M{"_id": id.String()}, update); err != nil {
+		return repoErr(repository.OpUpdate, err, id)
+	}
+
+	return nil
+}
+
 func (r *Repository[T]) Remove(ctx context.Context, id uuid.UUID) error {
 	if err := mongodb.Remove(ctx, r.collection, bson.M{"_id": id.String()}); err != nil {
 		return repoErr(repository.OpRemove, err, id)
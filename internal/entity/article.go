@@ -17,9 +17,16 @@ const (
 	AudioType MediaType = "audio"
 )
 
+const MaxArticleRevisions = 5
+
 const (
-	FeedSource    Source = "feed"
-	SitemapSource Source = "sitemap"
+	FeedSource        Source = "feed"
+	SitemapSource     Source = "sitemap"
+	HTMLSource        Source = "html"
+	JSONSource        Source = "json"
+	TelegramSource    Source = "telegram"
+	MailSource        Source = "mail"
+	ActivityPubSource Source = "activitypub"
 
 	ArticleCollection = "articles"
 )
@@ -30,18 +37,42 @@ type Media struct {
 	Meta map[string]any `json:"meta,omitempty" bson:"meta,omitempty"`
 }
 
-type Article struct {
-	ID        uuid.UUID `json:"id,omitempty" bson:"_id,omitempty"`
-	Link      string    `json:"link,omitempty" bson:"link,omitempty"`
-	SiteID    uuid.UUID `json:"site_id,omitempty" bson:"site_id,omitempty"`
-	Source    Source    `json:"source,omitempty" bson:"source,omitempty"`
-	Lang      string    `json:"lang,omitempty" bson:"lang,omitempty"`
+// ArticleContent is the readable content extracted from the article page, the reading time is in minutes.
+type ArticleContent struct {
+	Text        string `json:"text,omitempty" bson:"text,omitempty"`
+	HTML        string `json:"html,omitempty" bson:"html,omitempty"`
+	WordCount   int    `json:"word_count,omitempty" bson:"word_count,omitempty"`
+	ReadingTime int    `json:"reading_time,omitempty" bson:"reading_time,omitempty"`
+}
+
+type ArticleRevision struct {
 	Title     string    `json:"title,omitempty" bson:"title,omitempty"`
 	Desc      *string   `json:"desc,omitempty" bson:"short_desc,omitempty"`
 	Media     *[]Media  `json:"media,omitempty" bson:"media,omitempty"`
-	PubDate   time.Time `json:"pub_date,omitempty" bson:"pub_date,omitempty"`
-	CreatedAt time.Time `json:"created_at,omitempty" bson:"created_at,omitempty"`
-	UpdatedAt time.Time `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	RevisedAt time.Time `json:"revised_at,omitempty" bson:"revised_at,omitempty"`
+}
+
+type Article struct {
+	ID              uuid.UUID          `json:"id,omitempty" bson:"_id,omitempty"`
+	Link            string             `json:"link,omitempty" bson:"link,omitempty"`
+	OriginalLink    string             `json:"original_link,omitempty" bson:"original_link,omitempty"`
+	SiteID          uuid.UUID          `json:"site_id,omitempty" bson:"site_id,omitempty"`
+	Source          Source             `json:"source,omitempty" bson:"source,omitempty"`
+	Lang            string             `json:"lang,omitempty" bson:"lang,omitempty"`
+	Title           string             `json:"title,omitempty" bson:"title,omitempty"`
+	Desc            *string            `json:"desc,omitempty" bson:"short_desc,omitempty"`
+	LongDesc        *string            `json:"long_desc,omitempty" bson:"long_desc,omitempty"`
+	Media           *[]Media           `json:"media,omitempty" bson:"media,omitempty"`
+	Content         *ArticleContent    `json:"content,omitempty" bson:"content,omitempty"`
+	Categories      *[]string          `json:"categories,omitempty" bson:"categories,omitempty"`
+	Authors         *[]string          `json:"authors,omitempty" bson:"authors,omitempty"`
+	PubDate         time.Time          `json:"pub_date,omitempty" bson:"pub_date,omitempty"`
+	Hash            string             `json:"content_hash,omitempty" bson:"content_hash,omitempty"`
+	Digest          string             `json:"digest,omitempty" bson:"digest,omitempty"`
+	SourceUpdatedAt *time.Time         `json:"source_updated_at,omitempty" bson:"source_updated_at,omitempty"`
+	Revisions       *[]ArticleRevision `json:"revisions,omitempty" bson:"revisions,omitempty"`
+	CreatedAt       time.Time          `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt       time.Time          `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
 }
 
 func (e *Article) Tags() []string {
@@ -56,11 +87,40 @@ func (e *Article) Domain() string {
 	return util.SafeDomain(e.Link)
 }
 
+// SetLink stores the canonical link, the link the article was found by is kept when it differs.
+func (e *Article) SetLink(link, original string) *Article {
+	e.Link = link
+	if link != original {
+		e.OriginalLink = original
+	}
+	return e
+}
+
 func (e *Article) SetDesc(desc string) *Article {
 	e.Desc = &desc
 	return e
 }
 
+func (e *Article) SetLongDesc(longDesc string) *Article {
+	e.LongDesc = &longDesc
+	return e
+}
+
+func (e *Article) SetContent(content ArticleContent) *Article {
+	e.Content = &content
+	return e
+}
+
+func (e *Article) SetCategories(categories []string) *Article {
+	e.Categories = &categories
+	return e
+}
+
+func (e *Article) SetAuthors(authors []string) *Article {
+	e.Authors = &authors
+	return e
+}
+
 func (e *Article) SetMedia(media []Media) *Article {
 	e.Media = &media
 	return e
@@ -76,3 +136,56 @@ func (e *Article) FirstMedia(t MediaType) (m Media) {
 	}
 	return
 }
+
+// Revise applies the title, description and media of next, the previous values are kept as a revision.
+// It returns the names of the changed fields.
+func (e *Article) Revise(next *Article) []string {
+	var changes []string
+
+	if next.Title != "" && next.Title != e.Title {
+		changes = append(changes, "title")
+	}
+	if next.Desc != nil && (e.Desc == nil || *next.Desc != *e.Desc) {
+		changes = append(changes, "desc")
+	}
+	if next.Media != nil && (e.Media == nil || !sameMedia(*e.Media, *next.Media)) {
+		changes = append(changes, "media")
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	revisions := []ArticleRevision{{Title: e.Title, Desc: e.Desc, Media: e.Media, RevisedAt: time.Now()}}
+	if e.Revisions != nil {
+		revisions = append(revisions, *e.Revisions...)
+	}
+	if len(revisions) > MaxArticleRevisions {
+		revisions = revisions[:MaxArticleRevisions]
+	}
+	e.Revisions = &revisions
+
+	if next.Title != "" {
+		e.Title = next.Title
+	}
+	if next.Desc != nil {
+		e.Desc = next.Desc
+	}
+	if next.Media != nil {
+		e.Media = next.Media
+	}
+
+	return changes
+}
+
+func sameMedia(a, b []Media) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].URL != b[i].URL || a[i].Type != b[i].Type {
+			return false
+		}
+	}
+	return true
+}
@@ -55,6 +55,7 @@ type Chat struct {
 	FirstName  string       `json:"first_name,omitempty" bson:"first_name,omitempty"`
 	LastName   string       `json:"last_name,omitempty" bson:"last_name,omitempty"`
 	Broadcast  *[]uuid.UUID `json:"broadcast,omitempty" bson:"broadcast,omitempty"`
+	Source     *uuid.UUID   `json:"source,omitempty" bson:"source,omitempty"`
 	Rights     *ChatRights  `json:"rights,omitempty" bson:"rights,omitempty"`
 	Blocked    *bool        `json:"blocked,omitempty" bson:"blocked,omitempty"`
 	Deleted    *bool        `json:"deleted,omitempty" bson:"deleted,omitempty"`
@@ -75,6 +76,16 @@ func (e *Chat) SetBroadcast(broadcast []uuid.UUID) *Chat {
 	return e
 }
 
+// SetSource links the channel to the site its posts are published as articles of, uuid.Nil unlinks it.
+func (e *Chat) SetSource(siteID uuid.UUID) *Chat {
+	e.Source = &siteID
+	return e
+}
+
+func (e *Chat) IsSource() bool {
+	return e.Source != nil && *e.Source != uuid.Nil
+}
+
 func (e *Chat) SetRights(rights ChatRights) *Chat {
 	e.Rights = &rights
 	return e
@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const FilteredArticleCollection = "filtered_articles"
+
+// FilteredArticle is an item rejected by the content rules, it is kept for audit only and never published.
+type FilteredArticle struct {
+	ID         uuid.UUID  `json:"id,omitempty" bson:"_id,omitempty"`
+	SiteID     uuid.UUID  `json:"site_id,omitempty" bson:"site_id,omitempty"`
+	JobID      *uuid.UUID `json:"job_id,omitempty" bson:"job_id,omitempty"`
+	Source     Source     `json:"source,omitempty" bson:"source,omitempty"`
+	Link       string     `json:"link,omitempty" bson:"link,omitempty"`
+	Lang       string     `json:"lang,omitempty" bson:"lang,omitempty"`
+	Title      string     `json:"title,omitempty" bson:"title,omitempty"`
+	Desc       *string    `json:"desc,omitempty" bson:"short_desc,omitempty"`
+	Categories []string   `json:"categories,omitempty" bson:"categories,omitempty"`
+	Reason     string     `json:"reason,omitempty" bson:"reason,omitempty"`
+	PubDate    time.Time  `json:"pub_date,omitempty" bson:"pub_date,omitempty"`
+	CreatedAt  time.Time  `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+// NewFilteredArticle keeps a single record per link, the article is filtered again on every run of a sitemap.
+func NewFilteredArticle(article *Article, jobID *uuid.UUID, categories []string, reason string) *FilteredArticle {
+	return &FilteredArticle{
+		ID:         uuid.NewSHA1(uuid.NameSpaceURL, []byte(article.Link)),
+		SiteID:     article.SiteID,
+		JobID:      jobID,
+		Source:     article.Source,
+		Link:       article.Link,
+		Lang:       article.Lang,
+		Title:      article.Title,
+		Desc:       article.Desc,
+		Categories: categories,
+		Reason:     reason,
+		PubDate:    article.PubDate,
+	}
+}
+
+func (e *FilteredArticle) Tags() []string {
+	return []string{FilteredArticleCollection, e.ID.String()}
+}
+
+func (e *FilteredArticle) EntityID() uuid.UUID {
+	return e.ID
+}
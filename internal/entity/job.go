@@ -12,8 +12,11 @@ type (
 )
 
 const (
-	JobFeed    JobName = "job:feed"
-	JobSitemap JobName = "job:sitemap"
+	JobFeed        JobName = "job:feed"
+	JobSitemap     JobName = "job:sitemap"
+	JobHTML        JobName = "job:html"
+	JobJSON        JobName = "job:json"
+	JobActivityPub JobName = "job:activitypub"
 
 	JobCollection = "jobs"
 )
@@ -37,21 +40,112 @@ type JobOption struct {
 }
 
 type FeedPayload struct {
-	JobID  *uuid.UUID `json:"job_id,omitempty" bson:"-"`
-	SiteID uuid.UUID  `json:"site_id,omitempty" bson:"site_id,omitempty"`
-	Link   string     `json:"link,omitempty" bson:"link,omitempty"`
+	JobID   *uuid.UUID    `json:"job_id,omitempty" bson:"-"`
+	SiteID  uuid.UUID     `json:"site_id,omitempty" bson:"site_id,omitempty"`
+	Link    string        `json:"link,omitempty" bson:"link,omitempty"`
+	Workers *int          `json:"workers,omitempty" bson:"workers,omitempty"`
+	Extract *bool         `json:"extract,omitempty" bson:"extract,omitempty"`
+	Rules   *ContentRules `json:"rules,omitempty" bson:"rules,omitempty"`
+	Force   bool          `json:"force,omitempty" bson:"-"`
+	// Pushed is the feed delivered by a WebSub hub, it is processed instead of fetching the link.
+	Pushed []byte `json:"pushed,omitempty" bson:"-"`
 }
 
 type SitemapPayload struct {
-	JobID      *uuid.UUID `json:"job_id,omitempty" bson:"-"`
-	SiteID     uuid.UUID  `json:"site_id,omitempty" bson:"site_id,omitempty"`
-	Link       string     `json:"link,omitempty" bson:"link,omitempty"`
-	Lang       *string    `json:"lang,omitempty" bson:"lang,omitempty"`
-	MatchLoc   *string    `json:"match_loc,omitempty" bson:"match_loc,omitempty"`
-	SearchLoc  *string    `json:"search_loc,omitempty" bson:"search_loc,omitempty"`
-	SearchLink *string    `json:"search_link,omitempty" bson:"search_link,omitempty"`
-	Index      *bool      `json:"index,omitempty" bson:"index,omitempty"`
-	StopOnDup  *bool      `json:"stop_on_dup,omitempty" bson:"stop_on_dup,omitempty"`
+	JobID      *uuid.UUID    `json:"job_id,omitempty" bson:"-"`
+	SiteID     uuid.UUID     `json:"site_id,omitempty" bson:"site_id,omitempty"`
+	Link       string        `json:"link,omitempty" bson:"link,omitempty"`
+	Lang       *string       `json:"lang,omitempty" bson:"lang,omitempty"`
+	MatchLoc   *string       `json:"match_loc,omitempty" bson:"match_loc,omitempty"`
+	SearchLoc  *string       `json:"search_loc,omitempty" bson:"search_loc,omitempty"`
+	SearchLink *string       `json:"search_link,omitempty" bson:"search_link,omitempty"`
+	Index      *bool         `json:"index,omitempty" bson:"index,omitempty"`
+	StopOnDup  *bool         `json:"stop_on_dup,omitempty" bson:"stop_on_dup,omitempty"`
+	Workers    *int          `json:"workers,omitempty" bson:"workers,omitempty"`
+	Extract    *bool         `json:"extract,omitempty" bson:"extract,omitempty"`
+	Rules      *ContentRules `json:"rules,omitempty" bson:"rules,omitempty"`
+	Group      string        `json:"group,omitempty" bson:"-"`
+	LastMod    *time.Time    `json:"lastmod,omitempty" bson:"-"`
+	Force      bool          `json:"force,omitempty" bson:"-"`
+}
+
+// HTMLPayload scrapes the articles of a listing page by CSS selectors, the selectors of the item parts
+// are relative to the item container. Next page links are followed up to MaxPages.
+type HTMLPayload struct {
+	JobID         *uuid.UUID    `json:"job_id,omitempty" bson:"-"`
+	SiteID        uuid.UUID     `json:"site_id,omitempty" bson:"site_id,omitempty"`
+	Link          string        `json:"link,omitempty" bson:"link,omitempty"`
+	Lang          *string       `json:"lang,omitempty" bson:"lang,omitempty"`
+	ItemSelector  string        `json:"item_selector,omitempty" bson:"item_selector,omitempty"`
+	LinkSelector  *string       `json:"link_selector,omitempty" bson:"link_selector,omitempty"`
+	TitleSelector *string       `json:"title_selector,omitempty" bson:"title_selector,omitempty"`
+	DateSelector  *string       `json:"date_selector,omitempty" bson:"date_selector,omitempty"`
+	DateFormat    *string       `json:"date_format,omitempty" bson:"date_format,omitempty"`
+	ImageSelector *string       `json:"image_selector,omitempty" bson:"image_selector,omitempty"`
+	NextSelector  *string       `json:"next_selector,omitempty" bson:"next_selector,omitempty"`
+	MaxPages      *int          `json:"max_pages,omitempty" bson:"max_pages,omitempty"`
+	Workers       *int          `json:"workers,omitempty" bson:"workers,omitempty"`
+	Extract       *bool         `json:"extract,omitempty" bson:"extract,omitempty"`
+	Rules         *ContentRules `json:"rules,omitempty" bson:"rules,omitempty"`
+	Force         bool          `json:"force,omitempty" bson:"-"`
+}
+
+// JSONMapping maps the paths of an API response to the fields of the articles. A path is a dot separated list
+// of object keys and array indexes, * iterates an array. The paths of the item fields are relative to an item.
+type JSONMapping struct {
+	Items      string `json:"items,omitempty" bson:"items,omitempty"`
+	Link       string `json:"link,omitempty" bson:"link,omitempty"`
+	Title      string `json:"title,omitempty" bson:"title,omitempty"`
+	Desc       string `json:"desc,omitempty" bson:"desc,omitempty"`
+	Date       string `json:"date,omitempty" bson:"date,omitempty"`
+	DateFormat string `json:"date_format,omitempty" bson:"date_format,omitempty"`
+	Image      string `json:"image,omitempty" bson:"image,omitempty"`
+	Categories string `json:"categories,omitempty" bson:"categories,omitempty"`
+}
+
+// JSONPayload reads the articles of a JSON API. The next pages are requested either by incrementing
+// the page query parameter or by passing the cursor found at CursorPath of the response in CursorParam.
+type JSONPayload struct {
+	JobID       *uuid.UUID        `json:"job_id,omitempty" bson:"-"`
+	SiteID      uuid.UUID         `json:"site_id,omitempty" bson:"site_id,omitempty"`
+	Link        string            `json:"link,omitempty" bson:"link,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty" bson:"headers,omitempty"`
+	Lang        *string           `json:"lang,omitempty" bson:"lang,omitempty"`
+	Mapping     JSONMapping       `json:"mapping,omitempty" bson:"mapping,omitempty"`
+	PageParam   *string           `json:"page_param,omitempty" bson:"page_param,omitempty"`
+	CursorParam *string           `json:"cursor_param,omitempty" bson:"cursor_param,omitempty"`
+	CursorPath  *string           `json:"cursor_path,omitempty" bson:"cursor_path,omitempty"`
+	MaxPages    *int              `json:"max_pages,omitempty" bson:"max_pages,omitempty"`
+	Workers     *int              `json:"workers,omitempty" bson:"workers,omitempty"`
+	Extract     *bool             `json:"extract,omitempty" bson:"extract,omitempty"`
+	Rules       *ContentRules     `json:"rules,omitempty" bson:"rules,omitempty"`
+	Force       bool              `json:"force,omitempty" bson:"-"`
+}
+
+// ActivityPubPayload reads the posts of a fediverse actor from its outbox. The actor is either
+// a handle like @user@host resolved by WebFinger or the link of the actor document.
+type ActivityPubPayload struct {
+	JobID    *uuid.UUID    `json:"job_id,omitempty" bson:"-"`
+	SiteID   uuid.UUID     `json:"site_id,omitempty" bson:"site_id,omitempty"`
+	Actor    string        `json:"actor,omitempty" bson:"actor,omitempty"`
+	Lang     *string       `json:"lang,omitempty" bson:"lang,omitempty"`
+	MaxPages *int          `json:"max_pages,omitempty" bson:"max_pages,omitempty"`
+	Workers  *int          `json:"workers,omitempty" bson:"workers,omitempty"`
+	Extract  *bool         `json:"extract,omitempty" bson:"extract,omitempty"`
+	Rules    *ContentRules `json:"rules,omitempty" bson:"rules,omitempty"`
+	Force    bool          `json:"force,omitempty" bson:"-"`
+}
+
+func (p *FeedPayload) SetWorkers(workers int) *FeedPayload {
+	p.Workers = &workers
+	return p
+}
+
+func (p *FeedPayload) WorkersCount() int {
+	if p.Workers == nil {
+		return 0
+	}
+	return *p.Workers
 }
 
 func (p *SitemapPayload) SetLang(lang string) *SitemapPayload {
@@ -92,15 +186,139 @@ func (p *SitemapPayload) StoppingOnDup() bool {
 	return p.StopOnDup != nil && *p.StopOnDup
 }
 
+func (p *SitemapPayload) SetWorkers(workers int) *SitemapPayload {
+	p.Workers = &workers
+	return p
+}
+
+func (p *SitemapPayload) WorkersCount() int {
+	if p.Workers == nil {
+		return 0
+	}
+	return *p.Workers
+}
+
+// IsChild reports whether the payload belongs to a child task enqueued by a sitemap index.
+func (p *SitemapPayload) IsChild() bool {
+	return p.Group != ""
+}
+
+func (p *HTMLPayload) WorkersCount() int {
+	if p.Workers == nil {
+		return 0
+	}
+	return *p.Workers
+}
+
+// PagesCount returns the number of listing pages to scrape, the first page only by default.
+func (p *HTMLPayload) PagesCount() int {
+	if p.MaxPages == nil || *p.MaxPages < 1 || p.NextSelector == nil || *p.NextSelector == "" {
+		return 1
+	}
+	return *p.MaxPages
+}
+
+func (p *JSONPayload) WorkersCount() int {
+	if p.Workers == nil {
+		return 0
+	}
+	return *p.Workers
+}
+
+// Paged reports whether the payload tells how to request the next pages.
+func (p *JSONPayload) Paged() bool {
+	return (p.PageParam != nil && *p.PageParam != "") ||
+		(p.CursorParam != nil && *p.CursorParam != "" && p.CursorPath != nil && *p.CursorPath != "")
+}
+
+// PagesCount returns the number of pages to request, the first page only by default.
+func (p *JSONPayload) PagesCount() int {
+	if p.MaxPages == nil || *p.MaxPages < 1 || !p.Paged() {
+		return 1
+	}
+	return *p.MaxPages
+}
+
+func (p *ActivityPubPayload) WorkersCount() int {
+	if p.Workers == nil {
+		return 0
+	}
+	return *p.Workers
+}
+
+// PagesCount returns the number of outbox pages to read, the first page only by default.
+func (p *ActivityPubPayload) PagesCount() int {
+	if p.MaxPages == nil || *p.MaxPages < 1 {
+		return 1
+	}
+	return *p.MaxPages
+}
+
+type FetchState struct {
+	Link         string    `json:"link,omitempty" bson:"link,omitempty"`
+	ETag         string    `json:"etag,omitempty" bson:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty" bson:"last_modified,omitempty"`
+	Hash         string    `json:"hash,omitempty" bson:"hash,omitempty"`
+	Status       int       `json:"status,omitempty" bson:"status,omitempty"`
+	CheckedAt    time.Time `json:"checked_at,omitempty" bson:"checked_at,omitempty"`
+	ChangedAt    time.Time `json:"changed_at,omitempty" bson:"changed_at,omitempty"`
+	LastSeen     string    `json:"last_seen,omitempty" bson:"last_seen,omitempty"`
+}
+
+type WebSubStatus string
+
+const (
+	WebSubPending WebSubStatus = "pending"
+	WebSubActive  WebSubStatus = "active"
+	WebSubDenied  WebSubStatus = "denied"
+)
+
+// WebSub is the subscription of a feed job to the hub the feed advertises.
+type WebSub struct {
+	Hub          string       `json:"hub,omitempty" bson:"hub,omitempty"`
+	Topic        string       `json:"topic,omitempty" bson:"topic,omitempty"`
+	Secret       string       `json:"-" bson:"secret,omitempty"`
+	Status       WebSubStatus `json:"status,omitempty" bson:"status,omitempty"`
+	LeaseSeconds int          `json:"lease_seconds,omitempty" bson:"lease_seconds,omitempty"`
+	ExpiresAt    time.Time    `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	Reason       string       `json:"reason,omitempty" bson:"reason,omitempty"`
+	ChangedAt    time.Time    `json:"changed_at,omitempty" bson:"changed_at,omitempty"`
+}
+
+// Active reports whether the hub verified the subscription and its lease is not over.
+func (w *WebSub) Active(now time.Time) bool {
+	return w != nil && w.Status == WebSubActive && now.Before(w.ExpiresAt)
+}
+
+type JobHealthStatus string
+
+const (
+	JobHealthy   JobHealthStatus = "healthy"
+	JobDegraded  JobHealthStatus = "degraded"
+	JobDisabled  JobHealthStatus = "disabled"
+	JobRecovered JobHealthStatus = "recovered"
+)
+
+type JobHealth struct {
+	Status    JobHealthStatus `json:"status,omitempty" bson:"status,omitempty"`
+	Failures  int             `json:"failures,omitempty" bson:"failures,omitempty"`
+	NextRunAt time.Time       `json:"next_run_at,omitempty" bson:"next_run_at,omitempty"`
+	ChangedAt time.Time       `json:"changed_at,omitempty" bson:"changed_at,omitempty"`
+}
+
 type Job struct {
-	ID        uuid.UUID    `json:"id,omitempty" bson:"_id"`
-	CronExpr  string       `json:"cron_expr,omitempty" bson:"cron_expr,omitempty"`
-	Name      JobName      `json:"name,omitempty" bson:"name,omitempty"`
-	Payload   any          `json:"payload,omitempty" bson:"payload,omitempty"`
-	Options   *[]JobOption `json:"options,omitempty" bson:"options,omitempty"`
-	Enabled   *bool        `json:"enabled,omitempty" bson:"enabled,omitempty"`
-	CreatedAt time.Time    `json:"created_at,omitempty" bson:"created_at,omitempty"`
-	UpdatedAt time.Time    `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	ID         uuid.UUID    `json:"id,omitempty" bson:"_id"`
+	CronExpr   string       `json:"cron_expr,omitempty" bson:"cron_expr,omitempty"`
+	Name       JobName      `json:"name,omitempty" bson:"name,omitempty"`
+	Payload    any          `json:"payload,omitempty" bson:"payload,omitempty"`
+	Options    *[]JobOption `json:"options,omitempty" bson:"options,omitempty"`
+	Enabled    *bool        `json:"enabled,omitempty" bson:"enabled,omitempty"`
+	FetchState *FetchState  `json:"fetch_state,omitempty" bson:"fetch_state,omitempty"`
+	LastRun    *JobRunStats `json:"last_run,omitempty" bson:"last_run,omitempty"`
+	Health     *JobHealth   `json:"health,omitempty" bson:"health,omitempty"`
+	WebSub     *WebSub      `json:"websub,omitempty" bson:"websub,omitempty"`
+	CreatedAt  time.Time    `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt  time.Time    `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
 }
 
 func (e *Job) Tags() []string {
@@ -109,14 +327,18 @@ func (e *Job) Tags() []string {
 
 func (e *Job) UnmarshalBSON(data []byte) error {
 	var job struct {
-		ID        uuid.UUID    `json:"id,omitempty" bson:"_id"`
-		CronExpr  string       `json:"cron_expr,omitempty" bson:"cron_expr,omitempty"`
-		Name      JobName      `json:"name,omitempty" bson:"name,omitempty"`
-		Payload   bson.Raw     `json:"payload,omitempty" bson:"payload,omitempty"`
-		Options   *[]JobOption `json:"options,omitempty" bson:"options,omitempty"`
-		Enabled   *bool        `json:"enabled,omitempty" bson:"enabled,omitempty"`
-		CreatedAt time.Time    `json:"created_at,omitempty" bson:"created_at,omitempty"`
-		UpdatedAt time.Time    `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+		ID         uuid.UUID    `json:"id,omitempty" bson:"_id"`
+		CronExpr   string       `json:"cron_expr,omitempty" bson:"cron_expr,omitempty"`
+		Name       JobName      `json:"name,omitempty" bson:"name,omitempty"`
+		Payload    bson.Raw     `json:"payload,omitempty" bson:"payload,omitempty"`
+		Options    *[]JobOption `json:"options,omitempty" bson:"options,omitempty"`
+		Enabled    *bool        `json:"enabled,omitempty" bson:"enabled,omitempty"`
+		FetchState *FetchState  `json:"fetch_state,omitempty" bson:"fetch_state,omitempty"`
+		LastRun    *JobRunStats `json:"last_run,omitempty" bson:"last_run,omitempty"`
+		Health     *JobHealth   `json:"health,omitempty" bson:"health,omitempty"`
+		WebSub     *WebSub      `json:"websub,omitempty" bson:"websub,omitempty"`
+		CreatedAt  time.Time    `json:"created_at,omitempty" bson:"created_at,omitempty"`
+		UpdatedAt  time.Time    `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
 	}
 
 	if err := bson.Unmarshal(data, &job); err != nil {
@@ -128,6 +350,10 @@ func (e *Job) UnmarshalBSON(data []byte) error {
 	e.Name = job.Name
 	e.Options = job.Options
 	e.Enabled = job.Enabled
+	e.FetchState = job.FetchState
+	e.LastRun = job.LastRun
+	e.Health = job.Health
+	e.WebSub = job.WebSub
 	e.CreatedAt = job.CreatedAt
 	e.UpdatedAt = job.UpdatedAt
 
@@ -136,6 +362,12 @@ func (e *Job) UnmarshalBSON(data []byte) error {
 		e.Payload = &FeedPayload{}
 	case JobSitemap:
 		e.Payload = &SitemapPayload{}
+	case JobHTML:
+		e.Payload = &HTMLPayload{}
+	case JobJSON:
+		e.Payload = &JSONPayload{}
+	case JobActivityPub:
+		e.Payload = &ActivityPubPayload{}
 	default:
 		return nil
 	}
@@ -157,6 +389,52 @@ func (e *Job) SetEnabled(enabled bool) *Job {
 	return e
 }
 
+func (e *Job) SetFetchState(state FetchState) *Job {
+	e.FetchState = &state
+	return e
+}
+
+func (e *Job) SetLastRun(stats JobRunStats) *Job {
+	e.LastRun = &stats
+	return e
+}
+
+func (e *Job) SetHealth(health JobHealth) *Job {
+	e.Health = &health
+	return e
+}
+
+func (e *Job) SetWebSub(websub WebSub) *Job {
+	e.WebSub = &websub
+	return e
+}
+
+// StateOnly reports whether the job carries nothing but runtime state,
+// such saves must not touch updated_at, otherwise the scheduler re-registers the job.
+func (e *Job) StateOnly() bool {
+	return e.CronExpr == "" && e.Name == "" && e.Payload == nil && e.Options == nil && e.Enabled == nil
+}
+
+// TaskPayload returns the payload of the job task bound to the job.
+func (e *Job) TaskPayload() any {
+	id := e.ID
+
+	switch p := e.Payload.(type) {
+	case *FeedPayload:
+		p.JobID = &id
+	case *SitemapPayload:
+		p.JobID = &id
+	case *HTMLPayload:
+		p.JobID = &id
+	case *JSONPayload:
+		p.JobID = &id
+	case *ActivityPubPayload:
+		p.JobID = &id
+	}
+
+	return e.Payload
+}
+
 func (e *Job) HasOptions() bool {
 	return e.Options != nil
 }
@@ -0,0 +1,48 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const JobRunCollection = "job_runs"
+
+type JobRunStats struct {
+	Status     int       `json:"status,omitempty" bson:"status,omitempty"`
+	Seen       int       `json:"seen" bson:"seen,omitempty"`
+	Saved      int       `json:"saved" bson:"saved,omitempty"`
+	Updated    int       `json:"updated" bson:"updated,omitempty"`
+	Duplicates int       `json:"duplicates" bson:"duplicates,omitempty"`
+	Filtered   int       `json:"filtered" bson:"filtered,omitempty"`
+	OGFailures int       `json:"og_failures" bson:"og_failures,omitempty"`
+	Unchanged  bool      `json:"unchanged,omitempty" bson:"unchanged,omitempty"`
+	Error      string    `json:"error,omitempty" bson:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at,omitempty" bson:"started_at,omitempty"`
+	FinishedAt time.Time `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+}
+
+func (s JobRunStats) Failed() bool {
+	return s.Error != ""
+}
+
+type JobRun struct {
+	JobRunStats `bson:",inline"`
+
+	ID        uuid.UUID `json:"id,omitempty" bson:"_id,omitempty"`
+	JobID     uuid.UUID `json:"job_id,omitempty" bson:"job_id,omitempty"`
+	SiteID    uuid.UUID `json:"site_id,omitempty" bson:"site_id,omitempty"`
+	Name      JobName   `json:"name,omitempty" bson:"name,omitempty"`
+	TaskID    string    `json:"task_id,omitempty" bson:"task_id,omitempty"`
+	Group     string    `json:"group,omitempty" bson:"group,omitempty"`
+	Link      string    `json:"link,omitempty" bson:"link,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+func (e *JobRun) Tags() []string {
+	return []string{JobRunCollection, e.ID.String()}
+}
+
+func (e *JobRun) EntityID() uuid.UUID {
+	return e.ID
+}
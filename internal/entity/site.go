@@ -7,15 +7,42 @@ import (
 
 const SiteCollection = "sites"
 
+type RuleField string
+
+const (
+	RuleTitle    RuleField = "title"
+	RuleDesc     RuleField = "desc"
+	RulePath     RuleField = "path"
+	RuleCategory RuleField = "category"
+)
+
+// ContentRules decide which items of a site are published. An item must match one of the include rules
+// when there are any and must match none of the exclude rules.
+type ContentRules struct {
+	Include      []ContentRule `json:"include,omitempty" bson:"include,omitempty"`
+	Exclude      []ContentRule `json:"exclude,omitempty" bson:"exclude,omitempty"`
+	MinDescLen   int           `json:"min_desc_len,omitempty" bson:"min_desc_len,omitempty"`
+	KeepFiltered bool          `json:"keep_filtered,omitempty" bson:"keep_filtered,omitempty"`
+}
+
+type ContentRule struct {
+	Field   RuleField `json:"field,omitempty" bson:"field,omitempty"`
+	Pattern string    `json:"pattern,omitempty" bson:"pattern,omitempty"`
+}
+
 type Site struct {
-	ID        uuid.UUID `json:"id,omitempty" bson:"_id,omitempty"`
-	Domain    string    `json:"domain,omitempty" bson:"domain,omitempty"`
-	Favicon   string    `json:"favicon,omitempty" bson:"favicon,omitempty"`
-	Languages []string  `json:"languages,omitempty" bson:"languages,omitempty"`
-	Title     string    `json:"title,omitempty" bson:"title,omitempty"`
-	Enabled   *bool     `json:"enabled,omitempty" bson:"enabled,omitempty"`
-	CreatedAt time.Time `json:"created_at,omitempty" bson:"created_at,omitempty"`
-	UpdatedAt time.Time `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	ID            uuid.UUID     `json:"id,omitempty" bson:"_id,omitempty"`
+	Domain        string        `json:"domain,omitempty" bson:"domain,omitempty"`
+	Favicon       string        `json:"favicon,omitempty" bson:"favicon,omitempty"`
+	Languages     []string      `json:"languages,omitempty" bson:"languages,omitempty"`
+	Title         string        `json:"title,omitempty" bson:"title,omitempty"`
+	Enabled       *bool         `json:"enabled,omitempty" bson:"enabled,omitempty"`
+	RespectRobots *bool         `json:"respect_robots,omitempty" bson:"respect_robots,omitempty"`
+	Extract       *bool         `json:"extract,omitempty" bson:"extract,omitempty"`
+	Stages        []string      `json:"stages,omitempty" bson:"stages,omitempty"`
+	Rules         *ContentRules `json:"rules,omitempty" bson:"rules,omitempty"`
+	CreatedAt     time.Time     `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt     time.Time     `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
 }
 
 func (e *Site) Tags() []string {
@@ -30,3 +57,25 @@ func (e *Site) SetEnabled(enabled bool) *Site {
 	e.Enabled = &enabled
 	return e
 }
+
+func (e *Site) SetRespectRobots(respectRobots bool) *Site {
+	e.RespectRobots = &respectRobots
+	return e
+}
+
+func (e *Site) RespectsRobots() bool {
+	return e.RespectRobots != nil && *e.RespectRobots
+}
+
+func (e *Site) SetExtract(extract bool) *Site {
+	e.Extract = &extract
+	return e
+}
+
+// Extracts reports whether the readable content of articles is extracted, a job may override it.
+func (e *Site) Extracts(override *bool) bool {
+	if override != nil {
+		return *override
+	}
+	return e.Extract != nil && *e.Extract
+}
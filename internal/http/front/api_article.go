@@ -69,6 +69,10 @@ func (a *ArticleActions) List(c wool.Ctx) error {
 		articlesFilter["lang"] = bson.M{"$in": strings.Split(query.Get("langs"), ",")}
 	}
 
+	if query.Has("categories") {
+		articlesFilter["categories"] = bson.M{"$in": strings.Split(strings.ToLower(query.Get("categories")), ",")}
+	}
+
 	total, err := a.ArticleRepo.Count(c.Req().Context(), articlesFilter)
 	if err != nil {
 		return err
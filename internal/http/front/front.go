@@ -56,6 +56,7 @@ func (front *Front) listen(done <-chan struct{}) {
 	}()
 
 	articlesCh := front.Sub.Articles(ctx).Channel()
+	updatesCh := front.Sub.ArticleUpdated(ctx).Channel()
 
 	for {
 		select {
@@ -69,6 +70,14 @@ func (front *Front) listen(done <-chan struct{}) {
 				Event: "articles",
 				Data:  data.Payload,
 			})
+		case data := <-updatesCh:
+			if data == nil {
+				continue
+			}
+			front.SSE.Broadcast(render.SSEvent{
+				Event: "article.updated",
+				Data:  data.Payload,
+			})
 		}
 	}
 }
@@ -27,7 +27,8 @@ func nopSiteList() {}
 //	@Param			index	query		int				false	"Page Index"	default(0)	minimum(0)
 //	@Param			size	query		int				false	"Page Size"		default(20)	minimum(1)	maximum(100)
 //	@Param			sites	query		string			false	"Sites"
-//	@Param			langs	query		string			false	"Languages"
+//	@Param			langs		query		string			false	"Languages"
+//	@Param			categories	query		string			false	"Categories"
 //	@Param			dt		query		string			false	"From DateTime"	Format(date-time)
 //	@Success		200		{array}		model.Article	"OK"
 //	@Failure		400		{object}	wool.Error
@@ -0,0 +1,85 @@
+package http
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gowool/wool"
+	"github.com/rumorsflow/rumors/v2/internal/task"
+	"golang.org/x/exp/slog"
+	"net/http"
+)
+
+// WebSub is the callback of the WebSub hubs, the intents of the hubs are verified by GET
+// and the content of the subscribed feeds is delivered by POST.
+type WebSub struct {
+	websub *task.WebSub
+	logger *slog.Logger
+}
+
+func NewWebSub(websub *task.WebSub, logger *slog.Logger) *WebSub {
+	return &WebSub{websub: websub, logger: logger}
+}
+
+func (ws *WebSub) Register(mux *wool.Wool) {
+	mux.Group("/websub", func(w *wool.Wool) {
+		w.GET("/:id", ws.Verify)
+		w.POST("/:id", ws.Receive)
+	})
+
+	ws.logger.Info("websub callback registered")
+}
+
+func (ws *WebSub) Verify(c wool.Ctx) error {
+	id, err := uuid.Parse(c.Req().PathParamID())
+	if err != nil {
+		return wool.NewErrNotFound(err)
+	}
+
+	challenge, err := ws.websub.Verify(c.Req().Context(), id, c.Req().URL.Query())
+	if err != nil {
+		if errors.Is(err, task.ErrWebSubIntent) {
+			return wool.NewErrNotFound(err)
+		}
+		return err
+	}
+
+	if challenge == "" {
+		return c.OK()
+	}
+
+	return c.Blob(http.StatusOK, "text/plain; charset=utf-8", []byte(challenge))
+}
+
+func (ws *WebSub) Receive(c wool.Ctx) error {
+	id, err := uuid.Parse(c.Req().PathParamID())
+	if err != nil {
+		return wool.NewErrNotFound(err)
+	}
+
+	body, err := ws.websub.ReadBody(c.Req().Body)
+	if err != nil {
+		if errors.Is(err, task.ErrBodyTooLarge) {
+			return wool.NewErrRequestEntityTooLarge(err)
+		}
+		return err
+	}
+
+	signature := c.Req().Header.Get("X-Hub-Signature")
+	if signature == "" {
+		signature = c.Req().Header.Get("X-Hub-Signature-256")
+	}
+
+	if err = ws.websub.Receive(c.Req().Context(), id, body, signature); err != nil {
+		switch {
+		case errors.Is(err, task.ErrWebSubSignature):
+			// the content is ignored, yet the hub is answered with success as the spec requires
+			ws.logger.Warn("websub content ignored", "err", err, "job_id", id)
+		case errors.Is(err, task.ErrWebSubIntent):
+			return wool.NewErrNotFound(err)
+		default:
+			return err
+		}
+	}
+
+	return c.Status(http.StatusAccepted)
+}
@@ -10,12 +10,14 @@ import (
 	"github.com/gowool/swagger"
 	"github.com/gowool/wool"
 	"github.com/redis/go-redis/v9"
+	"github.com/roadrunner-server/endure/v2/dep"
 	"github.com/roadrunner-server/errors"
 	_ "github.com/rumorsflow/rumors/v2/docs"
 	"github.com/rumorsflow/rumors/v2/internal/common"
 	"github.com/rumorsflow/rumors/v2/internal/entity"
 	"github.com/rumorsflow/rumors/v2/internal/http/front"
 	"github.com/rumorsflow/rumors/v2/internal/http/sys"
+	"github.com/rumorsflow/rumors/v2/internal/task"
 	"github.com/rumorsflow/rumors/v2/pkg/config"
 	"github.com/rumorsflow/rumors/v2/pkg/errs"
 	"github.com/rumorsflow/rumors/v2/pkg/jwt"
@@ -36,9 +38,22 @@ type Plugin struct {
 	front        *front.Front
 	sys          *sys.Sys
 	done         chan struct{}
+	logger       *slog.Logger
+	webSubCfg    *task.WebSubConfig
+	siteRepo     repository.ReadWriteRepository[*entity.Site]
+	jobRepo      repository.ReadWriteRepository[*entity.Job]
+	jobRunRepo   repository.ReadWriteRepository[*entity.JobRun]
+	taskClient   common.Client
+	fetcher      *task.Fetcher
 }
 
-func (p *Plugin) Init(cfg config.Configurer, rdbMaker common.RedisMaker, sub common.Sub, uow common.UnitOfWork, log logger.Logger) error {
+func (p *Plugin) Init(
+	cfg config.Configurer,
+	rdbMaker common.RedisMaker,
+	sub common.Sub,
+	uow common.UnitOfWork,
+	log logger.Logger,
+) error {
 	const op = errors.Op("http_plugin_init")
 
 	if !cfg.Has(PluginName) {
@@ -70,6 +85,12 @@ func (p *Plugin) Init(cfg config.Configurer, rdbMaker common.RedisMaker, sub com
 	}
 	articleRepo := articleAny.(repository.ReadWriteRepository[*entity.Article])
 
+	filteredAny, err := uow.Repository((*entity.FilteredArticle)(nil))
+	if err != nil {
+		return errors.E(op, err)
+	}
+	filteredRepo := filteredAny.(repository.ReadWriteRepository[*entity.FilteredArticle])
+
 	chatAny, err := uow.Repository((*entity.Chat)(nil))
 	if err != nil {
 		return errors.E(op, err)
@@ -82,6 +103,12 @@ func (p *Plugin) Init(cfg config.Configurer, rdbMaker common.RedisMaker, sub com
 	}
 	jobRepo := jobAny.(repository.ReadWriteRepository[*entity.Job])
 
+	jobRunAny, err := uow.Repository((*entity.JobRun)(nil))
+	if err != nil {
+		return errors.E(op, err)
+	}
+	jobRunRepo := jobRunAny.(repository.ReadWriteRepository[*entity.JobRun])
+
 	sysUserAny, err := uow.Repository((*entity.SysUser)(nil))
 	if err != nil {
 		return errors.E(op, err)
@@ -98,6 +125,10 @@ func (p *Plugin) Init(cfg config.Configurer, rdbMaker common.RedisMaker, sub com
 	p.queueActions = sys.NewQueueActions(rdbMaker)
 	p.client = client
 
+	if p.webSubCfg, err = task.LoadWebSubConfig(cfg); err != nil {
+		return errors.E(op, err)
+	}
+
 	l := log.NamedLogger(PluginName)
 	frontLog := l.WithGroup("front")
 	sysLog := l.WithGroup("sys")
@@ -141,18 +172,24 @@ func (p *Plugin) Init(cfg config.Configurer, rdbMaker common.RedisMaker, sub com
 	}
 
 	p.sys = &sys.Sys{
-		Logger:         sysLog,
-		CfgJWT:         httpCfg.JWT,
-		DirUI:          httpCfg.UI.SysPath,
-		QueueActions:   p.queueActions,
-		SSE:            sys.NewSSE(rdbMaker, sysLog.WithGroup("sse")),
-		AuthActions:    sys.NewAuthActions(authService, sysLog.WithGroup("auth")),
-		ArticleActions: sys.NewArticleActions(articleRepo, articleRepo),
-		SiteCRUD:       sys.NewSiteCRUD(siteRepo, siteRepo),
-		ChatCRUD:       sys.NewChatCRUD(chatRepo, chatRepo),
-		JobCRUD:        sys.NewJobCRUD(jobRepo, jobRepo),
+		Logger:          sysLog,
+		CfgJWT:          httpCfg.JWT,
+		DirUI:           httpCfg.UI.SysPath,
+		QueueActions:    p.queueActions,
+		SSE:             sys.NewSSE(rdbMaker, sysLog.WithGroup("sse")),
+		AuthActions:     sys.NewAuthActions(authService, sysLog.WithGroup("auth")),
+		ArticleActions:  sys.NewArticleActions(articleRepo, articleRepo),
+		FilteredActions: sys.NewFilteredArticleActions(filteredRepo, filteredRepo),
+		SiteCRUD:        sys.NewSiteCRUD(siteRepo, siteRepo),
+		ChatCRUD:        sys.NewChatCRUD(chatRepo, chatRepo),
+		JobCRUD:         sys.NewJobCRUD(jobRepo, jobRepo),
 	}
 
+	p.siteRepo = siteRepo
+	p.jobRepo = jobRepo
+	p.jobRunRepo = jobRunRepo
+	p.logger = l
+
 	p.front = &front.Front{
 		Logger:         frontLog,
 		Sub:            sub,
@@ -162,18 +199,57 @@ func (p *Plugin) Init(cfg config.Configurer, rdbMaker common.RedisMaker, sub com
 		ArticleActions: &front.ArticleActions{ArticleRepo: articleRepo, SiteRepo: siteRepo},
 	}
 
+	return nil
+}
+
+// Collects resolves the client and the fetcher of the task plugin, without it running the jobs, discovering sites,
+// previewing jobs and receiving WebSub content are not available.
+func (p *Plugin) Collects() []*dep.In {
+	return []*dep.In{
+		dep.Fits(func(client any) {
+			p.taskClient = client.(common.Client)
+		}, (*common.Client)(nil)),
+		dep.Fits(func(provider any) {
+			p.fetcher = provider.(task.FetcherProvider).Fetcher()
+		}, (*task.FetcherProvider)(nil)),
+	}
+}
+
+// mount registers the routes, the dependencies collected after Init are resolved by then.
+func (p *Plugin) mount() {
+	var (
+		discoverer sys.SiteDiscoverer
+		previewer  sys.JobPreviewer
+	)
+
+	if p.fetcher != nil {
+		discoverer = task.NewDiscoverer(p.fetcher)
+		previewer = task.NewPreviewer(p.fetcher, p.siteRepo, p.sys.Logger.WithGroup("preview"))
+	}
+
+	p.sys.SiteActions = sys.NewSiteActions(p.siteRepo, p.jobRepo, discoverer)
+	p.sys.JobActions = sys.NewJobActions(p.jobRepo, p.jobRunRepo, p.taskClient, previewer, task.NewGroups(p.client))
+
 	p.w.Group("", func(sw *wool.Wool) {
 		p.sys.Register(sw)
 	})
 
+	if p.fetcher != nil && p.taskClient != nil {
+		if websub := task.NewWebSub(p.webSubCfg, p.fetcher, p.jobRepo, p.taskClient, p.logger.WithGroup("websub")); websub.Enabled() {
+			p.w.Group("", func(ww *wool.Wool) {
+				NewWebSub(websub, p.logger.WithGroup("websub")).Register(ww)
+			})
+		}
+	}
+
 	p.w.Group("", func(fw *wool.Wool) {
 		p.front.Register(fw)
 	})
-
-	return nil
 }
 
 func (p *Plugin) Serve() chan error {
+	p.mount()
+
 	errCh := make(chan error, 1)
 
 	go func(w *wool.Wool, srv *wool.Server, errCh chan<- error) {
@@ -5,6 +5,8 @@ import (
 	"github.com/rumorsflow/rumors/v2/internal/entity"
 	"github.com/rumorsflow/rumors/v2/internal/http/action"
 	"github.com/rumorsflow/rumors/v2/pkg/repository"
+	"github.com/rumorsflow/rumors/v2/pkg/util"
+	"strings"
 )
 
 type MediaDTO struct {
@@ -22,18 +24,40 @@ func (dto MediaDTO) toEntity() entity.Media {
 }
 
 type UpdateArticleDTO struct {
-	Lang  string      `json:"lang,omitempty" validate:"omitempty,bcp47_language_tag"`
-	Title string      `json:"title,omitempty" validate:"omitempty,max=254"`
-	Desc  *string     `json:"desc,omitempty" validate:"omitempty,max=500"`
-	Media *[]MediaDTO `json:"media,omitempty" validate:"omitempty,dive"`
+	Lang       string      `json:"lang,omitempty" validate:"omitempty,bcp47_language_tag"`
+	Title      string      `json:"title,omitempty" validate:"omitempty,max=254"`
+	Desc       *string     `json:"desc,omitempty" validate:"omitempty,max=500"`
+	LongDesc   *string     `json:"long_desc,omitempty"`
+	Media      *[]MediaDTO `json:"media,omitempty" validate:"omitempty,dive"`
+	Categories *[]string   `json:"categories,omitempty" validate:"omitempty,dive,max=100"`
+	Authors    *[]string   `json:"authors,omitempty" validate:"omitempty,dive,max=254"`
 }
 
 func (dto UpdateArticleDTO) toEntity(id uuid.UUID) *entity.Article {
 	a := &entity.Article{
-		ID:    id,
-		Lang:  dto.Lang,
-		Title: dto.Title,
-		Desc:  dto.Desc,
+		ID:      id,
+		Lang:    dto.Lang,
+		Title:   dto.Title,
+		Desc:    dto.Desc,
+		Authors: dto.Authors,
+	}
+
+	if dto.Categories != nil {
+		categories := make([]string, 0, len(*dto.Categories))
+		seen := make(map[string]struct{}, len(*dto.Categories))
+		for _, category := range *dto.Categories {
+			category = strings.ToLower(strings.TrimSpace(category))
+			if _, ok := seen[category]; ok || category == "" {
+				continue
+			}
+			seen[category] = struct{}{}
+			categories = append(categories, category)
+		}
+		a.Categories = &categories
+	}
+
+	if dto.LongDesc != nil {
+		a.SetLongDesc(util.SanitizeHTML(*dto.LongDesc))
 	}
 
 	if dto.Media != nil {
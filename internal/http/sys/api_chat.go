@@ -15,6 +15,7 @@ type CreateChatDTO struct {
 	FirstName  string          `json:"first_name,omitempty" validate:"omitempty,max=254"`
 	LastName   string          `json:"last_name,omitempty" validate:"omitempty,max=254"`
 	Broadcast  []string        `json:"broadcast,omitempty" validate:"omitempty,dive,uuid4"`
+	Source     string          `json:"source,omitempty" validate:"omitempty,uuid4"`
 	Blocked    bool            `json:"blocked,omitempty"`
 	Deleted    bool            `json:"deleted,omitempty"`
 }
@@ -25,7 +26,7 @@ func (dto CreateChatDTO) toEntity(id uuid.UUID) *entity.Chat {
 		broadcast[i] = uuid.MustParse(b)
 	}
 
-	return &entity.Chat{
+	chat := &entity.Chat{
 		ID:         id,
 		TelegramID: dto.TelegramID,
 		Type:       dto.Type,
@@ -37,11 +38,17 @@ func (dto CreateChatDTO) toEntity(id uuid.UUID) *entity.Chat {
 		Blocked:    &dto.Blocked,
 		Deleted:    &dto.Deleted,
 	}
+	if dto.Source != "" {
+		chat.SetSource(uuid.MustParse(dto.Source))
+	}
+	return chat
 }
 
 type UpdateChatDTO struct {
 	Broadcast *[]string `json:"broadcast,omitempty" validate:"omitempty,dive,uuid4"`
-	Blocked   *bool     `json:"blocked,omitempty"`
+	// Source is the id of the site the posts of the channel are published as articles of, an empty value unlinks the channel.
+	Source  *string `json:"source,omitempty" validate:"omitempty,len=0|uuid4"`
+	Blocked *bool   `json:"blocked,omitempty"`
 }
 
 func (dto UpdateChatDTO) toEntity(id uuid.UUID) *entity.Chat {
@@ -56,6 +63,13 @@ func (dto UpdateChatDTO) toEntity(id uuid.UUID) *entity.Chat {
 		}
 		m.SetBroadcast(broadcast)
 	}
+	if dto.Source != nil {
+		if *dto.Source == "" {
+			m.SetSource(uuid.Nil)
+		} else {
+			m.SetSource(uuid.MustParse(*dto.Source))
+		}
+	}
 	return m
 }
 
@@ -0,0 +1,24 @@
+package sys
+
+import (
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/internal/http/action"
+	"github.com/rumorsflow/rumors/v2/pkg/repository"
+)
+
+type FilteredArticleActions struct {
+	*action.ListAction[*entity.FilteredArticle, any]
+	*action.TakeAction[*entity.FilteredArticle, any]
+	*action.DeleteAction[*entity.FilteredArticle]
+}
+
+func NewFilteredArticleActions(
+	read repository.ReadRepository[*entity.FilteredArticle],
+	write repository.WriteRepository[*entity.FilteredArticle],
+) *FilteredArticleActions {
+	return &FilteredArticleActions{
+		ListAction:   &action.ListAction[*entity.FilteredArticle, any]{ReadRepository: read},
+		TakeAction:   &action.TakeAction[*entity.FilteredArticle, any]{ReadRepository: read},
+		DeleteAction: &action.DeleteAction[*entity.FilteredArticle]{WriteRepository: write},
+	}
+}
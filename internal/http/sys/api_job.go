@@ -1,11 +1,20 @@
 package sys
 
 import (
+	"context"
+	"errors"
 	"github.com/goccy/go-json"
 	"github.com/google/uuid"
+	"github.com/gowool/wool"
+	"github.com/rumorsflow/rumors/v2/internal/common"
 	"github.com/rumorsflow/rumors/v2/internal/entity"
 	"github.com/rumorsflow/rumors/v2/internal/http/action"
+	"github.com/rumorsflow/rumors/v2/internal/model"
+	"github.com/rumorsflow/rumors/v2/internal/task"
 	"github.com/rumorsflow/rumors/v2/pkg/repository"
+	"github.com/spf13/cast"
+	"go.mongodb.org/mongo-driver/bson"
+	"net/http"
 )
 
 type JobOptionDTO struct {
@@ -21,28 +30,37 @@ func (dto JobOptionDTO) toEntity() entity.JobOption {
 }
 
 type FeedPayloadDTO struct {
-	SiteID string `json:"site_id,omitempty" validate:"required,uuid4"`
-	Link   string `json:"link,omitempty" validate:"required,url"`
+	SiteID  string           `json:"site_id,omitempty" validate:"required,uuid4"`
+	Link    string           `json:"link,omitempty" validate:"required,url"`
+	Workers *int             `json:"workers,omitempty" validate:"omitempty,min=1,max=64"`
+	Extract *bool            `json:"extract,omitempty"`
+	Rules   *ContentRulesDTO `json:"rules,omitempty" validate:"omitempty"`
 }
 
 func (dto FeedPayloadDTO) toEntity() *entity.FeedPayload {
 	siteID, _ := uuid.Parse(dto.SiteID)
 
 	return &entity.FeedPayload{
-		SiteID: siteID,
-		Link:   dto.Link,
+		SiteID:  siteID,
+		Link:    dto.Link,
+		Workers: dto.Workers,
+		Extract: dto.Extract,
+		Rules:   dto.Rules.toEntity(),
 	}
 }
 
 type SitemapPayloadDTO struct {
-	SiteID     string  `json:"site_id,omitempty" validate:"required,uuid4"`
-	Link       string  `json:"link,omitempty" validate:"required,url"`
-	Lang       *string `json:"lang,omitempty" validate:"omitempty,bcp47_language_tag"`
-	MatchLoc   *string `json:"match_loc,omitempty" validate:"omitempty,max=500"`
-	SearchLoc  *string `json:"search_loc,omitempty" validate:"omitempty,max=500"`
-	SearchLink *string `json:"search_link,omitempty" validate:"omitempty,max=500"`
-	Index      *bool   `json:"index,omitempty"`
-	StopOnDup  *bool   `json:"stop_on_dup,omitempty"`
+	SiteID     string           `json:"site_id,omitempty" validate:"required,uuid4"`
+	Link       string           `json:"link,omitempty" validate:"required,url"`
+	Lang       *string          `json:"lang,omitempty" validate:"omitempty,bcp47_language_tag"`
+	MatchLoc   *string          `json:"match_loc,omitempty" validate:"omitempty,max=500"`
+	SearchLoc  *string          `json:"search_loc,omitempty" validate:"omitempty,max=500"`
+	SearchLink *string          `json:"search_link,omitempty" validate:"omitempty,max=500"`
+	Index      *bool            `json:"index,omitempty"`
+	StopOnDup  *bool            `json:"stop_on_dup,omitempty"`
+	Workers    *int             `json:"workers,omitempty" validate:"omitempty,min=1,max=64"`
+	Extract    *bool            `json:"extract,omitempty"`
+	Rules      *ContentRulesDTO `json:"rules,omitempty" validate:"omitempty"`
 }
 
 func (dto SitemapPayloadDTO) toEntity() *entity.SitemapPayload {
@@ -57,9 +75,155 @@ func (dto SitemapPayloadDTO) toEntity() *entity.SitemapPayload {
 		SearchLink: dto.SearchLink,
 		Index:      dto.Index,
 		StopOnDup:  dto.StopOnDup,
+		Workers:    dto.Workers,
+		Extract:    dto.Extract,
+		Rules:      dto.Rules.toEntity(),
 	}
 }
 
+type HTMLPayloadDTO struct {
+	SiteID        string           `json:"site_id,omitempty" validate:"required,uuid4"`
+	Link          string           `json:"link,omitempty" validate:"required,url"`
+	Lang          *string          `json:"lang,omitempty" validate:"omitempty,bcp47_language_tag"`
+	ItemSelector  string           `json:"item_selector,omitempty" validate:"required,max=500"`
+	LinkSelector  *string          `json:"link_selector,omitempty" validate:"omitempty,max=500"`
+	TitleSelector *string          `json:"title_selector,omitempty" validate:"omitempty,max=500"`
+	DateSelector  *string          `json:"date_selector,omitempty" validate:"omitempty,max=500"`
+	DateFormat    *string          `json:"date_format,omitempty" validate:"omitempty,max=100"`
+	ImageSelector *string          `json:"image_selector,omitempty" validate:"omitempty,max=500"`
+	NextSelector  *string          `json:"next_selector,omitempty" validate:"omitempty,max=500"`
+	MaxPages      *int             `json:"max_pages,omitempty" validate:"omitempty,min=1,max=20"`
+	Workers       *int             `json:"workers,omitempty" validate:"omitempty,min=1,max=64"`
+	Extract       *bool            `json:"extract,omitempty"`
+	Rules         *ContentRulesDTO `json:"rules,omitempty" validate:"omitempty"`
+}
+
+func (dto HTMLPayloadDTO) toEntity() *entity.HTMLPayload {
+	siteID, _ := uuid.Parse(dto.SiteID)
+
+	return &entity.HTMLPayload{
+		SiteID:        siteID,
+		Link:          dto.Link,
+		Lang:          dto.Lang,
+		ItemSelector:  dto.ItemSelector,
+		LinkSelector:  dto.LinkSelector,
+		TitleSelector: dto.TitleSelector,
+		DateSelector:  dto.DateSelector,
+		DateFormat:    dto.DateFormat,
+		ImageSelector: dto.ImageSelector,
+		NextSelector:  dto.NextSelector,
+		MaxPages:      dto.MaxPages,
+		Workers:       dto.Workers,
+		Extract:       dto.Extract,
+		Rules:         dto.Rules.toEntity(),
+	}
+}
+
+type JSONMappingDTO struct {
+	Items      string `json:"items,omitempty" validate:"omitempty,max=254"`
+	Link       string `json:"link,omitempty" validate:"required,max=254"`
+	Title      string `json:"title,omitempty" validate:"omitempty,max=254"`
+	Desc       string `json:"desc,omitempty" validate:"omitempty,max=254"`
+	Date       string `json:"date,omitempty" validate:"omitempty,max=254"`
+	DateFormat string `json:"date_format,omitempty" validate:"omitempty,max=100"`
+	Image      string `json:"image,omitempty" validate:"omitempty,max=254"`
+	Categories string `json:"categories,omitempty" validate:"omitempty,max=254"`
+}
+
+func (dto JSONMappingDTO) toEntity() entity.JSONMapping {
+	return entity.JSONMapping{
+		Items:      dto.Items,
+		Link:       dto.Link,
+		Title:      dto.Title,
+		Desc:       dto.Desc,
+		Date:       dto.Date,
+		DateFormat: dto.DateFormat,
+		Image:      dto.Image,
+		Categories: dto.Categories,
+	}
+}
+
+type JSONPayloadDTO struct {
+	SiteID      string            `json:"site_id,omitempty" validate:"required,uuid4"`
+	Link        string            `json:"link,omitempty" validate:"required,url"`
+	Headers     map[string]string `json:"headers,omitempty" validate:"omitempty,max=20,dive,keys,required,max=100,endkeys,max=1000"`
+	Lang        *string           `json:"lang,omitempty" validate:"omitempty,bcp47_language_tag"`
+	Mapping     JSONMappingDTO    `json:"mapping,omitempty" validate:"required"`
+	PageParam   *string           `json:"page_param,omitempty" validate:"omitempty,max=100"`
+	CursorParam *string           `json:"cursor_param,omitempty" validate:"omitempty,max=100"`
+	CursorPath  *string           `json:"cursor_path,omitempty" validate:"required_with=CursorParam,omitempty,max=254"`
+	MaxPages    *int              `json:"max_pages,omitempty" validate:"omitempty,min=1,max=20"`
+	Workers     *int              `json:"workers,omitempty" validate:"omitempty,min=1,max=64"`
+	Extract     *bool             `json:"extract,omitempty"`
+	Rules       *ContentRulesDTO  `json:"rules,omitempty" validate:"omitempty"`
+}
+
+func (dto JSONPayloadDTO) toEntity() *entity.JSONPayload {
+	siteID, _ := uuid.Parse(dto.SiteID)
+
+	return &entity.JSONPayload{
+		SiteID:      siteID,
+		Link:        dto.Link,
+		Headers:     dto.Headers,
+		Lang:        dto.Lang,
+		Mapping:     dto.Mapping.toEntity(),
+		PageParam:   dto.PageParam,
+		CursorParam: dto.CursorParam,
+		CursorPath:  dto.CursorPath,
+		MaxPages:    dto.MaxPages,
+		Workers:     dto.Workers,
+		Extract:     dto.Extract,
+		Rules:       dto.Rules.toEntity(),
+	}
+}
+
+type ActivityPubPayloadDTO struct {
+	SiteID   string           `json:"site_id,omitempty" validate:"required,uuid4"`
+	Actor    string           `json:"actor,omitempty" validate:"required,max=254"`
+	Lang     *string          `json:"lang,omitempty" validate:"omitempty,bcp47_language_tag"`
+	MaxPages *int             `json:"max_pages,omitempty" validate:"omitempty,min=1,max=20"`
+	Workers  *int             `json:"workers,omitempty" validate:"omitempty,min=1,max=64"`
+	Extract  *bool            `json:"extract,omitempty"`
+	Rules    *ContentRulesDTO `json:"rules,omitempty" validate:"omitempty"`
+}
+
+func (dto ActivityPubPayloadDTO) toEntity() *entity.ActivityPubPayload {
+	siteID, _ := uuid.Parse(dto.SiteID)
+
+	return &entity.ActivityPubPayload{
+		SiteID:   siteID,
+		Actor:    dto.Actor,
+		Lang:     dto.Lang,
+		MaxPages: dto.MaxPages,
+		Workers:  dto.Workers,
+		Extract:  dto.Extract,
+		Rules:    dto.Rules.toEntity(),
+	}
+}
+
+// checkPayload checks the parts of the payload the validator can not.
+func checkPayload(payload any) error {
+	switch p := payload.(type) {
+	case *FeedPayloadDTO:
+		return p.Rules.check()
+	case *SitemapPayloadDTO:
+		return p.Rules.check()
+	case *HTMLPayloadDTO:
+		if err := task.CheckSelectors(p.toEntity()); err != nil {
+			return wool.NewErrBadRequest(err)
+		}
+		return p.Rules.check()
+	case *JSONPayloadDTO:
+		return p.Rules.check()
+	case *ActivityPubPayloadDTO:
+		if err := task.CheckActor(p.toEntity()); err != nil {
+			return wool.NewErrBadRequest(err)
+		}
+		return p.Rules.check()
+	}
+	return nil
+}
+
 type CreateJobDTO struct {
 	CronExpr string         `json:"cron_expr,omitempty" validate:"required,min=9,max=254"`
 	Name     entity.JobName `json:"name,omitempty" validate:"required,max=254"`
@@ -91,6 +255,12 @@ func (dto *CreateJobDTO) UnmarshalJSON(data []byte) error {
 		dto.Payload = &FeedPayloadDTO{}
 	case entity.JobSitemap:
 		dto.Payload = &SitemapPayloadDTO{}
+	case entity.JobHTML:
+		dto.Payload = &HTMLPayloadDTO{}
+	case entity.JobJSON:
+		dto.Payload = &JSONPayloadDTO{}
+	case entity.JobActivityPub:
+		dto.Payload = &ActivityPubPayloadDTO{}
 	default:
 		return nil
 	}
@@ -120,6 +290,12 @@ func (dto *CreateJobDTO) toEntity(id uuid.UUID) *entity.Job {
 			job.Payload = dto.Payload.(*FeedPayloadDTO).toEntity()
 		case entity.JobSitemap:
 			job.Payload = dto.Payload.(*SitemapPayloadDTO).toEntity()
+		case entity.JobHTML:
+			job.Payload = dto.Payload.(*HTMLPayloadDTO).toEntity()
+		case entity.JobJSON:
+			job.Payload = dto.Payload.(*JSONPayloadDTO).toEntity()
+		case entity.JobActivityPub:
+			job.Payload = dto.Payload.(*ActivityPubPayloadDTO).toEntity()
 		}
 	}
 
@@ -160,6 +336,12 @@ func (dto *UpdateJobDTO) UnmarshalJSON(data []byte) error {
 		dto.Payload = &FeedPayloadDTO{}
 	case entity.JobSitemap:
 		dto.Payload = &SitemapPayloadDTO{}
+	case entity.JobHTML:
+		dto.Payload = &HTMLPayloadDTO{}
+	case entity.JobJSON:
+		dto.Payload = &JSONPayloadDTO{}
+	case entity.JobActivityPub:
+		dto.Payload = &ActivityPubPayloadDTO{}
 	default:
 		return nil
 	}
@@ -181,6 +363,12 @@ func (dto *UpdateJobDTO) toEntity(id uuid.UUID) *entity.Job {
 			job.Payload = dto.Payload.(*FeedPayloadDTO).toEntity()
 		case entity.JobSitemap:
 			job.Payload = dto.Payload.(*SitemapPayloadDTO).toEntity()
+		case entity.JobHTML:
+			job.Payload = dto.Payload.(*HTMLPayloadDTO).toEntity()
+		case entity.JobJSON:
+			job.Payload = dto.Payload.(*JSONPayloadDTO).toEntity()
+		case entity.JobActivityPub:
+			job.Payload = dto.Payload.(*ActivityPubPayloadDTO).toEntity()
 		}
 	}
 
@@ -203,11 +391,154 @@ func NewJobCRUD(read repository.ReadRepository[*entity.Job], write repository.Wr
 		action.NewDTOFactory[*CreateJobDTO](),
 		action.NewDTOFactory[*UpdateJobDTO](),
 		action.RequestMapperFunc[*CreateJobDTO, *entity.Job](func(id uuid.UUID, dto *CreateJobDTO) (*entity.Job, error) {
+			if err := checkPayload(dto.Payload); err != nil {
+				return nil, err
+			}
 			return dto.toEntity(id), nil
 		}),
 		action.RequestMapperFunc[*UpdateJobDTO, *entity.Job](func(id uuid.UUID, dto *UpdateJobDTO) (*entity.Job, error) {
+			if err := checkPayload(dto.Payload); err != nil {
+				return nil, err
+			}
 			return dto.toEntity(id), nil
 		}),
 		nil,
 	)
 }
+
+const maxPreviewLimit = 50
+
+// ErrTasksUnavailable is returned by the actions of the task plugin while it is disabled.
+var ErrTasksUnavailable = errors.New("tasks are not available, the task plugin is disabled")
+
+type JobGroups interface {
+	Latest(ctx context.Context, jobID uuid.UUID) (*model.JobGroup, error)
+}
+
+type JobPreviewer interface {
+	Preview(ctx context.Context, job *entity.Job, limit int) (*model.JobPreview, error)
+}
+
+type JobActions struct {
+	jobRepo   repository.ReadRepository[*entity.Job]
+	client    common.Client
+	previewer JobPreviewer
+	groups    JobGroups
+	runs      *action.ListAction[*entity.JobRun, any]
+}
+
+func NewJobActions(
+	jobRepo repository.ReadRepository[*entity.Job],
+	runRepo repository.ReadRepository[*entity.JobRun],
+	client common.Client,
+	previewer JobPreviewer,
+	groups JobGroups,
+) *JobActions {
+	return &JobActions{
+		jobRepo:   jobRepo,
+		client:    client,
+		previewer: previewer,
+		groups:    groups,
+		runs: &action.ListAction[*entity.JobRun, any]{
+			ReadRepository: runRepo,
+			CriteriaBuilder: func(c wool.Ctx) *repository.Criteria {
+				id, _ := uuid.Parse(c.Req().PathParamID())
+
+				criteria := action.DefaultCriteriaBuilder(c, "job_id")
+				criteria.Filter.(bson.M)["job_id"] = id
+				if criteria.Sort == nil {
+					criteria.Sort = bson.D{{Key: "started_at", Value: -1}}
+				}
+
+				return criteria
+			},
+		},
+	}
+}
+
+func (a *JobActions) Runs(c wool.Ctx) error {
+	if _, err := uuid.Parse(c.Req().PathParamID()); err != nil {
+		return wool.NewErrBadRequest(err, "id param is not valid")
+	}
+
+	return a.runs.List(c)
+}
+
+func (a *JobActions) Group(c wool.Ctx) error {
+	id, err := uuid.Parse(c.Req().PathParamID())
+	if err != nil {
+		return wool.NewErrBadRequest(err, "id param is not valid")
+	}
+
+	group, err := a.groups.Latest(c.Req().Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, group)
+}
+
+func (a *JobActions) Run(c wool.Ctx) error {
+	if a.client == nil {
+		return wool.NewError(http.StatusServiceUnavailable, ErrTasksUnavailable)
+	}
+
+	id, err := uuid.Parse(c.Req().PathParamID())
+	if err != nil {
+		return wool.NewErrBadRequest(err, "id param is not valid")
+	}
+
+	job, err := a.jobRepo.FindByID(c.Req().Context(), id)
+	if err != nil {
+		return err
+	}
+
+	switch p := job.Payload.(type) {
+	case *entity.FeedPayload:
+		p.Force = true
+	case *entity.SitemapPayload:
+		p.Force = true
+	case *entity.HTMLPayload:
+		p.Force = true
+	case *entity.JSONPayload:
+		p.Force = true
+	case *entity.ActivityPubPayload:
+		p.Force = true
+	}
+
+	if err = a.client.Enqueue(c.Req().Context(), string(job.Name), job.TaskPayload(), task.RunOptions(job)...); err != nil {
+		return err
+	}
+
+	return c.NoContent()
+}
+
+func (a *JobActions) Preview(c wool.Ctx) error {
+	if a.previewer == nil {
+		return wool.NewError(http.StatusServiceUnavailable, ErrTasksUnavailable)
+	}
+
+	var dto CreateJobDTO
+	if err := c.Bind(&dto); err != nil {
+		return err
+	}
+
+	if err := checkPayload(dto.Payload); err != nil {
+		return err
+	}
+
+	limit := cast.ToInt(c.Req().URL.Query().Get("limit"))
+	if limit > maxPreviewLimit {
+		limit = maxPreviewLimit
+	}
+
+	preview, err := a.previewer.Preview(c.Req().Context(), dto.toEntity(uuid.New()), limit)
+	if err != nil {
+		if errors.Is(err, task.ErrPreviewNotSupported) {
+			return wool.NewErrBadRequest(err)
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, preview)
+}
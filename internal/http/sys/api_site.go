@@ -1,18 +1,71 @@
 package sys
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
+	"github.com/gowool/wool"
 	"github.com/rumorsflow/rumors/v2/internal/entity"
 	"github.com/rumorsflow/rumors/v2/internal/http/action"
+	"github.com/rumorsflow/rumors/v2/internal/model"
+	"github.com/rumorsflow/rumors/v2/internal/task"
 	"github.com/rumorsflow/rumors/v2/pkg/repository"
+	"net/http"
+	"strings"
+	"time"
 )
 
+type ContentRuleDTO struct {
+	Field   entity.RuleField `json:"field,omitempty" validate:"required,oneof=title desc path category"`
+	Pattern string           `json:"pattern,omitempty" validate:"required,max=500"`
+}
+
+type ContentRulesDTO struct {
+	Include      []ContentRuleDTO `json:"include,omitempty" validate:"omitempty,max=50,dive"`
+	Exclude      []ContentRuleDTO `json:"exclude,omitempty" validate:"omitempty,max=50,dive"`
+	MinDescLen   int              `json:"min_desc_len,omitempty" validate:"omitempty,min=0,max=1000"`
+	KeepFiltered bool             `json:"keep_filtered,omitempty"`
+}
+
+func (dto *ContentRulesDTO) check() error {
+	if dto == nil {
+		return nil
+	}
+
+	for _, rule := range append(dto.Include, dto.Exclude...) {
+		if _, err := task.CompileRule(rule.Pattern); err != nil {
+			return wool.NewErrBadRequest(err, fmt.Sprintf("rule pattern %s is not valid", rule.Pattern))
+		}
+	}
+	return nil
+}
+
+func (dto *ContentRulesDTO) toEntity() *entity.ContentRules {
+	if dto == nil {
+		return nil
+	}
+
+	rules := &entity.ContentRules{MinDescLen: dto.MinDescLen, KeepFiltered: dto.KeepFiltered}
+	for _, rule := range dto.Include {
+		rules.Include = append(rules.Include, entity.ContentRule{Field: rule.Field, Pattern: rule.Pattern})
+	}
+	for _, rule := range dto.Exclude {
+		rules.Exclude = append(rules.Exclude, entity.ContentRule{Field: rule.Field, Pattern: rule.Pattern})
+	}
+	return rules
+}
+
 type CreateSiteDTO struct {
-	Domain    string   `json:"domain,omitempty" validate:"required,fqdn"`
-	Favicon   string   `json:"favicon,omitempty" validate:"required,url"`
-	Languages []string `json:"languages,omitempty" validate:"required,min=1,dive,bcp47_language_tag"`
-	Title     string   `json:"title,omitempty" validate:"required,max=254"`
-	Enabled   bool     `json:"enabled,omitempty"`
+	Domain        string           `json:"domain,omitempty" validate:"required,fqdn"`
+	Favicon       string           `json:"favicon,omitempty" validate:"required,url"`
+	Languages     []string         `json:"languages,omitempty" validate:"required,min=1,dive,bcp47_language_tag"`
+	Title         string           `json:"title,omitempty" validate:"required,max=254"`
+	Enabled       bool             `json:"enabled,omitempty"`
+	RespectRobots bool             `json:"respect_robots,omitempty"`
+	Extract       bool             `json:"extract,omitempty"`
+	Stages        []string         `json:"stages,omitempty" validate:"omitempty,dive,oneof=link metadata text lang media filter tag"`
+	Rules         *ContentRulesDTO `json:"rules,omitempty" validate:"omitempty"`
 }
 
 func (dto CreateSiteDTO) toEntity(id uuid.UUID) *entity.Site {
@@ -22,25 +75,35 @@ func (dto CreateSiteDTO) toEntity(id uuid.UUID) *entity.Site {
 		Favicon:   dto.Favicon,
 		Languages: dto.Languages,
 		Title:     dto.Title,
-	}).SetEnabled(dto.Enabled)
+		Stages:    dto.Stages,
+		Rules:     dto.Rules.toEntity(),
+	}).SetEnabled(dto.Enabled).SetRespectRobots(dto.RespectRobots).SetExtract(dto.Extract)
 }
 
 type UpdateSiteDTO struct {
-	Domain    string   `json:"domain,omitempty" validate:"omitempty,fqdn"`
-	Favicon   string   `json:"favicon,omitempty" validate:"required,url"`
-	Languages []string `json:"languages,omitempty" validate:"omitempty,dive,bcp47_language_tag"`
-	Title     string   `json:"title,omitempty" validate:"omitempty,max=254"`
-	Enabled   *bool    `json:"enabled,omitempty"`
+	Domain        string           `json:"domain,omitempty" validate:"omitempty,fqdn"`
+	Favicon       string           `json:"favicon,omitempty" validate:"required,url"`
+	Languages     []string         `json:"languages,omitempty" validate:"omitempty,dive,bcp47_language_tag"`
+	Title         string           `json:"title,omitempty" validate:"omitempty,max=254"`
+	Enabled       *bool            `json:"enabled,omitempty"`
+	RespectRobots *bool            `json:"respect_robots,omitempty"`
+	Extract       *bool            `json:"extract,omitempty"`
+	Stages        []string         `json:"stages,omitempty" validate:"omitempty,dive,oneof=link metadata text lang media filter tag"`
+	Rules         *ContentRulesDTO `json:"rules,omitempty" validate:"omitempty"`
 }
 
 func (dto UpdateSiteDTO) toEntity(id uuid.UUID) *entity.Site {
 	return &entity.Site{
-		ID:        id,
-		Domain:    dto.Domain,
-		Favicon:   dto.Favicon,
-		Languages: dto.Languages,
-		Title:     dto.Title,
-		Enabled:   dto.Enabled,
+		ID:            id,
+		Domain:        dto.Domain,
+		Favicon:       dto.Favicon,
+		Languages:     dto.Languages,
+		Title:         dto.Title,
+		Enabled:       dto.Enabled,
+		RespectRobots: dto.RespectRobots,
+		Extract:       dto.Extract,
+		Stages:        dto.Stages,
+		Rules:         dto.Rules.toEntity(),
 	}
 }
 
@@ -51,11 +114,159 @@ func NewSiteCRUD(read repository.ReadRepository[*entity.Site], write repository.
 		action.NewDTOFactory[*CreateSiteDTO](),
 		action.NewDTOFactory[*UpdateSiteDTO](),
 		action.RequestMapperFunc[*CreateSiteDTO, *entity.Site](func(id uuid.UUID, dto *CreateSiteDTO) (*entity.Site, error) {
+			if err := task.CheckStages(dto.Stages); err != nil {
+				return nil, wool.NewErrBadRequest(err)
+			}
+			if err := dto.Rules.check(); err != nil {
+				return nil, err
+			}
 			return dto.toEntity(id), nil
 		}),
 		action.RequestMapperFunc[*UpdateSiteDTO, *entity.Site](func(id uuid.UUID, dto *UpdateSiteDTO) (*entity.Site, error) {
+			if err := task.CheckStages(dto.Stages); err != nil {
+				return nil, wool.NewErrBadRequest(err)
+			}
+			if err := dto.Rules.check(); err != nil {
+				return nil, err
+			}
 			return dto.toEntity(id), nil
 		}),
 		nil,
 	)
 }
+
+type DiscoverSiteDTO struct {
+	Domain string `json:"domain,omitempty" validate:"required,max=254"`
+}
+
+type AcceptSiteDTO struct {
+	ID string `json:"id,omitempty" validate:"required,uuid4"`
+	CreateSiteDTO
+}
+
+type AcceptDiscoveryDTO struct {
+	Site AcceptSiteDTO   `json:"site,omitempty" validate:"required"`
+	Jobs []*CreateJobDTO `json:"jobs,omitempty" validate:"omitempty,dive"`
+}
+
+type SiteDiscoverer interface {
+	Discover(ctx context.Context, domain string) (*model.SiteDiscovery, error)
+}
+
+type SiteActions struct {
+	siteRepo   repository.ReadWriteRepository[*entity.Site]
+	jobRepo    repository.WriteRepository[*entity.Job]
+	discoverer SiteDiscoverer
+}
+
+func NewSiteActions(
+	siteRepo repository.ReadWriteRepository[*entity.Site],
+	jobRepo repository.WriteRepository[*entity.Job],
+	discoverer SiteDiscoverer,
+) *SiteActions {
+	return &SiteActions{siteRepo: siteRepo, jobRepo: jobRepo, discoverer: discoverer}
+}
+
+func (a *SiteActions) Discover(c wool.Ctx) error {
+	if a.discoverer == nil {
+		return wool.NewError(http.StatusServiceUnavailable, ErrTasksUnavailable)
+	}
+
+	var dto DiscoverSiteDTO
+	if err := c.Bind(&dto); err != nil {
+		return err
+	}
+
+	discovery, err := a.discoverer.Discover(c.Req().Context(), dto.Domain)
+	if err != nil {
+		return wool.NewError(http.StatusUnprocessableEntity, err)
+	}
+
+	return c.JSON(http.StatusOK, discovery)
+}
+
+func (a *SiteActions) Accept(c wool.Ctx) error {
+	var dto AcceptDiscoveryDTO
+	if err := c.Bind(&dto); err != nil {
+		return err
+	}
+
+	if err := task.CheckStages(dto.Site.Stages); err != nil {
+		return wool.NewErrBadRequest(err)
+	}
+
+	if err := dto.Site.Rules.check(); err != nil {
+		return err
+	}
+
+	for _, jobDTO := range dto.Jobs {
+		if err := checkPayload(jobDTO.Payload); err != nil {
+			return err
+		}
+	}
+
+	id, _ := uuid.Parse(dto.Site.ID)
+	site := dto.Site.toEntity(id)
+
+	jobs := make([]*entity.Job, len(dto.Jobs))
+	for i, jobDTO := range dto.Jobs {
+		jobs[i] = jobDTO.toEntity(uuid.New())
+
+		switch p := jobs[i].Payload.(type) {
+		case *entity.FeedPayload:
+			p.SiteID = site.ID
+		case *entity.SitemapPayload:
+			p.SiteID = site.ID
+		case *entity.HTMLPayload:
+			p.SiteID = site.ID
+		case *entity.JSONPayload:
+			p.SiteID = site.ID
+		case *entity.ActivityPubPayload:
+			p.SiteID = site.ID
+		}
+	}
+
+	// the site is restored as it was when a job fails to save
+	prev, err := a.siteRepo.FindByID(c.Req().Context(), site.ID)
+	if err != nil && !errors.Is(err, repository.ErrEntityNotFound) {
+		return err
+	}
+
+	if err = a.siteRepo.Save(c.Req().Context(), site); err != nil {
+		return err
+	}
+
+	for i, job := range jobs {
+		if err = a.jobRepo.Save(c.Req().Context(), job); err != nil {
+			a.rollback(site.ID, prev, jobs[:i])
+			return err
+		}
+	}
+
+	location := fmt.Sprintf(
+		"%s://%s%s/%s",
+		c.Req().URL.Scheme,
+		c.Req().Host,
+		strings.TrimSuffix(c.Req().URL.Path, "/discover/accept"),
+		site.ID.String(),
+	)
+
+	return c.Created(location)
+}
+
+// rollback removes the jobs saved by an accept failed halfway, the site is restored to the previous one
+// or removed when the accept created it.
+func (a *SiteActions) rollback(siteID uuid.UUID, prev *entity.Site, jobs []*entity.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, job := range jobs {
+		_ = a.jobRepo.Remove(ctx, job.ID)
+	}
+
+	if prev != nil {
+		_ = a.siteRepo.Save(ctx, prev)
+	} else {
+		_ = a.siteRepo.Remove(ctx, siteID)
+	}
+}
@@ -142,6 +142,42 @@ func nopUpdateSite() {}
 //	@Security		SysAuth
 func nopDeleteSite() {}
 
+//	@Summary		Discover site
+//	@Description	find feeds, sitemaps, languages and favicon of a domain and propose a site with its jobs
+//	@Tags			sites
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		DiscoverSiteDTO		true	"Discover Site DTO"
+//	@Success		200		{object}	model.SiteDiscovery	"OK"
+//	@Failure		400		{object}	wool.Error
+//	@Failure		401		{object}	wool.Error
+//	@Failure		403		{object}	wool.Error
+//	@Failure		422		{object}	wool.Error
+//	@Failure		500		{object}	wool.Error
+//	@Failure		503		{object}	wool.Error
+//	@Router			/sites/discover [post]
+//	@Security		SysAuth
+func nopDiscoverSite() {}
+
+//	@Summary		Accept discovered site
+//	@Description	add the discovered site with its jobs
+//	@Tags			sites
+//	@Accept			json
+//
+//	@Param			request	body		AcceptDiscoveryDTO	true	"Accept Discovery DTO"
+//
+//	@Header			201		{string}	Location			"/sites/{id}"
+//	@Success		201
+//	@Failure		400	{object}	wool.Error
+//	@Failure		401	{object}	wool.Error
+//	@Failure		403	{object}	wool.Error
+//	@Failure		409	{object}	wool.Error
+//	@Failure		422	{object}	wool.Error
+//	@Failure		500	{object}	wool.Error
+//	@Router			/sites/discover/accept [post]
+//	@Security		SysAuth
+func nopAcceptSite() {}
+
 //	@Summary		List chats
 //	@Description	get chats
 //	@Tags			chats
@@ -318,6 +354,76 @@ func nopUpdateJob() {}
 //	@Security		SysAuth
 func nopDeleteJob() {}
 
+//	@Summary		Show job group progress
+//	@Description	get progress of the child tasks enqueued by the last run of the job
+//	@Tags			jobs
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string			true	"Job ID"	Format(uuid)
+//	@Success		200	{object}	model.JobGroup	"OK"
+//	@Failure		400	{object}	wool.Error
+//	@Failure		401	{object}	wool.Error
+//	@Failure		403	{object}	wool.Error
+//	@Failure		404	{object}	wool.Error
+//	@Failure		500	{object}	wool.Error
+//	@Router			/jobs/{id}/group [get]
+//	@Security		SysAuth
+func nopJobGroup() {}
+
+//	@Summary		List job runs
+//	@Description	get run history of the job, newest first
+//	@Tags			jobs
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string			true	"Job ID"		Format(uuid)
+//	@Param			index	query		int				false	"Page Index"	default(0)	minimum(0)
+//	@Param			size	query		int				false	"Page Size"		default(20)	minimum(1)	maximum(100)
+//	@Success		200		{array}		entity.JobRun	"OK"
+//	@Failure		400		{object}	wool.Error
+//	@Failure		401		{object}	wool.Error
+//	@Failure		403		{object}	wool.Error
+//	@Failure		500		{object}	wool.Error
+//	@Router			/jobs/{id}/runs [get]
+//	@Security		SysAuth
+func nopJobRuns() {}
+
+//	@Summary		Run job
+//	@Description	enqueue the job task immediately, the job back-off is ignored
+//	@Tags			jobs
+//	@Accept			json
+//
+//	@Param			id	path	string	true	"Job ID"	Format(uuid)
+//
+//	@Success		204
+//	@Failure		400	{object}	wool.Error
+//	@Failure		401	{object}	wool.Error
+//	@Failure		403	{object}	wool.Error
+//	@Failure		404	{object}	wool.Error
+//	@Failure		500	{object}	wool.Error
+//	@Failure		503	{object}	wool.Error
+//	@Router			/jobs/{id}/run [post]
+//	@Security		SysAuth
+func nopRunJob() {}
+
+//	@Summary		Preview job
+//	@Description	parse the job source without saving or publishing and return the articles it would create
+//	@Tags			jobs
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int					false	"Items Limit"	default(10)	minimum(1)	maximum(50)
+//	@Param			request	body		CreateJobDTO		true	"Create Job DTO"
+//	@Success		200		{object}	model.JobPreview	"OK"
+//	@Failure		400		{object}	wool.Error
+//	@Failure		401		{object}	wool.Error
+//	@Failure		403		{object}	wool.Error
+//	@Failure		404		{object}	wool.Error
+//	@Failure		422		{object}	wool.Error
+//	@Failure		500		{object}	wool.Error
+//	@Failure		503		{object}	wool.Error
+//	@Router			/jobs/preview [post]
+//	@Security		SysAuth
+func nopPreviewJob() {}
+
 //	@Summary		List articles
 //	@Description	get articles
 //	@Tags			articles
@@ -387,6 +493,55 @@ func nopUpdateArticle() {}
 //	@Security		SysAuth
 func nopDeleteArticle() {}
 
+//	@Summary		List filtered articles
+//	@Description	get the articles rejected by the content rules of the sites and jobs
+//	@Tags			filtered-articles
+//	@Accept			json
+//	@Produce		json
+//	@Param			index	query		int						false	"Page Index"	default(0)	minimum(0)
+//	@Param			size	query		int						false	"Page Size"		default(20)	minimum(1)	maximum(100)
+//	@Success		200		{array}		entity.FilteredArticle	"OK"
+//	@Failure		400		{object}	wool.Error
+//	@Failure		401		{object}	wool.Error
+//	@Failure		403		{object}	wool.Error
+//	@Failure		500		{object}	wool.Error
+//	@Router			/filtered-articles [get]
+//	@Security		SysAuth
+func nopFilteredArticleList() {}
+
+//	@Summary		Show a filtered article
+//	@Description	get filtered article by ID
+//	@Tags			filtered-articles
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string					true	"Filtered Article ID"	Format(uuid)
+//	@Success		200	{object}	entity.FilteredArticle	"OK"
+//	@Failure		400	{object}	wool.Error
+//	@Failure		401	{object}	wool.Error
+//	@Failure		403	{object}	wool.Error
+//	@Failure		404	{object}	wool.Error
+//	@Failure		500	{object}	wool.Error
+//	@Router			/filtered-articles/{id} [get]
+//	@Security		SysAuth
+func nopFilteredArticleByID() {}
+
+//	@Summary		Delete filtered article
+//	@Description	delete filtered article
+//	@Tags			filtered-articles
+//	@Accept			json
+//
+//	@Param			id	path	string	true	"Filtered Article ID"	Format(uuid)
+//
+//	@Success		204
+//	@Failure		400	{object}	wool.Error
+//	@Failure		401	{object}	wool.Error
+//	@Failure		403	{object}	wool.Error
+//	@Failure		404	{object}	wool.Error
+//	@Failure		500	{object}	wool.Error
+//	@Router			/filtered-articles/{id} [delete]
+//	@Security		SysAuth
+func nopDeleteFilteredArticle() {}
+
 //	@Summary		Delete queue
 //	@Description	delete queue
 //	@Tags			queues
@@ -12,16 +12,19 @@ import (
 var uiBuiltIn = true
 
 type Sys struct {
-	Logger         *slog.Logger
-	CfgJWT         *jwt.Config
-	SSE            *SSE
-	AuthActions    *AuthActions
-	QueueActions   *QueueActions
-	ArticleActions *ArticleActions
-	SiteCRUD       action.CRUD
-	ChatCRUD       action.CRUD
-	JobCRUD        action.CRUD
-	DirUI          string
+	Logger          *slog.Logger
+	CfgJWT          *jwt.Config
+	SSE             *SSE
+	AuthActions     *AuthActions
+	QueueActions    *QueueActions
+	ArticleActions  *ArticleActions
+	FilteredActions *FilteredArticleActions
+	SiteCRUD        action.CRUD
+	SiteActions     *SiteActions
+	ChatCRUD        action.CRUD
+	JobCRUD         action.CRUD
+	JobActions      *JobActions
+	DirUI           string
 }
 
 func (s *Sys) Register(mux *wool.Wool) {
@@ -50,10 +53,23 @@ func (s *Sys) Register(mux *wool.Wool) {
 			w.Use(JWTMiddleware(s.CfgJWT, true))
 
 			w.CRUD("/articles", s.ArticleActions)
+			w.CRUD("/filtered-articles", s.FilteredActions)
 			w.CRUD("/sites", s.SiteCRUD)
 			w.CRUD("/chats", s.ChatCRUD)
 			w.CRUD("/jobs", s.JobCRUD)
 
+			w.Group("/sites", func(st *wool.Wool) {
+				st.POST("/discover", s.SiteActions.Discover)
+				st.POST("/discover/accept", s.SiteActions.Accept)
+			})
+
+			w.Group("/jobs", func(j *wool.Wool) {
+				j.POST("/preview", s.JobActions.Preview)
+				j.POST("/:id/run", s.JobActions.Run)
+				j.GET("/:id/runs", s.JobActions.Runs)
+				j.GET("/:id/group", s.JobActions.Group)
+			})
+
 			w.Group("/queues", func(q *wool.Wool) {
 				q.DELETE("/:"+QNameParam, s.QueueActions.Delete)
 				q.POST("/:"+QNameParam+"/pause", s.QueueActions.Pause)
@@ -8,15 +8,20 @@ import (
 )
 
 type Article struct {
-	ID      uuid.UUID `json:"id,omitempty"`
-	SiteID  uuid.UUID `json:"site_id,omitempty"`
-	Lang    string    `json:"lang,omitempty"`
-	Title   string    `json:"title,omitempty"`
-	Desc    string    `json:"desc,omitempty"`
-	Link    string    `json:"link,omitempty"`
-	Image   string    `json:"image,omitempty"`
-	PubDate time.Time `json:"pub_date,omitempty"`
-	PubDiff string    `json:"pub_diff,omitempty"`
+	ID         uuid.UUID `json:"id,omitempty"`
+	SiteID     uuid.UUID `json:"site_id,omitempty"`
+	Lang       string    `json:"lang,omitempty"`
+	Title      string    `json:"title,omitempty"`
+	Desc       string    `json:"desc,omitempty"`
+	LongDesc   string    `json:"long_desc,omitempty"`
+	Link       string    `json:"link,omitempty"`
+	Image      string    `json:"image,omitempty"`
+	Video      string    `json:"video,omitempty"`
+	Audio      string    `json:"audio,omitempty"`
+	Categories []string  `json:"categories,omitempty"`
+	Authors    []string  `json:"authors,omitempty"`
+	PubDate    time.Time `json:"pub_date,omitempty"`
+	PubDiff    string    `json:"pub_diff,omitempty"`
 }
 
 func ArticleFromEntity(e *entity.Article) Article {
@@ -27,6 +32,8 @@ func ArticleFromEntity(e *entity.Article) Article {
 		Title:   e.Title,
 		Link:    e.Link,
 		Image:   e.FirstMedia(entity.ImageType).URL,
+		Video:   e.FirstMedia(entity.VideoType).URL,
+		Audio:   e.FirstMedia(entity.AudioType).URL,
 		PubDate: e.CreatedAt,
 		PubDiff: timediff.TimeDiff(e.CreatedAt, timediff.WithStartTime(time.Now().UTC())),
 	}
@@ -35,5 +42,32 @@ func ArticleFromEntity(e *entity.Article) Article {
 		a.Desc = *e.Desc
 	}
 
+	if e.LongDesc != nil {
+		a.LongDesc = *e.LongDesc
+	}
+
+	if e.Categories != nil {
+		a.Categories = *e.Categories
+	}
+
+	if e.Authors != nil {
+		a.Authors = *e.Authors
+	}
+
 	return a
 }
+
+type ArticleUpdate struct {
+	Article   Article  `json:"article"`
+	PrevTitle string   `json:"prev_title,omitempty"`
+	Changes   []string `json:"changes"`
+}
+
+func (u ArticleUpdate) Changed(field string) bool {
+	for _, c := range u.Changes {
+		if c == field {
+			return true
+		}
+	}
+	return false
+}
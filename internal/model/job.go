@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+type JobGroup struct {
+	ID        string    `json:"id"`
+	JobID     string    `json:"job_id,omitempty"`
+	Total     int       `json:"total"`
+	Skipped   int       `json:"skipped"`
+	Done      int       `json:"done"`
+	Failed    int       `json:"failed"`
+	Pending   int       `json:"pending"`
+	Completed bool      `json:"completed"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type JobHealth struct {
+	JobID     string     `json:"job_id"`
+	Name      string     `json:"name,omitempty"`
+	Link      string     `json:"link,omitempty"`
+	Status    string     `json:"status"`
+	Failures  int        `json:"failures,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+}
+
+type JobPreview struct {
+	Link     string            `json:"link"`
+	Status   int               `json:"status,omitempty"`
+	Total    int               `json:"total"`
+	Articles []Article         `json:"articles"`
+	Errors   []JobPreviewError `json:"errors,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+type JobPreviewError struct {
+	Link  string `json:"link"`
+	Error string `json:"error"`
+}
@@ -8,16 +8,18 @@ import (
 type View string
 
 const (
-	ViewAppStart View = "appstart.html"
-	ViewAppStop  View = "appstop.html"
-	ViewArticles View = "articles.html"
-	ViewArticle  View = "article.html"
-	ViewChat     View = "chat.html"
-	ViewSites    View = "sites.html"
-	ViewSub      View = "sub.html"
-	ViewSuccess  View = "success.html"
-	ViewError    View = "error.html"
-	ViewNotFound View = "notfound.html"
+	ViewAppStart       View = "appstart.html"
+	ViewAppStop        View = "appstop.html"
+	ViewArticles       View = "articles.html"
+	ViewArticle        View = "article.html"
+	ViewArticleUpdated View = "articleupdated.html"
+	ViewChat           View = "chat.html"
+	ViewSites          View = "sites.html"
+	ViewSub            View = "sub.html"
+	ViewSuccess        View = "success.html"
+	ViewError          View = "error.html"
+	ViewNotFound       View = "notfound.html"
+	ViewJobHealth      View = "jobhealth.html"
 )
 
 type Render func(view View, data any) (string, error)
@@ -57,6 +59,10 @@ func (m *Message) UnmarshalJSON(data []byte) error {
 		return m.unmarshalData(msg.Data, &map[string][]Article{})
 	case ViewChat:
 		return m.unmarshalData(msg.Data, &entity.Chat{})
+	case ViewJobHealth:
+		return m.unmarshalData(msg.Data, &JobHealth{})
+	case ViewArticleUpdated:
+		return m.unmarshalData(msg.Data, &ArticleUpdate{})
 	case ViewSites, ViewSub:
 		return m.unmarshalData(msg.Data, &[]string{})
 	default:
@@ -0,0 +1,31 @@
+package model
+
+import "github.com/rumorsflow/rumors/v2/internal/entity"
+
+type SiteDiscovery struct {
+	Link     string               `json:"link"`
+	Site     *entity.Site         `json:"site"`
+	Jobs     []*entity.Job        `json:"jobs"`
+	Feeds    []DiscoveredFeed     `json:"feeds,omitempty"`
+	Sitemaps []DiscoveredSitemap  `json:"sitemaps,omitempty"`
+	Errors   []SiteDiscoveryError `json:"errors,omitempty"`
+}
+
+type DiscoveredFeed struct {
+	Link  string `json:"link"`
+	Type  string `json:"type,omitempty"`
+	Title string `json:"title,omitempty"`
+	Lang  string `json:"lang,omitempty"`
+	Items int    `json:"items"`
+}
+
+type DiscoveredSitemap struct {
+	Link  string `json:"link"`
+	Index bool   `json:"index"`
+	News  bool   `json:"news"`
+}
+
+type SiteDiscoveryError struct {
+	Link  string `json:"link"`
+	Error string `json:"error"`
+}
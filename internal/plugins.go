@@ -5,6 +5,7 @@ import (
 	"github.com/rumorsflow/rumors/v2/internal/http"
 	"github.com/rumorsflow/rumors/v2/internal/pubsub"
 	"github.com/rumorsflow/rumors/v2/internal/rdb"
+	"github.com/rumorsflow/rumors/v2/internal/smtp"
 	"github.com/rumorsflow/rumors/v2/internal/task"
 	"github.com/rumorsflow/rumors/v2/internal/telegram"
 )
@@ -17,5 +18,6 @@ func Plugins() []any {
 		&telegram.Plugin{},
 		&task.Plugin{},
 		&http.Plugin{},
+		&smtp.Plugin{},
 	}
 }
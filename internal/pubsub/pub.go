@@ -11,9 +11,10 @@ import (
 )
 
 const (
-	ChannelPrefix   = "rumors.event."
-	ChannelArticles = ChannelPrefix + "articles"
-	ChannelTg       = ChannelPrefix + "telegram"
+	ChannelPrefix         = "rumors.event."
+	ChannelArticles       = ChannelPrefix + "articles"
+	ChannelArticleUpdated = ChannelPrefix + "article.updated"
+	ChannelTg             = ChannelPrefix + "telegram"
 
 	OpMarshal = "pubsub: marshal"
 	OpPublish = "pubsub: publish"
@@ -46,6 +47,12 @@ func (p *Publisher) Articles(ctx context.Context, articles []model.Article) {
 	}
 }
 
+func (p *Publisher) ArticleUpdated(ctx context.Context, update model.ArticleUpdate) {
+	if err := p.publish(ctx, ChannelArticleUpdated, update); err != nil {
+		p.error("error due to publish article update", ChannelArticleUpdated, err)
+	}
+}
+
 func (p *Publisher) publish(ctx context.Context, channel string, message any) (err error) {
 	switch message.(type) {
 	case string, []byte:
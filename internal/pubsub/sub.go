@@ -35,6 +35,10 @@ func (s *Subscriber) Articles(ctx context.Context) *redis.PubSub {
 	return s.subscribe(ctx, ChannelArticles)
 }
 
+func (s *Subscriber) ArticleUpdated(ctx context.Context) *redis.PubSub {
+	return s.subscribe(ctx, ChannelArticleUpdated)
+}
+
 func (s *Subscriber) subscribe(ctx context.Context, channels ...string) *redis.PubSub {
 	s.mu.Lock()
 	defer s.mu.Unlock()
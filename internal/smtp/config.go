@@ -0,0 +1,157 @@
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// DefaultRelays are the hosts trusted to hand over the mail, the MTA of the domain runs on the same host.
+var DefaultRelays = []string{"127.0.0.0/8", "::1"}
+
+type Config struct {
+	Address       string        `mapstructure:"address"`
+	Domain        string        `mapstructure:"domain"`
+	MaxSize       int64         `mapstructure:"max_size"`
+	MaxRecipients int           `mapstructure:"max_recipients"`
+	Timeout       time.Duration `mapstructure:"timeout"`
+	Recipients    []Recipient   `mapstructure:"recipients"`
+}
+
+// Recipient maps an address to the site its newsletters are published as articles of,
+// either a newsletter becomes an article or each story linked by it does.
+// The mail is accepted from the relays only, the IPs or CIDRs of the MTAs of the domain,
+// and from the senders only when they are listed, the addresses or the domains of the From header.
+type Recipient struct {
+	Address string   `mapstructure:"address"`
+	Site    string   `mapstructure:"site"`
+	Stories bool     `mapstructure:"stories"`
+	Senders []string `mapstructure:"senders"`
+	Relays  []string `mapstructure:"relays"`
+
+	siteID uuid.UUID
+	relays []netip.Prefix
+}
+
+// trusts reports whether the mail of the recipient may be handed over by the host.
+func (r Recipient) trusts(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	for _, prefix := range r.relays {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// accepts reports whether the recipient takes the mail of the sender, any sender is taken when none is listed.
+func (r Recipient) accepts(sender string) bool {
+	if len(r.Senders) == 0 {
+		return true
+	}
+
+	sender = strings.ToLower(strings.TrimSpace(sender))
+	_, domain, ok := strings.Cut(sender, "@")
+	if !ok {
+		return false
+	}
+
+	for _, s := range r.Senders {
+		if s == sender || s == domain || strings.HasSuffix(domain, "."+s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cfg *Config) Init() error {
+	if cfg.Address == "" {
+		cfg.Address = "127.0.0.1:2525"
+	}
+
+	if cfg.Domain == "" {
+		cfg.Domain = "localhost"
+	}
+
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 10 << 20
+	}
+
+	if cfg.MaxRecipients <= 0 {
+		cfg.MaxRecipients = 100
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Minute
+	}
+
+	if len(cfg.Recipients) == 0 {
+		return errors.New("smtp recipients are required")
+	}
+
+	for i, r := range cfg.Recipients {
+		id, err := uuid.Parse(r.Site)
+		if err != nil {
+			return fmt.Errorf("smtp recipient %s site is not valid: %w", r.Address, err)
+		}
+		cfg.Recipients[i].Address = strings.ToLower(strings.TrimSpace(r.Address))
+		cfg.Recipients[i].siteID = id
+
+		for j, sender := range r.Senders {
+			cfg.Recipients[i].Senders[j] = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(sender)), "@")
+		}
+
+		relays := r.Relays
+		if len(relays) == 0 {
+			relays = DefaultRelays
+		}
+
+		for _, relay := range relays {
+			prefix, err := parseRelay(relay)
+			if err != nil {
+				return fmt.Errorf("smtp recipient %s relay %s is not valid: %w", r.Address, relay, err)
+			}
+			cfg.Recipients[i].relays = append(cfg.Recipients[i].relays, prefix)
+		}
+	}
+
+	return nil
+}
+
+// trusted reports whether the host may hand over the mail of any recipient.
+func (cfg *Config) trusted(addr netip.Addr) bool {
+	for _, r := range cfg.Recipients {
+		if r.trusts(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cfg *Config) recipient(address string) (Recipient, bool) {
+	address = strings.ToLower(strings.TrimSpace(address))
+	for _, r := range cfg.Recipients {
+		if r.Address == address {
+			return r, true
+		}
+	}
+	return Recipient{}, false
+}
+
+func parseRelay(relay string) (netip.Prefix, error) {
+	relay = strings.TrimSpace(relay)
+	if strings.Contains(relay, "/") {
+		prefix, err := netip.ParsePrefix(relay)
+		return prefix.Masked(), err
+	}
+
+	addr, err := netip.ParseAddr(relay)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap().WithZone("")
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
@@ -0,0 +1,52 @@
+package smtp
+
+import (
+	"context"
+	"github.com/roadrunner-server/errors"
+	"github.com/rumorsflow/rumors/v2/internal/common"
+	"github.com/rumorsflow/rumors/v2/pkg/config"
+	"github.com/rumorsflow/rumors/v2/pkg/logger"
+)
+
+const PluginName = "smtp"
+
+type Plugin struct {
+	server *Server
+}
+
+func (p *Plugin) Init(cfg config.Configurer, client common.Client, log logger.Logger) error {
+	const op = errors.Op("smtp_plugin_init")
+
+	if !cfg.Has(PluginName) {
+		return errors.E(op, errors.Disabled)
+	}
+
+	var c Config
+	if err := cfg.UnmarshalKey(PluginName, &c); err != nil {
+		return errors.E(op, err)
+	}
+
+	if err := c.Init(); err != nil {
+		return errors.E(op, err)
+	}
+
+	p.server = NewServer(&c, client, log.NamedLogger(PluginName))
+
+	return nil
+}
+
+func (p *Plugin) Serve() chan error {
+	errCh := make(chan error, 1)
+
+	p.server.Start(errCh)
+
+	return errCh
+}
+
+func (p *Plugin) Stop(ctx context.Context) error {
+	return p.server.Stop(ctx)
+}
+
+func (p *Plugin) Name() string {
+	return PluginName
+}
@@ -0,0 +1,429 @@
+package smtp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/hibiken/asynq"
+	"github.com/rumorsflow/rumors/v2/internal/common"
+	"github.com/rumorsflow/rumors/v2/internal/task"
+	"golang.org/x/exp/slog"
+	"io"
+	"net"
+	"net/mail"
+	"net/netip"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OpServerStart = "smtp.server: start ->"
+
+	maxLineSize = 4096
+
+	// retryRetention keeps the newsletter tasks, and so their IDs, while the senders retry the messages
+	retryRetention = 5 * 24 * time.Hour
+)
+
+// Server is a minimal SMTP listener accepting the mail of the configured recipients only,
+// it neither relays nor authenticates, so it must sit behind the MTA of the domain: the MTA checks
+// the senders (SPF, DKIM, DMARC) and hands the mail over from the relays trusted by the recipients.
+type Server struct {
+	cfg    *Config
+	client common.Client
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	ln    net.Listener
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+func NewServer(cfg *Config, client common.Client, logger *slog.Logger) *Server {
+	return &Server{
+		cfg:    cfg,
+		client: client,
+		logger: logger,
+		conns:  make(map[net.Conn]struct{}),
+	}
+}
+
+func (s *Server) Start(errCh chan<- error) {
+	ln, err := net.Listen("tcp", s.cfg.Address)
+	if err != nil {
+		errCh <- fmt.Errorf("%s %w", OpServerStart, err)
+		return
+	}
+
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+
+	s.logger.Info("smtp server started", "address", ln.Addr().String())
+
+	go s.serve(ln, errCh)
+}
+
+func (s *Server) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if s.ln != nil {
+		_ = s.ln.Close()
+	}
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) serve(ln net.Listener, errCh chan<- error) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+
+			errCh <- fmt.Errorf("%s %w", OpServerStart, err)
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+
+				_ = conn.Close()
+				s.wg.Done()
+			}()
+
+			s.handle(conn)
+		}()
+	}
+}
+
+type session struct {
+	srv   *Server
+	conn  net.Conn
+	addr  netip.Addr
+	limit *io.LimitedReader
+	r     *textproto.Reader
+	w     *textproto.Writer
+	from  string
+	mail  bool
+	rcpts []Recipient
+}
+
+func (s *Server) handle(conn net.Conn) {
+	limit := &io.LimitedReader{R: conn}
+	sess := &session{
+		srv:   s,
+		conn:  conn,
+		limit: limit,
+		r:     textproto.NewReader(bufio.NewReader(limit)),
+		w:     textproto.NewWriter(bufio.NewWriter(conn)),
+	}
+
+	if addr, err := netip.ParseAddrPort(conn.RemoteAddr().String()); err == nil {
+		sess.addr = addr.Addr()
+	}
+
+	sess.reply(220, s.cfg.Domain+" ESMTP ready")
+
+	for {
+		_ = conn.SetDeadline(time.Now().Add(s.cfg.Timeout))
+		limit.N = maxLineSize
+
+		line, err := sess.r.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			sess.reset()
+			sess.reply(250, s.cfg.Domain)
+		case "EHLO":
+			sess.reset()
+			sess.reply(250, s.cfg.Domain, "SIZE "+strconv.FormatInt(s.cfg.MaxSize, 10), "8BITMIME")
+		case "MAIL":
+			sess.mailFrom(arg)
+		case "RCPT":
+			sess.rcptTo(arg)
+		case "DATA":
+			if !sess.data() {
+				return
+			}
+		case "RSET":
+			sess.reset()
+			sess.reply(250, "2.0.0 OK")
+		case "NOOP":
+			sess.reply(250, "2.0.0 OK")
+		case "VRFY":
+			sess.reply(252, "2.5.0 Cannot verify the user")
+		case "QUIT":
+			sess.reply(221, "2.0.0 Bye")
+			return
+		default:
+			sess.reply(502, "5.5.2 Command not recognized")
+		}
+	}
+}
+
+func (sess *session) reset() {
+	sess.from = ""
+	sess.mail = false
+	sess.rcpts = nil
+}
+
+func (sess *session) reply(code int, lines ...string) {
+	for i, line := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+		_ = sess.w.PrintfLine("%d%s%s", code, sep, line)
+	}
+}
+
+func (sess *session) mailFrom(arg string) {
+	from, params, ok := path(arg, "FROM:")
+	if !ok {
+		sess.reply(501, "5.5.4 Syntax: MAIL FROM:<address>")
+		return
+	}
+
+	for _, param := range strings.Fields(params) {
+		key, value, _ := strings.Cut(param, "=")
+		if strings.EqualFold(key, "SIZE") {
+			if size, err := strconv.ParseInt(value, 10, 64); err == nil && size > sess.srv.cfg.MaxSize {
+				sess.reply(552, "5.3.4 Message size exceeds fixed limit")
+				return
+			}
+		}
+	}
+
+	if !sess.srv.cfg.trusted(sess.addr) {
+		sess.srv.logger.Warn("mail rejected, the relay is not trusted", "relay", sess.addr, "from", from)
+		sess.reply(550, "5.7.1 Relay not trusted")
+		return
+	}
+
+	sess.reset()
+	sess.from = from
+	sess.mail = true
+	sess.reply(250, "2.1.0 OK")
+}
+
+func (sess *session) rcptTo(arg string) {
+	if !sess.mail {
+		sess.reply(503, "5.5.1 Need MAIL command")
+		return
+	}
+
+	address, _, ok := path(arg, "TO:")
+	if !ok {
+		sess.reply(501, "5.5.4 Syntax: RCPT TO:<address>")
+		return
+	}
+
+	if len(sess.rcpts) >= sess.srv.cfg.MaxRecipients {
+		sess.reply(452, "4.5.3 Too many recipients")
+		return
+	}
+
+	r, ok := sess.srv.cfg.recipient(address)
+	if !ok {
+		sess.reply(550, "5.1.1 Mailbox unavailable")
+		return
+	}
+
+	if !r.trusts(sess.addr) {
+		sess.reply(550, "5.7.1 Relay not trusted for the mailbox")
+		return
+	}
+
+	sess.rcpts = append(sess.rcpts, r)
+	sess.reply(250, "2.1.5 OK")
+}
+
+// data reads the message and enqueues it for every recipient, it reports whether the session goes on.
+func (sess *session) data() bool {
+	if len(sess.rcpts) == 0 {
+		sess.reply(503, "5.5.1 Need RCPT command")
+		return true
+	}
+
+	sess.reply(354, "Start mail input; end with <CRLF>.<CRLF>")
+
+	maxSize := sess.srv.cfg.MaxSize
+	sess.limit.N = 2*maxSize + maxLineSize
+
+	dot := sess.r.DotReader()
+
+	data, err := io.ReadAll(io.LimitReader(dot, maxSize+1))
+	if err != nil {
+		return false
+	}
+
+	if int64(len(data)) > maxSize {
+		if _, err = io.Copy(io.Discard, dot); err != nil {
+			return false
+		}
+		sess.reset()
+		sess.reply(552, "5.3.4 Message size exceeds fixed limit")
+		return true
+	}
+
+	from := sender(data)
+
+	rcpts := sess.accepted(from)
+	if len(rcpts) == 0 {
+		sess.srv.logger.Warn("newsletter rejected, the sender is not allowed", "from", from, "envelope_from", sess.from)
+		sess.reset()
+		sess.reply(550, "5.7.1 Sender not allowed")
+		return true
+	}
+	sess.rcpts = rcpts
+
+	ctx, cancel := context.WithTimeout(context.Background(), sess.srv.cfg.Timeout)
+	defer cancel()
+
+	if err = sess.enqueue(ctx, data); err != nil {
+		sess.srv.logger.Error("error due to enqueue newsletter", "err", err, "from", sess.from)
+		sess.reset()
+		sess.reply(451, "4.3.0 Requested action aborted: local error in processing")
+		return true
+	}
+
+	sess.srv.logger.Info("newsletter received", "from", sess.from, "recipients", len(sess.rcpts), "size", len(data))
+
+	sess.reset()
+	sess.reply(250, "2.0.0 OK: queued")
+	return true
+}
+
+// accepted returns the recipients taking the mail of the sender.
+func (sess *session) accepted(from string) []Recipient {
+	rcpts := make([]Recipient, 0, len(sess.rcpts))
+	for _, r := range sess.rcpts {
+		if r.accepts(from) {
+			rcpts = append(rcpts, r)
+		}
+	}
+	return rcpts
+}
+
+// enqueue enqueues the message once per site of the recipients. The task of a site is identified by the message,
+// so when a later site fails and the sender retries the message, the sites enqueued already are not enqueued twice.
+func (sess *session) enqueue(ctx context.Context, data []byte) error {
+	seen := make(map[string]struct{}, len(sess.rcpts))
+	message := messageKey(data)
+
+	for _, r := range sess.rcpts {
+		key := r.siteID.String() + ":" + strconv.FormatBool(r.Stories)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		payload := task.NewsletterPayload{SiteID: r.siteID, Stories: r.Stories, Message: data}
+
+		if err := sess.srv.client.Enqueue(
+			ctx,
+			task.MailNewsletter,
+			payload,
+			asynq.Queue("newsletter"),
+			asynq.TaskID(task.MailNewsletter+":"+message+":"+key),
+			asynq.Retention(retryRetention),
+		); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+			return err
+		}
+	}
+	return nil
+}
+
+// messageKey identifies the message by its Message-ID, by its content when it has none.
+func messageKey(data []byte) string {
+	if msg, err := mail.ReadMessage(bytes.NewReader(data)); err == nil {
+		if id := strings.TrimSpace(msg.Header.Get("Message-ID")); id != "" {
+			data = []byte(id)
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// sender returns the address of the From header of the message, the newsletter is published on behalf of it.
+func sender(data []byte) string {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return ""
+	}
+	return from.Address
+}
+
+// path parses the <address> following the prefix of a MAIL or RCPT argument, the rest are the parameters.
+func path(arg, prefix string) (string, string, bool) {
+	arg = strings.TrimSpace(arg)
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", "", false
+	}
+
+	arg = strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(arg, "<") {
+		return "", "", false
+	}
+
+	end := strings.IndexByte(arg, '>')
+	if end < 0 {
+		return "", "", false
+	}
+
+	address := arg[1:end]
+	// the source route of the path is obsolete, the mailbox follows the colon
+	if i := strings.LastIndexByte(address, ':'); i >= 0 && strings.HasPrefix(address, "@") {
+		address = address[i+1:]
+	}
+
+	return address, strings.TrimSpace(arg[end+1:]), true
+}
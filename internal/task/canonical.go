@@ -0,0 +1,111 @@
+package task
+
+import (
+	"github.com/otiai10/opengraph/v2"
+	"github.com/rumorsflow/rumors/v2/pkg/util"
+	"net"
+	"net/url"
+	"strings"
+)
+
+var DefaultTrackingParams = []string{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"dclid",
+	"msclkid",
+	"yclid",
+	"mc_cid",
+	"mc_eid",
+	"_ga",
+	"_gl",
+	"igshid",
+	"ref_src",
+	"cmpid",
+	"ncid",
+	"ocid",
+	"sr_share",
+	"wt_mc",
+	"at_medium",
+	"at_campaign",
+	"__twitter_impression",
+}
+
+// Canonical returns the link an article is stored under. The final URL after redirects is used,
+// og:url or rel=canonical replaces it when it points to the same site. The scheme is kept
+// unless keep_scheme is disabled, then http links are upgraded to https.
+func (f *Fetcher) Canonical(link string, og *opengraph.OpenGraph) string {
+	final := link
+	if og != nil && og.Intent.URL != "" {
+		final = og.Intent.URL
+	}
+
+	u, err := url.Parse(final)
+	if err != nil {
+		return link
+	}
+
+	if og != nil && og.URL != "" {
+		if c, err := u.Parse(strings.TrimSpace(og.URL)); err == nil && (c.Scheme == "http" || c.Scheme == "https") &&
+			util.SafeDomain(c.String()) == util.SafeDomain(u.String()) {
+			u = c
+		}
+	}
+
+	return f.normalize(u)
+}
+
+func (f *Fetcher) normalize(u *url.URL) string {
+	n := *u
+	n.User = nil
+	n.Fragment = ""
+	n.RawFragment = ""
+
+	n.Scheme = strings.ToLower(n.Scheme)
+	if n.Scheme == "http" && !*f.cfg.Canonical.KeepScheme {
+		n.Scheme = "https"
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(n.Hostname()), ".")
+	if port := n.Port(); port != "" && port != "80" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	n.Host = host
+
+	if n.Path == "" {
+		n.Path = "/"
+	} else if len(n.Path) > 1 {
+		n.Path = strings.TrimSuffix(n.Path, "/")
+		n.RawPath = strings.TrimSuffix(n.RawPath, "/")
+	}
+
+	if n.RawQuery != "" {
+		query := n.Query()
+		for key := range query {
+			if f.tracking(key) {
+				query.Del(key)
+			}
+		}
+		n.RawQuery = query.Encode()
+	}
+	n.ForceQuery = false
+
+	return n.String()
+}
+
+func (f *Fetcher) tracking(param string) bool {
+	param = strings.ToLower(param)
+	for _, p := range f.cfg.Canonical.TrackingParams {
+		p = strings.ToLower(p)
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(param, prefix) {
+				return true
+			}
+		} else if param == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package task
+
+import (
+	"bytes"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	htmlTypes   = []string{"text/html", "application/xhtml+xml"}
+	xmlEncoding = regexp.MustCompile(`(?i)encoding\s*=\s*["']([\w.:-]+)["']`)
+	headEnd     = []byte("</head")
+)
+
+func isHTML(contentType string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	for _, t := range htmlTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// decode transcodes an HTML document to UTF-8. The encoding is taken from the BOM, the Content-Type header,
+// the meta tags or the XML declaration of the head, then the document is checked to be valid UTF-8,
+// windows-1252 is used otherwise.
+func decode(data []byte, contentType string) io.Reader {
+	e, name, certain := charset.DetermineEncoding(data, contentType)
+	if !certain {
+		e, name = encoding.Encoding(encoding.Nop), "utf-8"
+		if label := declaredCharset(data); label != "" {
+			if enc, n := charset.Lookup(label); enc != nil {
+				e, name = enc, n
+			}
+		}
+		if name == "utf-8" && !utf8.Valid(data) {
+			e = charmap.Windows1252
+		}
+	}
+
+	var fallback transform.Transformer = transform.Nop
+	if e != encoding.Nop {
+		fallback = e.NewDecoder()
+	}
+
+	return transform.NewReader(bytes.NewReader(data), unicode.BOMOverride(fallback))
+}
+
+// declaredCharset returns the charset declared by <meta charset>, <meta http-equiv="content-type">
+// or by the XML declaration of an XHTML document.
+func declaredCharset(data []byte) string {
+	z := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.CommentToken:
+			if text := string(z.Text()); strings.HasPrefix(text, "?xml") {
+				if m := xmlEncoding.FindStringSubmatch(text); m != nil {
+					return m[1]
+				}
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "meta":
+				var httpEquiv, content string
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = z.TagAttr()
+					switch string(key) {
+					case "charset":
+						return strings.TrimSpace(string(val))
+					case "http-equiv":
+						httpEquiv = strings.ToLower(strings.TrimSpace(string(val)))
+					case "content":
+						content = string(val)
+					}
+				}
+				if httpEquiv == "content-type" {
+					if _, params, err := mime.ParseMediaType(content); err == nil && params["charset"] != "" {
+						return params["charset"]
+					}
+				}
+			case "body":
+				return ""
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "head" {
+				return ""
+			}
+		}
+	}
+}
+
+// headReader stops reading a document after the end of its head.
+type headReader struct {
+	r    io.Reader
+	tail []byte
+	done bool
+}
+
+func (h *headReader) Read(p []byte) (int, error) {
+	if h.done {
+		return 0, io.EOF
+	}
+
+	n, err := h.r.Read(p)
+	if n > 0 {
+		window := bytes.ToLower(append(h.tail, p[:n]...))
+		if bytes.Contains(window, headEnd) {
+			h.done = true
+		} else if len(window) >= len(headEnd) {
+			h.tail = append(h.tail[:0], window[len(window)-len(headEnd)+1:]...)
+		} else {
+			h.tail = window
+		}
+	}
+	return n, err
+}
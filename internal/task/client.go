@@ -51,6 +51,15 @@ func (c *Client) EnqueueTgMemberEdit(ctx context.Context, member *tgbotapi.ChatM
 	}
 }
 
+func (c *Client) EnqueueTgPost(ctx context.Context, message *tgbotapi.Message, updateID int) {
+	name := TelegramPost
+	taskID := asynq.TaskID(fmt.Sprintf("%s:%d", name, updateID))
+
+	if err := c.Enqueue(ctx, name, message, taskID, asynq.Queue("tgpost")); err != nil {
+		c.logger.Error("error due to enqueue channel post", "err", err, "option_task_id", taskID, "message", message)
+	}
+}
+
 func (c *Client) Enqueue(ctx context.Context, name string, data any, opts ...asynq.Option) error {
 	if err := c.enqueue(ctx, name, data, opts...); err != nil {
 		return fmt.Errorf("%s error: %w", OpClientEnqueue, err)
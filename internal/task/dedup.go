@@ -0,0 +1,161 @@
+package task
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/pkg/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"strings"
+	"time"
+)
+
+const seenPrefix = "rumors:seen:"
+
+// Dedup keeps the identities of already processed items in a rotating bloom filter per job.
+// An item is seen while it is present in the current or the previous generation.
+type Dedup struct {
+	cfg *DedupConfig
+	rdb redis.UniversalClient
+}
+
+func NewDedup(cfg *DedupConfig, rdb redis.UniversalClient) *Dedup {
+	return &Dedup{cfg: cfg, rdb: rdb}
+}
+
+func (d *Dedup) Has(ctx context.Context, scope string, ids []string) ([]bool, error) {
+	seen := make([]bool, len(ids))
+	if d == nil || len(ids) == 0 {
+		return seen, nil
+	}
+
+	current, previous := d.keys(scope)
+
+	cmds := make([][2][]*redis.IntCmd, len(ids))
+
+	if _, err := d.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			for _, offset := range d.offsets(id) {
+				cmds[i][0] = append(cmds[i][0], pipe.GetBit(ctx, current, offset))
+				cmds[i][1] = append(cmds[i][1], pipe.GetBit(ctx, previous, offset))
+			}
+		}
+		return nil
+	}); err != nil {
+		return seen, fmt.Errorf("%s has %s error: %w", OpDedup, scope, err)
+	}
+
+	for i := range ids {
+		seen[i] = allSet(cmds[i][0]) || allSet(cmds[i][1])
+	}
+
+	return seen, nil
+}
+
+func (d *Dedup) Add(ctx context.Context, scope string, ids ...string) error {
+	if d == nil || len(ids) == 0 {
+		return nil
+	}
+
+	current, _ := d.keys(scope)
+
+	if _, err := d.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range ids {
+			for _, offset := range d.offsets(id) {
+				pipe.SetBit(ctx, current, offset, 1)
+			}
+		}
+		pipe.Expire(ctx, current, 2*d.cfg.Rotate)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("%s add %s error: %w", OpDedup, scope, err)
+	}
+
+	return nil
+}
+
+func (d *Dedup) HashWindow() time.Duration {
+	if d == nil {
+		return 0
+	}
+	return d.cfg.HashWindow
+}
+
+func (d *Dedup) UpdateWindow() time.Duration {
+	if d == nil {
+		return 0
+	}
+	return d.cfg.UpdateWindow
+}
+
+func (d *Dedup) keys(scope string) (string, string) {
+	gen := time.Now().UnixNano() / int64(d.cfg.Rotate)
+	return fmt.Sprintf("%s%s:%d", seenPrefix, scope, gen), fmt.Sprintf("%s%s:%d", seenPrefix, scope, gen-1)
+}
+
+func (d *Dedup) offsets(id string) []int64 {
+	sum := sha256.Sum256([]byte(id))
+	h1 := binary.BigEndian.Uint64(sum[:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+
+	offsets := make([]int64, d.cfg.Hashes)
+	for i := range offsets {
+		offsets[i] = int64((h1 + uint64(i)*h2) % d.cfg.Bits)
+	}
+	return offsets
+}
+
+func allSet(cmds []*redis.IntCmd) bool {
+	for _, cmd := range cmds {
+		if cmd.Val() != 1 {
+			return false
+		}
+	}
+	return len(cmds) > 0
+}
+
+func dedupScope(jobID *uuid.UUID, siteID uuid.UUID) string {
+	if jobID != nil {
+		return jobID.String()
+	}
+	return siteID.String()
+}
+
+// contentHash identifies an article by its site and title, it catches the same story published under another link.
+func contentHash(siteID uuid.UUID, title string) string {
+	return checksum([]byte(siteID.String() + "\n" + strings.ToLower(strings.Join(strings.Fields(title), " "))))
+}
+
+// findExisting looks the stored articles up by the links and their canonical forms,
+// the result is keyed by both the link and the original link of an article.
+func findExisting(ctx context.Context, repo repository.ReadRepository[*entity.Article], fetcher *Fetcher, links []string) (map[string]*entity.Article, error) {
+	all := make([]string, 0, 2*len(links))
+	for _, link := range links {
+		all = append(all, link)
+		if canonical := fetcher.Canonical(link, nil); canonical != link {
+			all = append(all, canonical)
+		}
+	}
+
+	articles, err := repo.Find(ctx, &repository.Criteria{Filter: bson.M{"$or": bson.A{
+		bson.M{"link": bson.M{"$in": all}},
+		bson.M{"original_link": bson.M{"$in": all}},
+	}}})
+	if err != nil {
+		return nil, fmt.Errorf("%s find existing articles error: %w", OpServerProcessTask, err)
+	}
+
+	existing := make(map[string]*entity.Article, 2*len(articles))
+	for _, article := range articles {
+		existing[article.Link] = article
+		if article.OriginalLink != "" {
+			existing[article.OriginalLink] = article
+		}
+	}
+
+	return existing, nil
+}
@@ -0,0 +1,38 @@
+package task
+
+import "time"
+
+type DedupConfig struct {
+	// Bits is the size of a single bloom filter generation kept for every job.
+	Bits   uint64        `mapstructure:"bits"`
+	Hashes int           `mapstructure:"hashes"`
+	Rotate time.Duration `mapstructure:"rotate"`
+	// HashWindow is how far back an article with the same title on the same site is a duplicate,
+	// a negative value disables the content hash check.
+	HashWindow time.Duration `mapstructure:"hash_window"`
+	// UpdateWindow is how long after publishing an already stored feed item is checked for updates,
+	// a negative value disables update tracking.
+	UpdateWindow time.Duration `mapstructure:"update_window"`
+}
+
+func (cfg *DedupConfig) Init() {
+	if cfg.Bits == 0 {
+		cfg.Bits = 1 << 18
+	}
+
+	if cfg.Hashes <= 0 {
+		cfg.Hashes = 4
+	}
+
+	if cfg.Rotate <= 0 {
+		cfg.Rotate = 7 * 24 * time.Hour
+	}
+
+	if cfg.HashWindow == 0 {
+		cfg.HashWindow = 72 * time.Hour
+	}
+
+	if cfg.UpdateWindow == 0 {
+		cfg.UpdateWindow = 48 * time.Hour
+	}
+}
@@ -0,0 +1,355 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/mmcdole/gofeed"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/internal/model"
+	"github.com/rumorsflow/rumors/v2/pkg/util"
+	"golang.org/x/net/html"
+	"golang.org/x/text/language"
+	"net/url"
+	"strings"
+)
+
+const (
+	DefaultFeedCron    = "*/15 * * * *"
+	DefaultSitemapCron = "*/30 * * * *"
+
+	maxDiscoveryLinks = 10
+)
+
+var (
+	feedTypes     = []string{"application/rss+xml", "application/atom+xml", "application/rdf+xml", "application/feed+json"}
+	sitemapProbes = []string{"/sitemap.xml", "/sitemap_index.xml"}
+)
+
+// Discoverer inspects the homepage and robots.txt of a domain and proposes a site with its jobs.
+type Discoverer struct {
+	fetcher *Fetcher
+}
+
+func NewDiscoverer(fetcher *Fetcher) *Discoverer {
+	return &Discoverer{fetcher: fetcher}
+}
+
+func (d *Discoverer) Discover(ctx context.Context, domain string) (*model.SiteDiscovery, error) {
+	link, err := homepage(domain)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s error: %w", OpDiscover, domain, err)
+	}
+
+	res, err := d.fetcher.Get(ctx, link)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s error: %w", OpDiscover, link, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		return nil, fmt.Errorf("%s error due to request %s with response status code %d", OpDiscover, link, res.StatusCode)
+	}
+
+	body, err := d.fetcher.ReadBody(res)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s error: %w", OpDiscover, link, err)
+	}
+
+	node, err := html.Parse(decode(body, res.Header.Get("Content-Type")))
+	if err != nil {
+		return nil, fmt.Errorf("%s %s error: %w", OpDiscover, link, err)
+	}
+
+	base := res.Request.URL
+	origin := base.Scheme + "://" + base.Host
+
+	doc := &page{base: base}
+	doc.walk(node)
+
+	if doc.favicon == "" {
+		doc.favicon = origin + "/favicon.ico"
+	}
+
+	title := doc.siteName
+	if title == "" {
+		title = doc.title
+	}
+
+	site := (&entity.Site{
+		ID:      uuid.New(),
+		Domain:  util.SafeDomain(base.String()),
+		Favicon: doc.favicon,
+		Title:   util.MaxLen(strings.TrimSpace(title), 254),
+	}).SetEnabled(true).SetRespectRobots(true)
+
+	discovery := &model.SiteDiscovery{Link: base.String(), Site: site}
+
+	probe := false
+	sitemaps := doc.sitemaps
+
+	if data, err := d.fetcher.fetchRobots(ctx, origin+"/robots.txt"); err != nil {
+		discovery.Errors = append(discovery.Errors, model.SiteDiscoveryError{Link: origin + "/robots.txt", Error: err.Error()})
+	} else {
+		sitemaps = append(sitemaps, ParseRobots(strings.NewReader(data), d.fetcher.cfg.Robots.Agent).Sitemaps()...)
+	}
+
+	if len(sitemaps) == 0 {
+		probe = true
+		for _, path := range sitemapProbes {
+			sitemaps = append(sitemaps, origin+path)
+		}
+	}
+
+	for _, f := range unique(doc.feeds) {
+		if feed, err := d.feed(ctx, f); err != nil {
+			discovery.Errors = append(discovery.Errors, model.SiteDiscoveryError{Link: f, Error: err.Error()})
+		} else {
+			discovery.Feeds = append(discovery.Feeds, *feed)
+			doc.lang(feed.Lang)
+		}
+	}
+
+	for _, s := range unique(sitemaps) {
+		if sm, err := d.sitemap(ctx, s); err != nil {
+			if !probe {
+				discovery.Errors = append(discovery.Errors, model.SiteDiscoveryError{Link: s, Error: err.Error()})
+			}
+		} else {
+			discovery.Sitemaps = append(discovery.Sitemaps, *sm)
+		}
+	}
+
+	site.Languages = doc.langs
+	discovery.Jobs = candidates(site, discovery)
+
+	return discovery, nil
+}
+
+func (d *Discoverer) feed(ctx context.Context, link string) (*model.DiscoveredFeed, error) {
+	res, err := d.fetcher.Conditional(ctx, link, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := gofeed.NewParser().Parse(bytes.NewReader(res.body))
+	if err != nil {
+		return nil, fmt.Errorf("%s error: %w", OpServerParseFeed, err)
+	}
+
+	return &model.DiscoveredFeed{
+		Link:  link,
+		Type:  parsed.FeedType,
+		Title: parsed.Title,
+		Lang:  baseLang(parsed.Language),
+		Items: len(parsed.Items),
+	}, nil
+}
+
+func (d *Discoverer) sitemap(ctx context.Context, link string) (*model.DiscoveredSitemap, error) {
+	res, err := d.fetcher.Conditional(ctx, link, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sm := &model.DiscoveredSitemap{Link: link}
+
+	switch {
+	case bytes.Contains(res.body, []byte("<sitemapindex")):
+		sm.Index = true
+		sm.News = strings.Contains(strings.ToLower(link), "news")
+	case bytes.Contains(res.body, []byte("<urlset")):
+		sm.News = bytes.Contains(res.body, []byte("<news:news"))
+	default:
+		return nil, fmt.Errorf("%s %s is not a sitemap", OpServerParseSitemap, link)
+	}
+
+	return sm, nil
+}
+
+// candidates proposes a job for every feed and news sitemap,
+// plain sitemaps are proposed only when the site has neither of them.
+func candidates(site *entity.Site, discovery *model.SiteDiscovery) []*entity.Job {
+	jobs := make([]*entity.Job, 0, len(discovery.Feeds)+len(discovery.Sitemaps))
+
+	for _, f := range discovery.Feeds {
+		jobs = append(jobs, candidate(DefaultFeedCron, entity.JobFeed, &entity.FeedPayload{SiteID: site.ID, Link: f.Link}))
+	}
+
+	for _, plain := range []bool{false, true} {
+		if plain && len(jobs) > 0 {
+			break
+		}
+		for _, s := range discovery.Sitemaps {
+			if s.News == plain {
+				continue
+			}
+			payload := (&entity.SitemapPayload{SiteID: site.ID, Link: s.Link}).SetIndex(s.Index).SetStopOnDup(s.News)
+			jobs = append(jobs, candidate(DefaultSitemapCron, entity.JobSitemap, payload))
+		}
+	}
+
+	return jobs
+}
+
+func candidate(cronExpr string, name entity.JobName, payload any) *entity.Job {
+	return (&entity.Job{
+		ID:       uuid.New(),
+		CronExpr: cronExpr,
+		Name:     name,
+		Payload:  payload,
+	}).SetEnabled(true)
+}
+
+type page struct {
+	base     *url.URL
+	title    string
+	siteName string
+	favicon  string
+	langs    []string
+	feeds    []string
+	sitemaps []string
+}
+
+func (p *page) walk(node *html.Node) {
+	if node.Type == html.ElementNode {
+		switch node.Data {
+		case "html":
+			p.lang(attr(node, "lang"))
+		case "title":
+			if p.title == "" && node.FirstChild != nil {
+				p.title = node.FirstChild.Data
+			}
+		case "meta":
+			p.meta(node)
+		case "link":
+			p.link(node)
+		}
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		p.walk(child)
+	}
+}
+
+func (p *page) meta(node *html.Node) {
+	content := attr(node, "content")
+
+	switch {
+	case attr(node, "property") == "og:site_name":
+		p.siteName = content
+	case attr(node, "property") == "og:locale":
+		p.lang(content)
+	case strings.EqualFold(attr(node, "http-equiv"), "content-language"):
+		for _, lang := range strings.Split(content, ",") {
+			p.lang(lang)
+		}
+	}
+}
+
+func (p *page) link(node *html.Node) {
+	href := p.resolve(attr(node, "href"))
+	if href == "" {
+		return
+	}
+
+	for _, rel := range strings.Fields(strings.ToLower(attr(node, "rel"))) {
+		switch rel {
+		case "alternate":
+			if typ := strings.ToLower(attr(node, "type")); typ != "" {
+				for _, t := range feedTypes {
+					if typ == t && len(p.feeds) < maxDiscoveryLinks {
+						p.feeds = append(p.feeds, href)
+					}
+				}
+			} else if lang := attr(node, "hreflang"); lang != "x-default" {
+				p.lang(lang)
+			}
+		case "icon", "apple-touch-icon":
+			if p.favicon == "" {
+				p.favicon = href
+			}
+		case "sitemap":
+			if len(p.sitemaps) < maxDiscoveryLinks {
+				p.sitemaps = append(p.sitemaps, href)
+			}
+		}
+	}
+}
+
+func (p *page) lang(value string) {
+	if lang := baseLang(value); lang != "" {
+		for _, l := range p.langs {
+			if l == lang {
+				return
+			}
+		}
+		p.langs = append(p.langs, lang)
+	}
+}
+
+func (p *page) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return ""
+	}
+
+	u, err := p.base.Parse(href)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
+
+func attr(node *html.Node, key string) string {
+	for _, a := range node.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+func baseLang(value string) string {
+	tag, err := language.Parse(strings.ReplaceAll(strings.TrimSpace(value), "_", "-"))
+	if err != nil {
+		return ""
+	}
+
+	base, confidence := tag.Base()
+	if confidence == language.No {
+		return ""
+	}
+	return base.String()
+}
+
+func homepage(domain string) (string, error) {
+	domain = strings.TrimSpace(domain)
+	if !strings.Contains(domain, "://") {
+		domain = "https://" + domain
+	}
+
+	u, err := url.Parse(domain)
+	if err != nil {
+		return "", err
+	}
+	if u.Hostname() == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("invalid domain %s", domain)
+	}
+
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}).String(), nil
+}
+
+func unique(links []string) []string {
+	seen := make(map[string]struct{}, len(links))
+	result := make([]string, 0, len(links))
+
+	for _, link := range links {
+		if _, ok := seen[link]; !ok && len(result) < maxDiscoveryLinks {
+			seen[link] = struct{}{}
+			result = append(result, link)
+		}
+	}
+	return result
+}
@@ -0,0 +1,36 @@
+package task
+
+import (
+	"github.com/rumorsflow/rumors/v2/internal/common"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/pkg/repository"
+	"net/http/httptest"
+	"testing"
+)
+
+// FeedFixture is the handler of the feed jobs and the websub of its jobs storing in memory, it lets the tests
+// of the package task_test run the handlers behind the routes of the http plugin.
+type FeedFixture struct {
+	Handler  *HandlerJobFeed
+	WebSub   *WebSub
+	Site     *entity.Site
+	Jobs     repository.ReadWriteRepository[*entity.Job]
+	Articles repository.ReadRepository[*entity.Article]
+}
+
+// NewFeedFixture returns the feed fixture, the fetcher reaches the servers of the test and the client enqueues
+// the tasks of the pushed content.
+func NewFeedFixture(t *testing.T, srv *httptest.Server, cfg *WebSubConfig, client common.Client) *FeedFixture {
+	fetcher := testFetcher(t, srv)
+	lists, site, jobRepo, articleRepo := testListing(fetcher, entity.FeedSource)
+
+	websub := NewWebSub(cfg, fetcher, jobRepo, client, testLogger())
+
+	return &FeedFixture{
+		Handler:  &HandlerJobFeed{listing: lists, websub: websub},
+		WebSub:   websub,
+		Site:     site,
+		Jobs:     jobRepo,
+		Articles: articleRepo,
+	}
+}
@@ -0,0 +1,295 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/pkg/util"
+	"golang.org/x/net/html"
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	wordsPerMinute = 200
+	minContentLen  = 250
+	minParagraph   = 25
+)
+
+var (
+	unlikelyCandidate = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|cookie|disqus|extra|footer|gdpr|header|menu|modal|nav|newsletter|pager|pagination|popup|promo|related|remark|replies|share|shoutbox|sidebar|social|sponsor|subscribe|tags|tool|widget|advert`)
+	maybeCandidate    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveCandidate = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|story|text|blog`)
+	negativeCandidate = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |caption|comment|com-|contact|foot|footer|footnote|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+
+	droppedTags = map[string]struct{}{
+		"script": {}, "style": {}, "noscript": {}, "iframe": {}, "form": {}, "nav": {}, "aside": {}, "footer": {},
+		"header": {}, "svg": {}, "button": {}, "input": {}, "select": {}, "textarea": {}, "template": {}, "object": {},
+		"embed": {}, "canvas": {}, "dialog": {}, "menu": {},
+	}
+	scoredTags = map[string]struct{}{"p": {}, "pre": {}, "td": {}, "blockquote": {}, "li": {}}
+	blockTags  = map[string]struct{}{
+		"p": {}, "div": {}, "section": {}, "article": {}, "br": {}, "li": {}, "pre": {}, "blockquote": {},
+		"h1": {}, "h2": {}, "h3": {}, "h4": {}, "h5": {}, "h6": {}, "tr": {}, "figure": {}, "ul": {}, "ol": {},
+	}
+)
+
+type extractKey struct{}
+
+// WithExtract makes the fetcher read the whole article page and extract its readable content.
+func WithExtract(ctx context.Context) context.Context {
+	return context.WithValue(ctx, extractKey{}, true)
+}
+
+func extracting(ctx context.Context) bool {
+	v, _ := ctx.Value(extractKey{}).(bool)
+	return v
+}
+
+// extractContent finds the node holding the main readable content of the page the way readability does,
+// paragraphs are scored by their length and commas, the scores are propagated to the ancestors and
+// weighted by class names and link density.
+func extractContent(doc *html.Node) *entity.ArticleContent {
+	body := find(doc, "body")
+	if body == nil {
+		return nil
+	}
+
+	prune(body)
+
+	scores := make(map[*html.Node]float64)
+	var candidates []*html.Node
+
+	var score func(*html.Node)
+	score = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			score(child)
+		}
+
+		if node.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scoredTags[node.Data]; !ok {
+			return
+		}
+
+		text := textContent(node)
+		length := utf8.RuneCountInString(text)
+		if length < minParagraph {
+			return
+		}
+
+		points := 1 + float64(strings.Count(text, ",")) + math.Min(float64(length/100), 3)
+
+		for i, ancestor := 0, node.Parent; i < 3 && ancestor != nil && ancestor.Type == html.ElementNode; i, ancestor = i+1, ancestor.Parent {
+			if _, ok := scores[ancestor]; !ok {
+				scores[ancestor] = initialScore(ancestor)
+				candidates = append(candidates, ancestor)
+			}
+			switch i {
+			case 0:
+				scores[ancestor] += points
+			case 1:
+				scores[ancestor] += points / 2
+			default:
+				scores[ancestor] += points / 6
+			}
+		}
+	}
+	score(body)
+
+	var top *html.Node
+	var best float64
+	for _, c := range candidates {
+		s := scores[c] * (1 - linkDensity(c))
+		if top == nil || s > best {
+			top, best = c, s
+		}
+	}
+
+	if top == nil {
+		return nil
+	}
+
+	text := strings.TrimSpace(util.StripNewLine(blockText(top), 2))
+	if utf8.RuneCountInString(text) < minContentLen {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for child := top.FirstChild; child != nil; child = child.NextSibling {
+		_ = html.Render(&buf, child)
+	}
+
+	words := len(strings.Fields(text))
+
+	return &entity.ArticleContent{
+		Text:        text,
+		HTML:        util.SanitizeHTML(buf.String()),
+		WordCount:   words,
+		ReadingTime: int(math.Ceil(float64(words) / wordsPerMinute)),
+	}
+}
+
+// prune removes the elements that never hold the content and the ones unlikely to be a part of it.
+func prune(node *html.Node) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+
+		if child.Type == html.CommentNode {
+			node.RemoveChild(child)
+		} else if child.Type == html.ElementNode {
+			_, dropped := droppedTags[child.Data]
+			match := attr(child, "class") + " " + attr(child, "id")
+			if dropped || (child.Data != "body" && child.Data != "article" && child.Data != "main" &&
+				unlikelyCandidate.MatchString(match) && !maybeCandidate.MatchString(match)) ||
+				hasAttr(child, "hidden") || strings.EqualFold(attr(child, "aria-hidden"), "true") {
+				node.RemoveChild(child)
+			} else {
+				prune(child)
+			}
+		}
+
+		child = next
+	}
+}
+
+func initialScore(node *html.Node) float64 {
+	var s float64
+	switch node.Data {
+	case "article", "main":
+		s = 10
+	case "div":
+		s = 5
+	case "pre", "td", "blockquote":
+		s = 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		s = -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		s = -5
+	}
+
+	for _, name := range []string{attr(node, "class"), attr(node, "id")} {
+		if name == "" {
+			continue
+		}
+		if negativeCandidate.MatchString(name) {
+			s -= 25
+		}
+		if positiveCandidate.MatchString(name) {
+			s += 25
+		}
+	}
+	return s
+}
+
+func linkDensity(node *html.Node) float64 {
+	length := utf8.RuneCountInString(textContent(node))
+	if length == 0 {
+		return 0
+	}
+
+	var links int
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			links += utf8.RuneCountInString(textContent(n))
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(node)
+
+	return float64(links) / float64(length)
+}
+
+func textContent(node *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(node)
+
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// blockText returns the text of the node with paragraphs separated by new lines.
+func blockText(node *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			sb.WriteString(strings.ReplaceAll(n.Data, "\n", " "))
+		case html.ElementNode:
+			_, block := blockTags[n.Data]
+			if block {
+				sb.WriteString("\n")
+			}
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				walk(child)
+			}
+			if block {
+				sb.WriteString("\n")
+			}
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(node)
+
+	lines := strings.Split(sb.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func hasAttr(node *html.Node, key string) bool {
+	for _, a := range node.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func find(node *html.Node, tag string) *html.Node {
+	if node.Type == html.ElementNode && node.Data == tag {
+		return node
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if n := find(child, tag); n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+// summary shortens the paragraphs of the extracted text to a short description, headings are skipped.
+func summary(text string) string {
+	var paragraphs []string
+	for _, line := range strings.Split(text, "\n") {
+		if utf8.RuneCountInString(line) >= minParagraph {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+
+	text = strings.Join(paragraphs, " ")
+	if utf8.RuneCountInString(text) > maxShortDesc {
+		text = strings.TrimSuffix(string([]rune(text)[:maxShortDesc-3]), ".") + "..."
+	}
+	return text
+}
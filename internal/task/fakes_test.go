@@ -0,0 +1,186 @@
+package task
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/internal/model"
+	"github.com/rumorsflow/rumors/v2/pkg/repository"
+	"golang.org/x/exp/slog"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// memRepo keeps the entities in memory, merge applies an update to the stored entity.
+type memRepo[T repository.Entity] struct {
+	mu       sync.Mutex
+	entities map[uuid.UUID]T
+	order    []uuid.UUID
+	merge    func(stored, update T)
+}
+
+func newMemRepo[T repository.Entity](merge func(stored, update T)) *memRepo[T] {
+	return &memRepo[T]{entities: make(map[uuid.UUID]T), merge: merge}
+}
+
+func (r *memRepo[T]) Count(context.Context, any) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return int64(len(r.entities)), nil
+}
+
+func (r *memRepo[T]) Find(context.Context, *repository.Criteria) ([]T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]T, 0, len(r.order))
+	for _, id := range r.order {
+		result = append(result, r.entities[id])
+	}
+	return result, nil
+}
+
+func (r *memRepo[T]) FindIter(context.Context, *repository.Criteria) (repository.Iter[T], error) {
+	return nil, errors.New("not supported")
+}
+
+func (r *memRepo[T]) FindByID(_ context.Context, id uuid.UUID) (value T, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	value, ok := r.entities[id]
+	if !ok {
+		return value, repository.ErrEntityNotFound
+	}
+	return value, nil
+}
+
+func (r *memRepo[T]) Save(_ context.Context, entity T) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := entity.EntityID()
+	if stored, ok := r.entities[id]; ok {
+		r.merge(stored, entity)
+		return nil
+	}
+
+	r.entities[id] = entity
+	r.order = append(r.order, id)
+	return nil
+}
+
+func (r *memRepo[T]) Update(_ context.Context, entity T) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.entities[entity.EntityID()]
+	if !ok {
+		return repository.ErrEntityNotFound
+	}
+	r.merge(stored, entity)
+	return nil
+}
+
+func (r *memRepo[T]) Remove(_ context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.entities, id)
+	return nil
+}
+
+func mergeJob(stored, update *entity.Job) {
+	if update.FetchState != nil {
+		stored.FetchState = update.FetchState
+	}
+	if update.LastRun != nil {
+		stored.LastRun = update.LastRun
+	}
+	if update.Health != nil {
+		stored.Health = update.Health
+	}
+	if update.WebSub != nil {
+		stored.WebSub = update.WebSub
+	}
+}
+
+func mergeArticle(stored, update *entity.Article) {
+	*stored = *update
+}
+
+type nopPub struct{}
+
+func (nopPub) Telegram(context.Context, any) {}
+
+func (nopPub) Articles(context.Context, []model.Article) {}
+
+func (nopPub) ArticleUpdated(context.Context, model.ArticleUpdate) {}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// testFetcher returns a fetcher whose guard lets the requests to the test server through,
+// the certificate of a TLS test server is trusted.
+func testFetcher(t *testing.T, srv *httptest.Server) *Fetcher {
+	t.Helper()
+
+	cfg := &FetcherConfig{Guard: GuardConfig{Allow: []string{"127.0.0.1"}}}
+	cfg.Limiter.RPS = 1000
+	cfg.Limiter.MaxConcurrent = 10
+
+	if srv.TLS != nil {
+		cfg.TLS.RootCA = filepath.Join(t.TempDir(), "ca.pem")
+
+		data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+		if err := os.WriteFile(cfg.TLS.RootCA, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg.Init()
+
+	fetcher, err := NewFetcher(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fetcher
+}
+
+// testListing returns a listing creating the articles of the source and storing them in memory, the site publishes
+// in English and runs the stages which do not request the links of the items.
+func testListing(fetcher *Fetcher, source entity.Source) (listing, *entity.Site, *memRepo[*entity.Job], *memRepo[*entity.Article]) {
+	site := (&entity.Site{
+		ID:        uuid.New(),
+		Domain:    "127.0.0.1",
+		Languages: []string{"en"},
+		Stages:    []string{StageLink, StageText, StageLang, StageFilter, StageTag},
+	}).SetEnabled(true).SetRespectRobots(false)
+
+	siteRepo := newMemRepo[*entity.Site](nil)
+	_ = siteRepo.Save(context.Background(), site)
+
+	jobRepo := newMemRepo[*entity.Job](mergeJob)
+	articleRepo := newMemRepo[*entity.Article](mergeArticle)
+
+	return listing{
+		logger:       testLogger(),
+		publisher:    nopPub{},
+		fetcher:      fetcher,
+		pool:         NewPool(4),
+		siteRepo:     siteRepo,
+		articleRepo:  articleRepo,
+		jobRepo:      jobRepo,
+		processor:    NewArticleProcessor(fetcher, testLogger()),
+		filteredRepo: newMemRepo[*entity.FilteredArticle](nil),
+		source:       source,
+	}, site, jobRepo, articleRepo
+}
@@ -0,0 +1,129 @@
+package task
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/pkg/repository"
+	"net/http"
+	"time"
+)
+
+type fetched struct {
+	body        []byte
+	contentType string
+	header      http.Header
+	state       entity.FetchState
+	unchanged   bool
+}
+
+func (f *Fetcher) Conditional(ctx context.Context, link string, prev *entity.FetchState) (*fetched, error) {
+	return f.conditional(ctx, link, prev, nil)
+}
+
+// conditional sends the header along with the request, the headers of the fetcher config take precedence.
+func (f *Fetcher) conditional(ctx context.Context, link string, prev *entity.FetchState, header map[string]string) (*fetched, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+
+	if prev != nil && prev.Link == link {
+		if prev.ETag != "" {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev.LastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.LastModified)
+		}
+	} else {
+		prev = nil
+	}
+
+	res, err := f.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	now := time.Now()
+	result := &fetched{state: entity.FetchState{
+		Link:      link,
+		Status:    res.StatusCode,
+		CheckedAt: now,
+		ChangedAt: now,
+	}}
+
+	if prev != nil {
+		result.state.ETag = prev.ETag
+		result.state.LastModified = prev.LastModified
+		result.state.Hash = prev.Hash
+		result.state.ChangedAt = prev.ChangedAt
+		result.state.LastSeen = prev.LastSeen
+	}
+
+	if res.StatusCode == http.StatusNotModified {
+		result.unchanged = prev != nil
+		return result, nil
+	}
+
+	if res.StatusCode >= 400 {
+		return result, fmt.Errorf("error due to request %s with response status code %d", link, res.StatusCode)
+	}
+
+	result.contentType = res.Header.Get("Content-Type")
+	result.header = res.Header
+
+	if result.body, err = f.ReadBody(res); err != nil {
+		return result, err
+	}
+
+	hash := checksum(result.body)
+
+	result.unchanged = prev != nil && prev.Hash == hash
+	result.state.ETag = res.Header.Get("ETag")
+	result.state.LastModified = res.Header.Get("Last-Modified")
+	result.state.Hash = hash
+
+	if !result.unchanged {
+		result.state.ChangedAt = now
+	}
+
+	return result, nil
+}
+
+func fetchState(ctx context.Context, repo repository.ReadRepository[*entity.Job], jobID *uuid.UUID) *entity.FetchState {
+	if jobID == nil {
+		return nil
+	}
+
+	job, err := repo.FindByID(ctx, *jobID)
+	if err != nil {
+		return nil
+	}
+
+	return job.FetchState
+}
+
+func saveFetchState(ctx context.Context, repo repository.WriteRepository[*entity.Job], jobID *uuid.UUID, state entity.FetchState) error {
+	if jobID == nil {
+		return nil
+	}
+
+	if err := repo.Update(ctx, (&entity.Job{ID: *jobID}).SetFetchState(state)); err != nil {
+		return fmt.Errorf("%s save job %v fetch state error: %w", OpServerProcessTask, *jobID, err)
+	}
+
+	return nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,284 @@
+package task
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/otiai10/opengraph/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"golang.org/x/net/html"
+	"golang.org/x/net/http/httpproxy"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrBodyTooLarge = errors.New("response body too large")
+
+type Fetcher struct {
+	cfg     *FetcherConfig
+	client  *http.Client
+	rdb     redis.UniversalClient
+	limiter *Limiter
+}
+
+func NewFetcher(cfg *FetcherConfig, rdb redis.UniversalClient) (*Fetcher, error) {
+	tlsConfig, err := cfg.TLS.Config()
+	if err != nil {
+		return nil, fmt.Errorf("%s tls error: %w", OpFetcherNew, err)
+	}
+
+	var proxies []string
+
+	env := httpproxy.FromEnvironment()
+	for _, p := range []string{env.HTTPProxy, env.HTTPSProxy} {
+		if p != "" && !strings.Contains(p, "://") {
+			p = "http://" + p
+		}
+		if u, err := url.Parse(p); err == nil && u.Hostname() != "" {
+			proxies = append(proxies, proxyAddress(u))
+		}
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if cfg.Proxy != "" {
+		u, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("%s proxy error: %w", OpFetcherNew, err)
+		}
+		proxy = http.ProxyURL(u)
+		proxies = append(proxies, proxyAddress(u))
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	dialContext := dialer.DialContext
+
+	var guard *Guard
+	if !cfg.Guard.Disabled {
+		if guard, err = NewGuard(cfg.Guard, proxies...); err != nil {
+			return nil, err
+		}
+		dialContext = guard.DialContext(dialer)
+		proxy = guard.Proxy(proxy)
+	}
+
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialContext,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	f := &Fetcher{
+		cfg: cfg,
+		rdb: rdb,
+		client: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return fmt.Errorf("%w redirect to %s", ErrForbiddenAddress, req.URL)
+				}
+				if len(via) >= cfg.MaxRedirects {
+					return fmt.Errorf("stopped after %d redirects", cfg.MaxRedirects)
+				}
+				if guard != nil {
+					return guard.Check(req.Context(), req.URL.Hostname())
+				}
+				return nil
+			},
+		},
+	}
+
+	if rdb != nil {
+		f.limiter = NewLimiter(rdb, cfg)
+	}
+
+	return f, nil
+}
+
+// proxyAddress returns the host:port the transport dials to reach the proxy.
+func proxyAddress(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return net.JoinHostPort(u.Hostname(), port)
+	}
+
+	port := "80"
+	switch u.Scheme {
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		port = "1080"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+func (f *Fetcher) UserAgent() string {
+	return f.cfg.UserAgent
+}
+
+func (f *Fetcher) Get(ctx context.Context, link string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	return f.Do(req)
+}
+
+func (f *Fetcher) Do(req *http.Request) (*http.Response, error) {
+	if err := f.allowed(req.Context(), req.URL); err != nil {
+		return nil, err
+	}
+	return f.do(req)
+}
+
+func (f *Fetcher) do(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	timeout := f.cfg.RequestTimeout(host)
+	release := func() {}
+
+	if f.limiter != nil {
+		var err error
+		if release, err = f.limiter.Acquire(req.Context(), host); err != nil {
+			return nil, err
+		}
+	}
+
+	req.Header.Set("User-Agent", f.cfg.UserAgent)
+	for key, value := range f.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+
+	if site := f.cfg.Site(host); site != nil {
+		for key, value := range site.Headers {
+			req.Header.Set(key, value)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+
+	res, err := f.client.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+
+	if f.limiter != nil {
+		_ = f.limiter.Observe(req.Context(), host, res)
+	}
+
+	res.Body = &cancelBody{ReadCloser: res.Body, cancel: func() {
+		cancel()
+		release()
+	}}
+
+	return res, nil
+}
+
+func (f *Fetcher) ReadBody(res *http.Response) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(res.Body, f.cfg.MaxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > f.cfg.MaxBodySize {
+		return nil, fmt.Errorf("%w: %s exceeds %d bytes", ErrBodyTooLarge, res.Request.URL, f.cfg.MaxBodySize)
+	}
+
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		return io.ReadAll(io.LimitReader(r, f.cfg.MaxBodySize))
+	}
+
+	return data, nil
+}
+
+// ReadHead reads an HTML document up to the end of its head, at most MaxHeadSize bytes, and transcodes it to UTF-8.
+func (f *Fetcher) ReadHead(res *http.Response) (io.Reader, error) {
+	data, err := io.ReadAll(&headReader{r: io.LimitReader(res.Body, f.cfg.MaxHeadSize)})
+	if err != nil {
+		return nil, err
+	}
+	return decode(data, res.Header.Get("Content-Type")), nil
+}
+
+// OpenGraph parses the meta of the page, the readable content is extracted as well when the context asks for it,
+// otherwise the page is read up to the end of its head only.
+func (f *Fetcher) OpenGraph(ctx context.Context, url string) (*opengraph.OpenGraph, *entity.ArticleContent, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := f.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	if !isHTML(res.Header.Get("Content-Type")) {
+		return nil, nil, errors.New("content type must be text/html or application/xhtml+xml")
+	}
+
+	if res.StatusCode >= 400 {
+		return nil, nil, fmt.Errorf("open graph error due to request %s with response status code %d", url, res.StatusCode)
+	}
+
+	og := opengraph.New(res.Request.URL.String())
+	og.Intent.TrustedTags = []string{opengraph.HTMLMetaTag, opengraph.HTMLTitleTag, opengraph.HTMLLinkTag}
+
+	extract := extracting(ctx)
+
+	var doc io.Reader
+	if extract {
+		data, err := io.ReadAll(io.LimitReader(res.Body, f.cfg.MaxBodySize))
+		if err != nil {
+			return nil, nil, err
+		}
+		doc = decode(data, res.Header.Get("Content-Type"))
+	} else if doc, err = f.ReadHead(res); err != nil {
+		return nil, nil, err
+	}
+
+	node, err := html.Parse(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = walk(og, node); err != nil {
+		return nil, nil, err
+	}
+
+	if !extract {
+		return og, nil, nil
+	}
+	return og, extractContent(node), nil
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
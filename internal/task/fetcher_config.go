@@ -0,0 +1,235 @@
+package task
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/rumorsflow/rumors/v2/pkg/config"
+	"github.com/rumorsflow/rumors/v2/pkg/util"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultUserAgent    = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:109.0) Gecko/20100101 Firefox/111.0"
+	DefaultFetchTimeout = 10 * time.Second
+	DefaultMaxBodySize  = 10 << 20
+	DefaultMaxHeadSize  = 1 << 20
+	DefaultMaxRedirects = 10
+
+	DefaultRPS           = 2
+	DefaultMaxConcurrent = 2
+	DefaultMaxWait       = time.Minute
+	DefaultRetryAfter    = time.Minute
+	DefaultMaxRetryAfter = time.Hour
+
+	DefaultRobotsAgent = "rumors"
+	DefaultRobotsTTL   = 24 * time.Hour
+)
+
+type FetcherConfig struct {
+	UserAgent    string              `mapstructure:"user_agent"`
+	Timeout      time.Duration       `mapstructure:"timeout"`
+	MaxBodySize  int64               `mapstructure:"max_body_size"`
+	MaxHeadSize  int64               `mapstructure:"max_head_size"`
+	MaxRedirects int                 `mapstructure:"max_redirects"`
+	Proxy        string              `mapstructure:"proxy"`
+	Headers      map[string]string   `mapstructure:"headers"`
+	Sites        []FetcherSiteConfig `mapstructure:"sites"`
+	TLS          FetcherTLSConfig    `mapstructure:"tls"`
+	Limiter      LimiterConfig       `mapstructure:"limiter"`
+	Robots       RobotsConfig        `mapstructure:"robots"`
+	Guard        GuardConfig         `mapstructure:"guard"`
+	Canonical    CanonicalConfig     `mapstructure:"canonical"`
+	sites        map[string]*FetcherSiteConfig
+}
+
+type FetcherSiteConfig struct {
+	Domain        string            `mapstructure:"domain"`
+	Timeout       time.Duration     `mapstructure:"timeout"`
+	Headers       map[string]string `mapstructure:"headers"`
+	RPS           float64           `mapstructure:"rps"`
+	MaxConcurrent int               `mapstructure:"max_concurrent"`
+}
+
+type LimiterConfig struct {
+	RPS           float64       `mapstructure:"rps"`
+	MaxConcurrent int           `mapstructure:"max_concurrent"`
+	MaxWait       time.Duration `mapstructure:"max_wait"`
+	RetryAfter    time.Duration `mapstructure:"retry_after"`
+	MaxRetryAfter time.Duration `mapstructure:"max_retry_after"`
+}
+
+type GuardConfig struct {
+	Disabled bool     `mapstructure:"disabled"`
+	Allow    []string `mapstructure:"allow"`
+}
+
+type CanonicalConfig struct {
+	TrackingParams []string `mapstructure:"tracking_params"`
+	KeepScheme     *bool    `mapstructure:"keep_scheme"`
+}
+
+type RobotsConfig struct {
+	Agent string        `mapstructure:"agent"`
+	TTL   time.Duration `mapstructure:"ttl"`
+}
+
+type FetcherTLSConfig struct {
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+	MinVersion         string `mapstructure:"min_version"`
+	RootCA             string `mapstructure:"root_ca"`
+	Cert               string `mapstructure:"cert"`
+	Key                string `mapstructure:"key"`
+}
+
+// LoadFetcherConfig reads the fetcher section, defaults are applied when the section is absent.
+func LoadFetcherConfig(cfg config.Configurer) (*FetcherConfig, error) {
+	var fc FetcherConfig
+	if cfg.Has(sectionFetcher) {
+		if err := cfg.UnmarshalKey(sectionFetcher, &fc); err != nil {
+			return nil, err
+		}
+	}
+	fc.Init()
+
+	return &fc, nil
+}
+
+func (cfg *FetcherConfig) Init() {
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = DefaultUserAgent
+	}
+
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultFetchTimeout
+	}
+
+	if cfg.MaxBodySize == 0 {
+		cfg.MaxBodySize = DefaultMaxBodySize
+	}
+
+	if cfg.MaxHeadSize == 0 {
+		cfg.MaxHeadSize = DefaultMaxHeadSize
+	}
+
+	if cfg.MaxRedirects == 0 {
+		cfg.MaxRedirects = DefaultMaxRedirects
+	}
+
+	if cfg.Limiter.RPS == 0 {
+		cfg.Limiter.RPS = DefaultRPS
+	}
+
+	if cfg.Limiter.MaxConcurrent == 0 {
+		cfg.Limiter.MaxConcurrent = DefaultMaxConcurrent
+	}
+
+	if cfg.Limiter.MaxWait == 0 {
+		cfg.Limiter.MaxWait = DefaultMaxWait
+	}
+
+	if cfg.Limiter.RetryAfter == 0 {
+		cfg.Limiter.RetryAfter = DefaultRetryAfter
+	}
+
+	if cfg.Limiter.MaxRetryAfter == 0 {
+		cfg.Limiter.MaxRetryAfter = DefaultMaxRetryAfter
+	}
+
+	if cfg.Robots.Agent == "" {
+		cfg.Robots.Agent = DefaultRobotsAgent
+	}
+
+	if cfg.Robots.TTL == 0 {
+		cfg.Robots.TTL = DefaultRobotsTTL
+	}
+
+	if cfg.Canonical.TrackingParams == nil {
+		cfg.Canonical.TrackingParams = DefaultTrackingParams
+	}
+
+	// the scheme the fetch ended on is kept, not every http site serves https
+	if cfg.Canonical.KeepScheme == nil {
+		cfg.Canonical.KeepScheme = util.ToPtr(true)
+	}
+
+	cfg.sites = make(map[string]*FetcherSiteConfig, len(cfg.Sites))
+	for i := range cfg.Sites {
+		cfg.sites[strings.ToLower(cfg.Sites[i].Domain)] = &cfg.Sites[i]
+	}
+}
+
+func (cfg *FetcherConfig) Site(host string) *FetcherSiteConfig {
+	host = strings.ToLower(host)
+	if site, ok := cfg.sites[host]; ok {
+		return site
+	}
+	return cfg.sites[util.SafeDomain("//"+host)]
+}
+
+func (cfg *FetcherConfig) RequestTimeout(host string) time.Duration {
+	if site := cfg.Site(host); site != nil && site.Timeout > 0 {
+		return site.Timeout
+	}
+	return cfg.Timeout
+}
+
+func (cfg *FetcherConfig) Limits(host string) (rps float64, maxConcurrent int) {
+	rps, maxConcurrent = cfg.Limiter.RPS, cfg.Limiter.MaxConcurrent
+	if site := cfg.Site(host); site != nil {
+		if site.RPS != 0 {
+			rps = site.RPS
+		}
+		if site.MaxConcurrent != 0 {
+			maxConcurrent = site.MaxConcurrent
+		}
+	}
+	return
+}
+
+func (cfg *FetcherTLSConfig) Config() (*tls.Config, error) {
+	c := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	switch cfg.MinVersion {
+	case "":
+	case "1.0":
+		c.MinVersion = tls.VersionTLS10
+	case "1.1":
+		c.MinVersion = tls.VersionTLS11
+	case "1.2":
+		c.MinVersion = tls.VersionTLS12
+	case "1.3":
+		c.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported tls min version %s", cfg.MinVersion)
+	}
+
+	if cfg.RootCA != "" {
+		data, err := os.ReadFile(cfg.RootCA)
+		if err != nil {
+			return nil, err
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("failed to append root ca %s", cfg.RootCA)
+		}
+		c.RootCAs = pool
+	}
+
+	if cfg.Cert != "" && cfg.Key != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+		if err != nil {
+			return nil, err
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+
+	return c, nil
+}
@@ -0,0 +1,143 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"github.com/rumorsflow/rumors/v2/internal/model"
+	"github.com/rumorsflow/rumors/v2/pkg/repository"
+	"github.com/spf13/cast"
+	"time"
+)
+
+const (
+	groupPrefix   = "rumors:group:"
+	lastModPrefix = "rumors:sitemap:lastmod:"
+
+	groupTTL   = 7 * 24 * time.Hour
+	lastModTTL = 30 * 24 * time.Hour
+
+	groupDone   = "done"
+	groupFailed = "failed"
+)
+
+// Groups tracks the progress of child tasks fanned out by a parent task.
+type Groups struct {
+	rdb redis.UniversalClient
+}
+
+func NewGroups(rdb redis.UniversalClient) *Groups {
+	return &Groups{rdb: rdb}
+}
+
+func (g *Groups) Start(ctx context.Context, id string, jobID *uuid.UUID, total, skipped int) error {
+	now := time.Now().Unix()
+	values := map[string]any{
+		"id":         id,
+		"total":      total,
+		"skipped":    skipped,
+		"created_at": now,
+		"updated_at": now,
+	}
+
+	_, err := g.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if jobID != nil {
+			values["job_id"] = jobID.String()
+			pipe.Set(ctx, groupPrefix+"job:"+jobID.String(), id, groupTTL)
+		}
+		pipe.HSet(ctx, groupPrefix+id, values)
+		pipe.Expire(ctx, groupPrefix+id, groupTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s start %s error: %w", OpGroup, id, err)
+	}
+	return nil
+}
+
+func (g *Groups) Incr(ctx context.Context, id, field string) error {
+	_, err := g.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, groupPrefix+id, field, 1)
+		pipe.HSet(ctx, groupPrefix+id, "updated_at", time.Now().Unix())
+		pipe.Expire(ctx, groupPrefix+id, groupTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s incr %s %s error: %w", OpGroup, id, field, err)
+	}
+	return nil
+}
+
+func (g *Groups) Get(ctx context.Context, id string) (*model.JobGroup, error) {
+	data, err := g.rdb.HGetAll(ctx, groupPrefix+id).Result()
+	if err != nil {
+		return nil, fmt.Errorf("%s get %s error: %w", OpGroup, id, err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%s get %s error: %w", OpGroup, id, repository.ErrEntityNotFound)
+	}
+
+	group := &model.JobGroup{
+		ID:        id,
+		JobID:     data["job_id"],
+		Total:     cast.ToInt(data["total"]),
+		Skipped:   cast.ToInt(data["skipped"]),
+		Done:      cast.ToInt(data[groupDone]),
+		Failed:    cast.ToInt(data[groupFailed]),
+		CreatedAt: time.Unix(cast.ToInt64(data["created_at"]), 0),
+		UpdatedAt: time.Unix(cast.ToInt64(data["updated_at"]), 0),
+	}
+	group.Pending = group.Total - group.Done - group.Failed
+	if group.Pending < 0 {
+		group.Pending = 0
+	}
+	group.Completed = group.Pending == 0
+
+	return group, nil
+}
+
+// Latest returns the progress of the last group started by the job.
+func (g *Groups) Latest(ctx context.Context, jobID uuid.UUID) (*model.JobGroup, error) {
+	id, err := g.rdb.Get(ctx, groupPrefix+"job:"+jobID.String()).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("%s latest %v error: %w", OpGroup, jobID, repository.ErrEntityNotFound)
+		}
+		return nil, fmt.Errorf("%s latest %v error: %w", OpGroup, jobID, err)
+	}
+	return g.Get(ctx, id)
+}
+
+// Modified reports whether the sitemap was modified after the last successful run of its child task.
+func (g *Groups) Modified(ctx context.Context, scope, link string, lastMod time.Time) bool {
+	value, err := g.rdb.HGet(ctx, lastModPrefix+scope, link).Int64()
+	if err != nil {
+		return true
+	}
+	return lastMod.Unix() > value
+}
+
+func (g *Groups) SetLastMod(ctx context.Context, scope, link string, lastMod time.Time) error {
+	_, err := g.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, lastModPrefix+scope, link, lastMod.Unix())
+		pipe.Expire(ctx, lastModPrefix+scope, lastModTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s lastmod %s error: %w", OpGroup, link, err)
+	}
+	return nil
+}
+
+// lastAttempt reports whether a failed task will not be retried anymore.
+func lastAttempt(ctx context.Context) bool {
+	retried, ok := asynq.GetRetryCount(ctx)
+	if !ok {
+		return true
+	}
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	return retried >= maxRetry
+}
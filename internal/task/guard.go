@@ -0,0 +1,174 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+var ErrForbiddenAddress = errors.New("forbidden destination address")
+
+var forbiddenPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+type Guard struct {
+	prefixes []netip.Prefix
+	hosts    []string
+	proxies  map[string]struct{}
+}
+
+// NewGuard returns the guard of the allowed entries of the config. The proxies, given as host:port, are dialed
+// without a check, the destinations of the requests sent through them are checked by CheckProxied instead.
+func NewGuard(cfg GuardConfig, proxies ...string) (*Guard, error) {
+	g := &Guard{proxies: make(map[string]struct{}, len(proxies))}
+
+	for _, proxy := range proxies {
+		g.proxies[strings.ToLower(proxy)] = struct{}{}
+	}
+
+	for _, item := range cfg.Allow {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+
+		if prefix, err := netip.ParsePrefix(item); err == nil {
+			g.prefixes = append(g.prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(item); err == nil {
+			g.prefixes = append(g.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		} else if strings.ContainsAny(item, "/:") {
+			return nil, fmt.Errorf("%s invalid allow entry %s", OpFetcherNew, item)
+		} else {
+			g.hosts = append(g.hosts, strings.TrimPrefix(item, "."))
+		}
+	}
+
+	return g, nil
+}
+
+func (g *Guard) DialContext(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	guarded := *dialer
+	guarded.Control = g.Control
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if _, ok := g.proxies[strings.ToLower(address)]; ok {
+			return dialer.DialContext(ctx, network, address)
+		}
+		if host, _, err := net.SplitHostPort(address); err == nil && g.allowedHost(host) {
+			return dialer.DialContext(ctx, network, address)
+		}
+		return guarded.DialContext(ctx, network, address)
+	}
+}
+
+// Control is called after DNS resolution for every connection attempt, including redirects.
+func (g *Guard) Control(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w %s", ErrForbiddenAddress, address)
+	}
+
+	if !g.Allowed(addrPort.Addr()) {
+		return fmt.Errorf("%w %s", ErrForbiddenAddress, address)
+	}
+	return nil
+}
+
+// Proxy wraps the proxy func of a transport, the destination of a proxied request is checked before it is sent,
+// the dialer sees the address of the proxy only.
+func (g *Guard) Proxy(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		u, err := proxy(req)
+		if err != nil || u == nil {
+			return u, err
+		}
+		if err = g.Check(req.Context(), req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+		return u, nil
+	}
+}
+
+// Check resolves the host and rejects it when one of its addresses is forbidden, IP literals are checked as they are.
+func (g *Guard) Check(ctx context.Context, host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if g.allowedHost(host) {
+		return nil
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !g.Allowed(addr) {
+			return fmt.Errorf("%w %s", ErrForbiddenAddress, host)
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		if !g.Allowed(addr) {
+			return fmt.Errorf("%w %s (%s)", ErrForbiddenAddress, host, addr)
+		}
+	}
+	return nil
+}
+
+func (g *Guard) Allowed(addr netip.Addr) bool {
+	// a zoned address is contained by no prefix
+	addr = addr.Unmap().WithZone("")
+
+	for _, prefix := range g.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	for _, prefix := range forbiddenPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (g *Guard) allowedHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, h := range g.hosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
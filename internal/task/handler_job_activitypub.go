@@ -0,0 +1,479 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/goccy/go-json"
+	"github.com/hibiken/asynq"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/pkg/errs"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const activityTypes = `application/activity+json, application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+
+type HandlerJobActivityPub struct {
+	listing
+}
+
+// CheckActor reports whether the actor of the payload is neither a handle nor a link.
+func CheckActor(payload *entity.ActivityPubPayload) error {
+	if _, _, ok := actorHandle(payload.Actor); ok {
+		return nil
+	}
+	if actorLink(payload.Actor) != "" {
+		return nil
+	}
+	return fmt.Errorf("actor %s is neither a handle nor a link", payload.Actor)
+}
+
+func (h *HandlerJobActivityPub) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	if task.Payload() == nil {
+		h.logger.Warn("task payload is empty")
+		return nil
+	}
+
+	var payload entity.ActivityPubPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		h.logger.Error("error due to unmarshal activitypub payload", "err", err, "payload", task.Payload())
+		return nil
+	}
+
+	ctx, r, err := h.begin(ctx, listingJob{
+		name:    entity.JobActivityPub,
+		jobID:   payload.JobID,
+		siteID:  payload.SiteID,
+		link:    payload.Actor,
+		lang:    payload.Lang,
+		extract: payload.Extract,
+		rules:   payload.Rules,
+		force:   payload.Force,
+		workers: payload.WorkersCount(),
+		pages:   payload.PagesCount(),
+		headers: activityHeaders(),
+	})
+	if r == nil {
+		return err
+	}
+	defer h.runs.Finish(r.run)
+
+	outbox, err := h.outbox(ctx, payload.Actor)
+	if err != nil {
+		r.run.Fail(err)
+
+		if !errs.IsCanceledOrDeadline(err) {
+			h.logger.Error("error due to resolve actor outbox", "err", err, "site_id", payload.SiteID, "actor", payload.Actor)
+		}
+		return nil
+	}
+
+	res, ok := h.fetch(ctx, r, outbox)
+	if !ok {
+		return nil
+	}
+
+	// the outbox lists the newest activities first, it is read until the last seen one
+	lastSeen := res.state.LastSeen
+
+	var newest string
+
+	items, err := h.pages(ctx, r, outbox, res, func(ctx context.Context, link string, res *fetched) (*listingPage, error) {
+		activities, next, err := h.page(ctx, link, res.body)
+		if err != nil {
+			return nil, err
+		}
+
+		if newest == "" && len(activities) > 0 {
+			newest = objectID(activities[0])
+		}
+
+		items, stop := h.items(ctx, activities, lastSeen)
+
+		return &listingPage{items: items, next: next, stop: stop}, nil
+	})
+
+	// the last seen activity moves to the newest one unless a page failed to be fetched, end keeps the whole state
+	// when an item failed, so it only moves past the items saved, filtered or skipped
+	if newest != "" && !r.partial {
+		res.state.LastSeen = newest
+	}
+
+	return h.end(ctx, r, items, res.state, err)
+}
+
+// items maps the activities to items up to the last seen one, it reports whether the last seen one is reached.
+func (h *HandlerJobActivityPub) items(ctx context.Context, activities []any, lastSeen string) ([]listingItem, bool) {
+	items := make([]listingItem, 0, len(activities))
+
+	for _, activity := range activities {
+		if lastSeen != "" && objectID(activity) == lastSeen {
+			return items, true
+		}
+
+		object := h.object(ctx, activity)
+		if object == nil {
+			continue
+		}
+
+		if item, ok := activityItem(object); ok {
+			items = append(items, item)
+		}
+	}
+
+	return items, false
+}
+
+// object returns the object created by the activity, the objects listed by reference are requested.
+// The outboxes of some servers list the objects themselves, not the activities creating them.
+func (h *HandlerJobActivityPub) object(ctx context.Context, activity any) map[string]any {
+	value, ok := activity.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	if kind := first(value, "type"); kind == "Note" || kind == "Article" {
+		return value
+	} else if kind != "Create" {
+		return nil
+	}
+
+	for _, object := range lookup(value, "object") {
+		switch o := object.(type) {
+		case map[string]any:
+			return o
+		case string:
+			doc, err := h.fetchActivity(ctx, o, activityTypes)
+			if err != nil {
+				if !errs.IsCanceledOrDeadline(err) {
+					h.logger.Warn("error due to fetch activity object", "err", err, "object_link", o)
+				}
+				return nil
+			}
+			if o, ok := doc.(map[string]any); ok {
+				return o
+			}
+		}
+	}
+	return nil
+}
+
+// page returns the activities of an outbox page and the link of the next page. When the outbox lists no activities
+// itself its first page is read.
+func (h *HandlerJobActivityPub) page(ctx context.Context, link string, body []byte) ([]any, string, error) {
+	root, err := parseActivity(body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	activities := collectionItems(root)
+
+	if f := lookup(root, "first"); len(activities) == 0 && len(f) > 0 {
+		switch page := f[0].(type) {
+		case map[string]any:
+			root = page
+		case string:
+			if link = resolveLink(link, page); link == "" {
+				return nil, "", nil
+			}
+			if root, err = h.fetchActivity(ctx, link, activityTypes); err != nil {
+				return nil, "", err
+			}
+		}
+		activities = collectionItems(root)
+	}
+
+	var next string
+	if n := lookup(root, "next"); len(n) > 0 {
+		next = resolveLink(link, objectID(n[0]))
+	}
+
+	return activities, next, nil
+}
+
+// outbox returns the link of the outbox of the actor, a handle is resolved by WebFinger first.
+func (h *HandlerJobActivityPub) outbox(ctx context.Context, actor string) (string, error) {
+	link := actorLink(actor)
+
+	if user, host, ok := actorHandle(actor); ok {
+		var err error
+		if link, err = h.webfinger(ctx, user, host); err != nil {
+			return "", err
+		}
+	}
+
+	if link == "" {
+		return "", fmt.Errorf("%s error: actor %s is neither a handle nor a link", OpServerParseActivity, actor)
+	}
+
+	doc, err := h.fetchActivity(ctx, link, activityTypes)
+	if err != nil {
+		return "", err
+	}
+
+	var outbox string
+	if o := lookup(doc, "outbox"); len(o) > 0 {
+		outbox = resolveLink(link, objectID(o[0]))
+	}
+
+	if outbox == "" {
+		return "", fmt.Errorf("%s error: actor %s has no outbox", OpServerParseActivity, link)
+	}
+	return outbox, nil
+}
+
+// webfinger returns the link of the actor document the account of the host points to.
+func (h *HandlerJobActivityPub) webfinger(ctx context.Context, user, host string) (string, error) {
+	resource := "acct:" + user + "@" + host
+	link := (&url.URL{
+		Scheme:   "https",
+		Host:     host,
+		Path:     "/.well-known/webfinger",
+		RawQuery: url.Values{"resource": {resource}}.Encode(),
+	}).String()
+
+	doc, err := h.fetchActivity(ctx, link, "application/jrd+json, application/json")
+	if err != nil {
+		return "", err
+	}
+
+	for _, l := range flatten(lookup(doc, "links")) {
+		if first(l, "rel") != "self" {
+			continue
+		}
+		if t := first(l, "type"); t == "application/activity+json" || strings.HasPrefix(t, "application/ld+json") {
+			if href := resolveLink(link, first(l, "href")); href != "" {
+				return href, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("%s error: webfinger of %s has no actor link", OpServerParseActivity, resource)
+}
+
+func (h *HandlerJobActivityPub) fetchActivity(ctx context.Context, link, accept string) (any, error) {
+	res, err := h.fetcher.conditional(ctx, link, nil, map[string]string{"Accept": accept})
+	if err != nil {
+		return nil, err
+	}
+	return parseActivity(res.body)
+}
+
+func activityHeaders() map[string]string {
+	return map[string]string{"Accept": activityTypes}
+}
+
+func parseActivity(body []byte) (any, error) {
+	var root any
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&root); err != nil {
+		return nil, fmt.Errorf("%s error: %w", OpServerParseActivity, err)
+	}
+	return root, nil
+}
+
+// activityItem maps a Note or an Article to an item, the replies are left out. The name of an article is its title,
+// the first line of a note is the title of the note. The first link of a note is the link of its item,
+// the note itself otherwise. The hashtags are the categories.
+func activityItem(object map[string]any) (listingItem, bool) {
+	kind := first(object, "type")
+	if (kind != "Note" && kind != "Article") || len(lookup(object, "inReplyTo")) > 0 {
+		return listingItem{}, false
+	}
+
+	item := listingItem{link: objectURL(object), date: parseDate(first(object, "published"), nil)}
+	if item.link == "" {
+		return listingItem{}, false
+	}
+
+	content := first(object, "content")
+	if content == "" {
+		if values, ok := object["contentMap"].(map[string]any); ok {
+			for _, value := range values {
+				if content = scalar(value); content != "" {
+					break
+				}
+			}
+		}
+	}
+
+	for _, tag := range flatten(lookup(object, "tag")) {
+		if first(tag, "type") == "Hashtag" {
+			if name := strings.TrimPrefix(first(tag, "name"), "#"); name != "" {
+				item.categories = append(item.categories, name)
+			}
+		}
+	}
+
+	for _, attachment := range append(flatten(lookup(object, "attachment")), flatten(lookup(object, "image"))...) {
+		if m := first(attachment, "mediaType"); m != "" && !strings.HasPrefix(m, "image/") {
+			continue
+		}
+		if t := first(attachment, "type"); t != "" && t != "Image" && t != "Document" {
+			continue
+		}
+		if item.image = resolveLink(item.link, objectURL(attachment)); item.image != "" {
+			break
+		}
+	}
+
+	if kind == "Article" {
+		item.title, item.desc, item.content = first(object, "name"), first(object, "summary"), content
+		return item, true
+	}
+
+	text, link := noteText(content)
+	if link != "" {
+		item.link = link
+	} else {
+		item.content = content
+	}
+
+	title, desc, _ := strings.Cut(text, "\n")
+	title, desc = strings.TrimSpace(title), strings.TrimSpace(desc)
+
+	if utf8.RuneCountInString(title) > 100 {
+		title, desc = strings.TrimSuffix(string([]rune(title)[:97]), ".")+"...", text
+	}
+
+	item.title, item.desc = title, desc
+
+	return item, true
+}
+
+// noteText returns the text of the note by lines and its first link which is neither a mention nor a hashtag.
+// The links written out are dropped from the text.
+func noteText(content string) (string, string) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return "", ""
+	}
+
+	var link string
+
+	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		if s.HasClass("mention") || s.HasClass("hashtag") || s.AttrOr("rel", "") == "tag" {
+			return
+		}
+
+		href := postURL(s.AttrOr("href", ""))
+		if href == "" {
+			return
+		}
+		if link == "" {
+			link = href
+		}
+		if text := strings.TrimSpace(s.Text()); !strings.ContainsAny(text, " \t\n") && strings.ContainsAny(text, "./") {
+			s.Remove()
+		}
+	})
+
+	doc.Find("br").ReplaceWithHtml("\n")
+
+	var lines []string
+	paragraphs := doc.Find("p")
+	if paragraphs.Length() == 0 {
+		paragraphs = doc.Find("body")
+	}
+
+	paragraphs.Each(func(_ int, s *goquery.Selection) {
+		for _, line := range strings.Split(s.Text(), "\n") {
+			if line = strings.Join(strings.Fields(line), " "); line != "" {
+				lines = append(lines, line)
+			}
+		}
+	})
+
+	return strings.Join(lines, "\n"), link
+}
+
+// collectionItems returns the items of an ordered or an unordered collection.
+func collectionItems(root any) []any {
+	return append(flatten(lookup(root, "orderedItems")), flatten(lookup(root, "items"))...)
+}
+
+// objectID returns the id of an object, an object referenced by link is its link.
+func objectID(value any) string {
+	if s, ok := value.(string); ok {
+		return strings.TrimSpace(s)
+	}
+	return first(value, "id")
+}
+
+// objectURL returns the html link of an object, the url is either a link, a Link object or a list of them,
+// the id of the object is used when it has no url.
+func objectURL(object any) string {
+	if s, ok := object.(string); ok {
+		return strings.TrimSpace(s)
+	}
+
+	var link string
+
+	for _, u := range flatten(lookup(object, "url")) {
+		switch v := u.(type) {
+		case string:
+			if link == "" {
+				link = strings.TrimSpace(v)
+			}
+		case map[string]any:
+			href := first(v, "href")
+			if m := first(v, "mediaType"); href != "" && (m == "text/html" || link == "") {
+				link = href
+				if m == "text/html" {
+					return link
+				}
+			}
+		}
+	}
+
+	if link == "" {
+		link = first(object, "id")
+	}
+	return link
+}
+
+func resolveLink(base, ref string) string {
+	if ref = strings.TrimSpace(ref); ref == "" {
+		return ""
+	}
+
+	b, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+
+	u, err := b.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.String()
+}
+
+// actorHandle splits a handle like @user@host, the acct: scheme is accepted too.
+func actorHandle(actor string) (string, string, bool) {
+	actor = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(actor), "acct:"), "@")
+	if strings.Contains(actor, "://") {
+		return "", "", false
+	}
+
+	user, host, ok := strings.Cut(actor, "@")
+	if !ok || user == "" || host == "" || strings.ContainsAny(user+host, "@/ \t?#") {
+		return "", "", false
+	}
+	return user, strings.ToLower(host), true
+}
+
+// actorLink returns the link of the actor document when the actor is one.
+func actorLink(actor string) string {
+	actor = strings.TrimSpace(actor)
+	if !strings.Contains(actor, "://") {
+		return ""
+	}
+	return resolveLink(actor, actor)
+}
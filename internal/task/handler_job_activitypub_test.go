@@ -0,0 +1,279 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+const outboxPageSize = 2
+
+// fediverse serves an account, its actor document and its outbox paged by outboxPageSize, the newest activity first.
+type fediverse struct {
+	mu         sync.Mutex
+	base       string
+	activities []any
+	requests   map[string]int
+}
+
+func newFediverse(t *testing.T) (*fediverse, *httptest.Server) {
+	f := &fediverse{requests: make(map[string]int)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/webfinger", f.webfinger)
+	mux.HandleFunc("/users/news", f.actor)
+	mux.HandleFunc("/users/news/outbox", f.outbox)
+
+	srv := httptest.NewTLSServer(mux)
+	t.Cleanup(srv.Close)
+
+	f.base = srv.URL
+
+	return f, srv
+}
+
+func (f *fediverse) count(r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests[r.URL.RequestURI()]++
+}
+
+func (f *fediverse) requested(uri string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.requests[uri]
+}
+
+func (f *fediverse) write(w http.ResponseWriter, contentType string, doc any) {
+	w.Header().Set("Content-Type", contentType)
+	_ = json.NewEncoder(w).Encode(doc)
+}
+
+func (f *fediverse) webfinger(w http.ResponseWriter, r *http.Request) {
+	f.count(r)
+
+	if r.URL.Query().Get("resource") != "acct:news@"+r.Host {
+		http.NotFound(w, r)
+		return
+	}
+
+	f.write(w, "application/jrd+json", map[string]any{
+		"subject": "acct:news@" + r.Host,
+		"links": []any{
+			map[string]any{"rel": "http://webfinger.net/rel/profile-page", "type": "text/html", "href": f.base + "/@news"},
+			map[string]any{"rel": "self", "type": "application/activity+json", "href": f.base + "/users/news"},
+		},
+	})
+}
+
+func (f *fediverse) actor(w http.ResponseWriter, r *http.Request) {
+	f.count(r)
+
+	f.write(w, "application/activity+json", map[string]any{
+		"id":     f.base + "/users/news",
+		"type":   "Person",
+		"outbox": "/users/news/outbox",
+	})
+}
+
+func (f *fediverse) outbox(w http.ResponseWriter, r *http.Request) {
+	f.count(r)
+
+	f.mu.Lock()
+	activities := f.activities
+	f.mu.Unlock()
+
+	link := f.base + "/users/news/outbox"
+
+	page := r.URL.Query().Get("page")
+	if page == "" {
+		f.write(w, "application/activity+json", map[string]any{
+			"id":         link,
+			"type":       "OrderedCollection",
+			"totalItems": len(activities),
+			"first":      link + "?page=1",
+		})
+		return
+	}
+
+	n, _ := strconv.Atoi(page)
+	start, end := (n-1)*outboxPageSize, n*outboxPageSize
+	if end > len(activities) {
+		end = len(activities)
+	}
+
+	doc := map[string]any{
+		"id":           link + "?page=" + page,
+		"type":         "OrderedCollectionPage",
+		"orderedItems": activities[start:end],
+	}
+	if end < len(activities) {
+		doc["next"] = link + "?page=" + strconv.Itoa(n+1)
+	}
+
+	f.write(w, "application/activity+json", doc)
+}
+
+// post puts a note on top of the outbox, the activity creating it is returned.
+func (f *fediverse) post(n int) map[string]any {
+	status := f.base + "/users/news/statuses/" + strconv.Itoa(n)
+
+	activity := map[string]any{
+		"id":   status + "/activity",
+		"type": "Create",
+		"object": map[string]any{
+			"id":        status,
+			"type":      "Note",
+			"url":       f.base + "/@news/" + strconv.Itoa(n),
+			"published": fmt.Sprintf("2026-10-%02dT10:00:00Z", n),
+			"content":   fmt.Sprintf("<p>The harbour story number %d is told here<br>It goes on in the second line.</p>", n),
+			"tag":       []any{map[string]any{"type": "Hashtag", "name": "#harbour"}},
+		},
+	}
+
+	f.push(activity)
+
+	return activity
+}
+
+func (f *fediverse) push(activity any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.activities = append([]any{activity}, f.activities...)
+}
+
+func TestHandlerJobActivityPub_ProcessTask(t *testing.T) {
+	fedi, srv := newFediverse(t)
+
+	lists, site, jobRepo, articleRepo := testListing(testFetcher(t, srv), entity.ActivityPubSource)
+	handler := &HandlerJobActivityPub{listing: lists}
+
+	jobID := uuid.New()
+	_ = jobRepo.Save(context.Background(), &entity.Job{ID: jobID, Name: entity.JobActivityPub})
+
+	maxPages := 5
+	data, err := json.Marshal(entity.ActivityPubPayload{
+		JobID:    &jobID,
+		SiteID:   site.ID,
+		Actor:    "@news@" + srv.Listener.Addr().String(),
+		MaxPages: &maxPages,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := asynq.NewTask(string(entity.JobActivityPub), data)
+
+	fedi.post(1)
+	fedi.post(2)
+	fedi.push(map[string]any{"id": fedi.base + "/users/news/statuses/boost/activity", "type": "Announce", "object": "https://example.com/notes/1"})
+	third := fedi.post(3)
+
+	if err = handler.ProcessTask(context.Background(), task); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+
+	if n := fedi.requested("/.well-known/webfinger?" + url.Values{"resource": {"acct:news@" + srv.Listener.Addr().String()}}.Encode()); n != 1 {
+		t.Errorf("webfinger requested %d times, want 1", n)
+	}
+	if n := fedi.requested("/users/news"); n != 1 {
+		t.Errorf("actor requested %d times, want 1", n)
+	}
+	if n := fedi.requested("/users/news/outbox?page=2"); n != 1 {
+		t.Errorf("second outbox page requested %d times, want 1", n)
+	}
+
+	articles, _ := articleRepo.Find(context.Background(), nil)
+	if len(articles) != 3 {
+		t.Fatalf("first run saved %d articles, want 3", len(articles))
+	}
+
+	for i, article := range articles {
+		n := i + 1
+		if want := fedi.base + "/@news/" + strconv.Itoa(n); article.Link != want {
+			t.Errorf("article %d link = %s, want %s", n, article.Link, want)
+		}
+		if want := fmt.Sprintf("The harbour story number %d is told here", n); article.Title != want {
+			t.Errorf("article %d title = %q, want %q", n, article.Title, want)
+		}
+		if article.Lang != "en" {
+			t.Errorf("article %d lang = %s, want en", n, article.Lang)
+		}
+		if article.Categories == nil || len(*article.Categories) != 1 || (*article.Categories)[0] != "harbour" {
+			t.Errorf("article %d categories = %v, want [harbour]", n, article.Categories)
+		}
+	}
+
+	job, _ := jobRepo.FindByID(context.Background(), jobID)
+	if job.FetchState == nil || job.FetchState.LastSeen != third["id"] {
+		t.Fatalf("last seen after the first run = %v, want %s", job.FetchState, third["id"])
+	}
+
+	fourth := fedi.post(4)
+
+	if err = handler.ProcessTask(context.Background(), task); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+
+	if n := fedi.requested("/users/news/outbox?page=2"); n != 1 {
+		t.Errorf("second outbox page requested %d times, want 1, the second run stops at the last seen activity", n)
+	}
+
+	articles, _ = articleRepo.Find(context.Background(), nil)
+	if len(articles) != 4 {
+		t.Fatalf("second run saved %d articles, want 1", len(articles)-3)
+	}
+	if want := fedi.base + "/@news/4"; articles[3].Link != want {
+		t.Errorf("second run article link = %s, want %s", articles[3].Link, want)
+	}
+
+	job, _ = jobRepo.FindByID(context.Background(), jobID)
+	if job.FetchState.LastSeen != fourth["id"] {
+		t.Errorf("last seen after the second run = %s, want %s", job.FetchState.LastSeen, fourth["id"])
+	}
+}
+
+func TestHandlerJobActivityPub_KeepsLastSeenWhenItemFailed(t *testing.T) {
+	fedi, srv := newFediverse(t)
+
+	lists, site, jobRepo, articleRepo := testListing(testFetcher(t, srv), entity.ActivityPubSource)
+	handler := &HandlerJobActivityPub{listing: lists}
+
+	// the metadata stage requests the links of the notes, the test server has no pages for them
+	site.Stages = DefaultStages
+
+	jobID := uuid.New()
+	_ = jobRepo.Save(context.Background(), &entity.Job{
+		ID:         jobID,
+		Name:       entity.JobActivityPub,
+		FetchState: &entity.FetchState{Link: fedi.base + "/users/news/outbox", LastSeen: "previous"},
+	})
+
+	data, _ := json.Marshal(entity.ActivityPubPayload{JobID: &jobID, SiteID: site.ID, Actor: fedi.base + "/users/news"})
+
+	fedi.post(1)
+
+	if err := handler.ProcessTask(context.Background(), asynq.NewTask(string(entity.JobActivityPub), data)); err != nil {
+		t.Fatal(err)
+	}
+
+	if articles, _ := articleRepo.Find(context.Background(), nil); len(articles) != 0 {
+		t.Fatalf("saved %d articles, want 0", len(articles))
+	}
+
+	job, _ := jobRepo.FindByID(context.Background(), jobID)
+	if job.FetchState.LastSeen != "previous" || job.FetchState.Hash != "" {
+		t.Errorf("fetch state = %+v, want the previous one", job.FetchState)
+	}
+}
@@ -1,40 +1,34 @@
 package task
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"github.com/abadojack/whatlanggo"
 	"github.com/goccy/go-json"
-	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
 	"github.com/mmcdole/gofeed"
-	"github.com/otiai10/opengraph/v2"
-	"github.com/rumorsflow/rumors/v2/internal/common"
-	"github.com/rumorsflow/rumors/v2/internal/db"
 	"github.com/rumorsflow/rumors/v2/internal/entity"
 	"github.com/rumorsflow/rumors/v2/internal/model"
 	"github.com/rumorsflow/rumors/v2/pkg/errs"
 	"github.com/rumorsflow/rumors/v2/pkg/repository"
 	"github.com/rumorsflow/rumors/v2/pkg/util"
-	"golang.org/x/exp/slog"
+	"go.mongodb.org/mongo-driver/bson"
 	"net/url"
 	"sort"
 	"strings"
 	"time"
-	"unicode/utf8"
 )
 
 const (
-	minShortDesc = 20
-	maxShortDesc = 500
+	minShortDesc  = 20
+	minSourceDesc = 50
+	maxShortDesc  = 500
 )
 
 type HandlerJobFeed struct {
-	logger      *slog.Logger
-	publisher   common.Pub
-	siteRepo    repository.ReadRepository[*entity.Site]
-	articleRepo repository.ReadWriteRepository[*entity.Article]
+	listing
+	websub *WebSub
 }
 
 func (h *HandlerJobFeed) ProcessTask(ctx context.Context, task *asynq.Task) error {
@@ -49,8 +43,25 @@ func (h *HandlerJobFeed) ProcessTask(ctx context.Context, task *asynq.Task) erro
 		return nil
 	}
 
+	pushed := payload.Pushed != nil
+
+	if !payload.Force && !pushed && h.runs.Deferred(ctx, payload.JobID) {
+		h.logger.Debug("job is backed off, run skipped", "job_id", payload.JobID)
+		return nil
+	}
+
+	if !payload.Force && !pushed && !h.websub.Polls(ctx, payload.JobID) {
+		h.logger.Debug("feed is pushed by websub hub, run skipped", "job_id", payload.JobID)
+		return nil
+	}
+
+	run := h.runs.Start(ctx, entity.JobFeed, payload.JobID, payload.SiteID, payload.Link, "")
+	defer h.runs.Finish(run)
+
 	site, err := h.siteRepo.FindByID(ctx, payload.SiteID)
 	if err != nil {
+		run.Fail(err)
+
 		if errors.Is(err, repository.ErrEntityNotFound) {
 			h.logger.Error("error due to find site", "err", err, "id", payload.SiteID)
 			return nil
@@ -58,121 +69,163 @@ func (h *HandlerJobFeed) ProcessTask(ctx context.Context, task *asynq.Task) erro
 		return fmt.Errorf("%s find site %v error: %w", OpServerProcessTask, payload.SiteID, err)
 	}
 
-	parsed, err := h.parseFeed(ctx, payload.Link)
+	if site.RespectsRobots() {
+		ctx = WithRobots(ctx)
+	}
+
+	if site.Extracts(payload.Extract) {
+		ctx = WithExtract(ctx)
+	}
+
+	ctx = WithRules(ctx, payload.Rules)
+
+	res, err := h.fetchFeed(ctx, payload)
+	run.Fetched(res)
+
 	if err != nil {
+		run.Fail(err)
+
 		if !errs.IsCanceledOrDeadline(err) {
-			h.logger.Error("error due to parse feed", "err", err, "site_id", payload.SiteID, "feed_link", payload.Link)
+			h.logger.Error("error due to fetch feed", "err", err, "site_id", payload.SiteID, "feed_link", payload.Link)
 		}
 		return nil
 	}
 
-	lastIndex, err := h.findLastIndex(ctx, parsed.Items)
-	if err != nil {
-		return err
+	if res.unchanged {
+		h.logger.Debug("feed not modified", "site_id", payload.SiteID, "feed_link", payload.Link)
+		h.saveFetchState(ctx, payload.JobID, res.state)
+		return nil
 	}
 
-	if lastIndex > -1 {
-		if n := len(parsed.Items) - lastIndex - 1; n > 0 {
-			items := make([]*gofeed.Item, len(parsed.Items)-lastIndex-1)
-			for i := 0; i <= lastIndex; i++ {
-				parsed.Items[i] = nil
-			}
-			copy(items, parsed.Items[lastIndex+1:])
-			parsed.Items = items
-		} else {
-			return nil
-		}
+	parsed, err := h.parseFeed(res.body)
+	if err != nil {
+		run.Fail(err)
+		h.logger.Error("error due to parse feed", "err", err, "site_id", payload.SiteID, "feed_link", payload.Link)
+		return nil
 	}
 
-	for _, item := range parsed.Items {
-		select {
-		case <-ctx.Done():
-			return nil
-		default:
-		}
-
-		h.processItem(ctx, site, item)
+	if !pushed {
+		h.websub.Discover(ctx, payload.JobID, payload.Link, res)
 	}
 
-	return nil
-}
+	run.Seen(len(parsed.Items))
 
-func (h *HandlerJobFeed) processItem(ctx context.Context, site *entity.Site, item *gofeed.Item) {
-	og, err := h.parseOpengraphMeta(ctx, item.Link)
+	scope := dedupScope(payload.JobID, payload.SiteID)
+
+	items, known, err := h.unseen(ctx, run, scope, parsed.Items)
 	if err != nil {
-		if !errs.IsCanceledOrDeadline(err) {
-			h.logger.Error("error due to parse feed item's link", "err", fmt.Errorf("%s error: %w", OpServerProcessTask, err), "item", item)
-		}
-		return
+		run.Fail(err)
+		return err
 	}
 
-	if item.Description == "" {
-		if item.Description = item.Content; item.Description == "" {
-			item.Description = og.Description
+	var failed int
+
+	Ordered(ctx, h.pool, payload.WorkersCount(), items, func(ctx context.Context, item *gofeed.Item) itemResult {
+		key := h.itemKey(item)
+		article, failed := h.processItem(ctx, run, scope, key, site, item)
+		return itemResult{key: key, article: article, failed: failed}
+	}, func(r itemResult) bool {
+		switch {
+		case r.article != nil && h.saveArticle(ctx, run, r.article):
+			h.markSeen(ctx, scope, r.key)
+		case r.article != nil || r.failed:
+			failed++
 		}
+		return true
+	})
+
+	if !pushed {
+		h.commitFetchState(ctx, payload.JobID, res.state, failed)
 	}
 
-	var shortDesc string
+	h.updates(ctx, run, payload.WorkersCount(), site, known)
 
-	if shortDesc = util.StripHTMLTags(og.Description); utf8.RuneCountInString(shortDesc) < minShortDesc {
-		if shortDesc = util.StripHTMLTags(item.Description); utf8.RuneCountInString(shortDesc) > maxShortDesc {
-			shortDesc = string([]rune(shortDesc)[:maxShortDesc-3])
-			shortDesc = strings.TrimSuffix(shortDesc, ".") + "..."
-		}
+	run.Fail(ctx.Err())
+
+	return nil
+}
+
+// itemResult is the article of an item, failed reports an item failed for a transient reason.
+type itemResult struct {
+	key     string
+	article *entity.Article
+	failed  bool
+}
+
+func (h *HandlerJobFeed) processItem(ctx context.Context, run *Run, scope, key string, site *entity.Site, item *gofeed.Item) (*entity.Article, bool) {
+	if h.published(ctx, site, util.StripHTMLTags(item.Title)) {
+		run.Duplicates(1)
+		h.markSeen(ctx, scope, key)
+		h.logger.Debug("feed item skipped, the same title is already published", "item", item)
+		return nil, false
 	}
 
-	if item.Title = util.StripHTMLTags(item.Title); item.Title == "" {
-		if item.Title = util.StripHTMLTags(og.Title); item.Title == "" {
-			if item.Title = shortDesc; utf8.RuneCountInString(item.Title) > 100 {
-				item.Title = strings.TrimSuffix(string([]rune(item.Title)[:97]), ".") + "..."
+	article, err := h.article(ctx, site, item)
+	if err != nil {
+		var filtered *FilteredError
+
+		switch {
+		case errs.IsCanceledOrDeadline(err):
+			return nil, true
+		case errors.Is(err, ErrItemSkipped):
+			h.markSeen(ctx, scope, key)
+			h.logger.Warn("feed item skipped", "err", err, "item", item)
+		case errors.As(err, &filtered):
+			run.Filtered()
+			h.markSeen(ctx, scope, key)
+			h.logger.Debug("feed item filtered", "reason", filtered.Reason, "link", item.Link)
+			if err = saveFiltered(ctx, h.filteredRepo, run.JobID(), filtered); err != nil {
+				h.logger.Error("error due to save filtered feed item", "err", err)
 			}
+		default:
+			run.OGFailure()
+			h.logger.Error("error due to parse feed item's link", "err", fmt.Errorf("%s error: %w", OpServerProcessTask, err), "item", item)
+			return nil, true
 		}
+		return nil, false
 	}
 
-	if item.Title == "" {
-		h.logger.Warn("article title not found", "feed", item, "og", og)
-		return
-	}
+	return article, false
+}
 
-	lang := whatlanggo.DetectLang(item.Title + " " + shortDesc + " " + item.Description).Iso6391()
-	if !contains(site.Languages, lang) {
-		if len(site.Languages) > 0 {
-			lang = site.Languages[0]
-		} else {
-			h.logger.Warn("feed item's lang not detected", "item", item)
-			return
-		}
+func (h *HandlerJobFeed) article(ctx context.Context, site *entity.Site, item *gofeed.Item) (*entity.Article, error) {
+	people := item.Authors
+	if len(people) == 0 && item.Author != nil {
+		people = []*gofeed.Person{item.Author}
 	}
 
-	article := &entity.Article{
-		ID:      uuid.New(),
-		SiteID:  site.ID,
-		Source:  entity.FeedSource,
-		Lang:    lang,
-		Title:   item.Title,
-		Link:    item.Link,
-		PubDate: *item.PublishedParsed,
-	}
+	return h.processor.Process(ctx, &ArticleDraft{
+		Site:       site,
+		Source:     entity.FeedSource,
+		Link:       item.Link,
+		Title:      item.Title,
+		Desc:       item.Description,
+		Content:    item.Content,
+		PubDate:    item.PublishedParsed,
+		UpdatedAt:  item.UpdatedParsed,
+		Categories: item.Categories,
+		Authors:    authors(people),
+		Media:      feedMedia(item),
+		Digest:     itemDigest(item),
+	})
+}
 
-	if utf8.RuneCountInString(shortDesc) >= 50 {
-		article.SetDesc(shortDesc)
+// fetchFeed returns the feed delivered by the hub as it is, the feed link is fetched otherwise.
+func (h *HandlerJobFeed) fetchFeed(ctx context.Context, payload entity.FeedPayload) (*fetched, error) {
+	if payload.Pushed != nil {
+		return &fetched{body: payload.Pushed}, nil
 	}
 
-	media := toMedia(og)
-	if len(media) > 0 {
-		article.SetMedia(media)
+	res, err := h.fetcher.Conditional(ctx, payload.Link, fetchState(ctx, h.jobRepo, payload.JobID))
+	if err != nil {
+		return res, fmt.Errorf("%s error: %w", OpServerParseFeed, err)
 	}
 
-	h.saveArticle(ctx, article)
+	return res, nil
 }
 
-func (h *HandlerJobFeed) parseFeed(ctx context.Context, link string) (*gofeed.Feed, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-
-	p := gofeed.NewParser()
-	p.UserAgent = userAgent
-	parsed, err := p.ParseURLWithContext(link, ctx)
+func (h *HandlerJobFeed) parseFeed(body []byte) (*gofeed.Feed, error) {
+	parsed, err := gofeed.NewParser().Parse(bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("%s error: %w", OpServerParseFeed, err)
 	}
@@ -231,67 +284,199 @@ func (h *HandlerJobFeed) parseFeed(ctx context.Context, link string) (*gofeed.Fe
 	return parsed, err
 }
 
-func (h *HandlerJobFeed) saveArticle(ctx context.Context, article *entity.Article) {
-	if err := h.articleRepo.Save(ctx, article); err != nil {
-		if errs.IsCanceledOrDeadline(err) {
-			return
-		}
+// unseen splits the items into new and already processed ones, the seen-set is checked first and the rest is looked up by link.
+func (h *HandlerJobFeed) unseen(ctx context.Context, run *Run, scope string, items []*gofeed.Item) ([]*gofeed.Item, []*gofeed.Item, error) {
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = h.itemKey(item)
+	}
+
+	seen, err := h.dedup.Has(ctx, scope, keys)
+	if err != nil {
+		h.logger.Warn("error due to check seen feed items", "err", err, "scope", scope)
+	}
 
-		if errors.Is(err, repository.ErrDuplicateKey) {
-			h.logger.Debug("error due to save article, duplicate key", "article", article)
+	var (
+		keysKnown  []string
+		known      = make([]*gofeed.Item, 0, len(items))
+		candidates = make([]*gofeed.Item, 0, len(items))
+		unseen     = make([]*gofeed.Item, 0, len(items))
+	)
+
+	for i, item := range items {
+		if seen[i] {
+			known = append(known, item)
+			keysKnown = append(keysKnown, keys[i])
 		} else {
-			h.logger.Error("error due to save article", "err", err, "article", article)
+			candidates = append(candidates, item)
+		}
+	}
+
+	if len(candidates) > 0 {
+		existing, err := h.existing(ctx, candidates)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, item := range candidates {
+			if h.stored(existing, item) != nil {
+				known = append(known, item)
+				keysKnown = append(keysKnown, h.itemKey(item))
+			} else {
+				unseen = append(unseen, item)
+			}
 		}
-		return
 	}
 
-	h.logger.Debug("article saved", "article", article)
+	run.Duplicates(len(known))
 
-	h.publisher.Articles(ctx, []model.Article{model.ArticleFromEntity(article)})
+	// known items are added again to keep them in the current generation of the seen-set
+	h.markSeen(ctx, scope, keysKnown...)
+
+	return unseen, known, nil
 }
 
-func (h *HandlerJobFeed) findLastIndex(ctx context.Context, items []*gofeed.Item) (int, error) {
-	seen := make(map[string]int, len(items))
+func (h *HandlerJobFeed) existing(ctx context.Context, items []*gofeed.Item) (map[string]*entity.Article, error) {
 	links := make([]string, len(items))
-
 	for i, item := range items {
-		seen[item.Link] = i
 		links[i] = item.Link
 	}
 
-	query := fmt.Sprintf("sort=-created_at&field.0.0=link&cond.0.0=in&value.0.0=%s", strings.Join(links, ","))
-	criteria := db.BuildCriteria(query).SetSize(int64(len(links)))
+	return findExisting(ctx, h.articleRepo, h.fetcher, links)
+}
+
+func (h *HandlerJobFeed) stored(existing map[string]*entity.Article, item *gofeed.Item) *entity.Article {
+	if article, ok := existing[item.Link]; ok {
+		return article
+	}
+	return existing[h.fetcher.Canonical(item.Link, nil)]
+}
+
+// updates applies edits of recently published items to the stored articles.
+// An item is rebuilt when the feed reports a newer update date or its digest differs from the stored one.
+func (h *HandlerJobFeed) updates(ctx context.Context, run *Run, workers int, site *entity.Site, items []*gofeed.Item) {
+	window := h.dedup.UpdateWindow()
+	if window <= 0 || len(items) == 0 {
+		return
+	}
+
+	since := time.Now().Add(-window)
+	recent := make([]*gofeed.Item, 0, len(items))
 
-	iter, err := h.articleRepo.FindIter(ctx, criteria)
+	for _, item := range items {
+		if item.PublishedParsed.After(since) || (item.UpdatedParsed != nil && item.UpdatedParsed.After(since)) {
+			recent = append(recent, item)
+		}
+	}
+
+	if len(recent) == 0 {
+		return
+	}
+
+	existing, err := h.existing(ctx, recent)
 	if err != nil {
-		return -1, fmt.Errorf("%s find article last index error: %w", OpServerProcessTask, err)
+		h.logger.Error("error due to find articles to update", "err", err, "site_id", site.ID)
+		return
 	}
 
-	defer func() {
-		_ = iter.Close(context.Background())
-	}()
+	changed := make([]feedUpdate, 0, len(recent))
+	for _, item := range recent {
+		if article := h.stored(existing, item); article != nil && modified(article, item) {
+			changed = append(changed, feedUpdate{item: item, article: article})
+		}
+	}
+
+	Ordered(ctx, h.pool, workers, changed, func(ctx context.Context, u feedUpdate) feedUpdate {
+		var err error
+		if u.next, err = h.article(ctx, site, u.item); err != nil && !errs.IsCanceledOrDeadline(err) {
+			h.logger.Warn("error due to rebuild updated feed item", "err", err, "item", u.item)
+		}
+		return u
+	}, func(u feedUpdate) bool {
+		if u.next != nil {
+			h.updateArticle(ctx, run, u.article, u.next)
+		}
+		return true
+	})
+}
 
-	for iter.Next(ctx) {
-		article := iter.Entity()
+type feedUpdate struct {
+	item    *gofeed.Item
+	article *entity.Article
+	next    *entity.Article
+}
 
-		if i, ok := seen[article.Link]; ok {
-			return i, nil
+// modified reports whether the feed item changed since the article was stored.
+func modified(article *entity.Article, item *gofeed.Item) bool {
+	if item.UpdatedParsed != nil {
+		last := article.CreatedAt
+		if article.SourceUpdatedAt != nil {
+			last = *article.SourceUpdatedAt
+		}
+		if item.UpdatedParsed.After(last) {
+			return true
 		}
 	}
+	return article.Digest != "" && article.Digest != itemDigest(item)
+}
 
-	return -1, nil
+func itemDigest(item *gofeed.Item) string {
+	var image string
+	if item.Image != nil {
+		image = item.Image.URL
+	}
+	return checksum([]byte(item.Title + "\n" + item.Description + "\n" + image))
 }
 
-func (h *HandlerJobFeed) parseOpengraphMeta(ctx context.Context, link string) (*opengraph.OpenGraph, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
+func (h *HandlerJobFeed) updateArticle(ctx context.Context, run *Run, article, next *entity.Article) {
+	prevTitle := article.Title
+	changes := article.Revise(next)
 
-	og, err := openGraphFetch(ctx, link)
-	if err != nil {
-		return nil, fmt.Errorf("%s error: %w", OpServerParseArticle, err)
+	article.Hash = next.Hash
+	article.Digest = next.Digest
+	article.SourceUpdatedAt = next.SourceUpdatedAt
+
+	if err := h.articleRepo.Save(ctx, article); err != nil {
+		if !errs.IsCanceledOrDeadline(err) {
+			h.logger.Error("error due to update article", "err", err, "article", article)
+		}
+		return
+	}
+
+	if len(changes) == 0 {
+		return
 	}
 
-	h.logger.Debug("article link parsed", "article", og)
+	h.logger.Debug("article updated", "article", article, "changes", changes)
 
-	return og, nil
+	run.Updated()
+
+	update := model.ArticleUpdate{Article: model.ArticleFromEntity(article), Changes: changes}
+	if prevTitle != article.Title {
+		update.PrevTitle = prevTitle
+	}
+
+	h.publisher.ArticleUpdated(ctx, update)
+}
+
+// published reports whether an article with the same title was added to the site within the hash window.
+func (h *HandlerJobFeed) published(ctx context.Context, site *entity.Site, title string) bool {
+	window := h.dedup.HashWindow()
+	if title == "" || window <= 0 {
+		return false
+	}
+
+	n, err := h.articleRepo.Count(ctx, bson.M{
+		"content_hash": contentHash(site.ID, title),
+		"created_at":   bson.M{"$gte": time.Now().Add(-window)},
+	})
+	return err == nil && n > 0
+}
+
+// itemKey is the stable identity of a feed item, the guid when present, otherwise the normalized link.
+func (h *HandlerJobFeed) itemKey(item *gofeed.Item) string {
+	if guid := strings.TrimSpace(item.GUID); guid != "" {
+		return "guid:" + guid
+	}
+	return "link:" + h.fetcher.Canonical(item.Link, nil)
 }
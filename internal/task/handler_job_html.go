@@ -0,0 +1,197 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/goccy/go-json"
+	"github.com/hibiken/asynq"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var imageAttrs = []string{"src", "data-src", "data-lazy-src", "data-original"}
+
+type HandlerJobHTML struct {
+	listing
+}
+
+// CheckSelectors reports the first CSS selector of the payload which is not valid.
+func CheckSelectors(payload *entity.HTMLPayload) error {
+	selectors := []*string{&payload.ItemSelector, payload.LinkSelector, payload.TitleSelector, payload.DateSelector, payload.ImageSelector, payload.NextSelector}
+
+	for _, selector := range selectors {
+		if selector == nil || *selector == "" {
+			continue
+		}
+		if _, err := cascadia.ParseGroup(*selector); err != nil {
+			return fmt.Errorf("selector %s is not valid: %w", *selector, err)
+		}
+	}
+	return nil
+}
+
+func (h *HandlerJobHTML) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	if task.Payload() == nil {
+		h.logger.Warn("task payload is empty")
+		return nil
+	}
+
+	var payload entity.HTMLPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		h.logger.Error("error due to unmarshal html payload", "err", err, "payload", task.Payload())
+		return nil
+	}
+
+	ctx, r, err := h.begin(ctx, listingJob{
+		name:    entity.JobHTML,
+		jobID:   payload.JobID,
+		siteID:  payload.SiteID,
+		link:    payload.Link,
+		lang:    payload.Lang,
+		extract: payload.Extract,
+		rules:   payload.Rules,
+		force:   payload.Force,
+		workers: payload.WorkersCount(),
+		pages:   payload.PagesCount(),
+	})
+	if r == nil {
+		return err
+	}
+	defer h.runs.Finish(r.run)
+
+	if err = CheckSelectors(&payload); err != nil {
+		r.run.Fail(err)
+		h.logger.Error("error due to check html payload", "err", err, "payload", payload)
+		return nil
+	}
+
+	res, ok := h.fetch(ctx, r, payload.Link)
+	if !ok {
+		return nil
+	}
+
+	items, err := h.pages(ctx, r, payload.Link, res, func(_ context.Context, link string, res *fetched) (*listingPage, error) {
+		return parseListing(res.body, res.contentType, link, payload)
+	})
+
+	return h.end(ctx, r, items, res.state, err)
+}
+
+// parseListing scrapes the items of a listing page, links are resolved against the page link or its <base>.
+func parseListing(body []byte, contentType, link string, payload entity.HTMLPayload) (*listingPage, error) {
+	doc, err := goquery.NewDocumentFromReader(decode(body, contentType))
+	if err != nil {
+		return nil, fmt.Errorf("%s error: %w", OpServerParseListing, err)
+	}
+
+	base, err := url.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("%s error: %w", OpServerParseListing, err)
+	}
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if u, err := base.Parse(strings.TrimSpace(href)); err == nil {
+			base = u
+		}
+	}
+
+	resolve := func(ref string) string {
+		if ref = strings.TrimSpace(ref); ref == "" || strings.HasPrefix(ref, "#") {
+			return ""
+		}
+		u, err := base.Parse(ref)
+		if err != nil {
+			return ""
+		}
+		u.Fragment = ""
+		return u.String()
+	}
+
+	page := &listingPage{}
+	seen := make(map[string]struct{})
+
+	doc.Find(payload.ItemSelector).Each(func(_ int, s *goquery.Selection) {
+		a := anchor(s, payload.LinkSelector)
+
+		item := listingItem{link: resolve(a.AttrOr("href", ""))}
+		if item.link == "" {
+			return
+		}
+		if _, ok := seen[item.link]; ok {
+			return
+		}
+		seen[item.link] = struct{}{}
+
+		if payload.TitleSelector != nil && *payload.TitleSelector != "" {
+			item.title = text(s.Find(*payload.TitleSelector).First())
+		} else {
+			item.title = text(a)
+		}
+
+		if payload.DateSelector != nil && *payload.DateSelector != "" {
+			item.date = listingDate(s.Find(*payload.DateSelector).First(), payload.DateFormat)
+		}
+
+		if payload.ImageSelector != nil && *payload.ImageSelector != "" {
+			item.image = resolve(image(s.Find(*payload.ImageSelector).First()))
+		}
+
+		page.items = append(page.items, item)
+	})
+
+	if payload.NextSelector != nil && *payload.NextSelector != "" {
+		page.next = resolve(anchor(doc.Find(*payload.NextSelector).First(), nil).AttrOr("href", ""))
+	}
+
+	return page, nil
+}
+
+// anchor returns the element holding the link of the item, the item itself when it is a link,
+// otherwise the first link inside the item or inside the element matched by the selector.
+func anchor(s *goquery.Selection, selector *string) *goquery.Selection {
+	if selector != nil && *selector != "" {
+		s = s.Find(*selector).First()
+	}
+	if _, ok := s.Attr("href"); ok {
+		return s
+	}
+	return s.Find("a[href]").First()
+}
+
+// image returns the source of the image element, lazy loaded images keep it in data attributes or srcset.
+func image(s *goquery.Selection) string {
+	if !s.Is("img, source") {
+		if img := s.Find("img").First(); img.Length() > 0 {
+			s = img
+		}
+	}
+
+	for _, name := range imageAttrs {
+		if src := strings.TrimSpace(s.AttrOr(name, "")); src != "" && !strings.HasPrefix(src, "data:") {
+			return src
+		}
+	}
+
+	if srcset := strings.TrimSpace(s.AttrOr("srcset", "")); srcset != "" {
+		if fields := strings.Fields(strings.Split(srcset, ",")[0]); len(fields) > 0 {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+// listingDate parses the datetime attribute of the element or its text.
+func listingDate(s *goquery.Selection, layout *string) *time.Time {
+	value := strings.TrimSpace(s.AttrOr("datetime", ""))
+	if value == "" {
+		value = text(s)
+	}
+	return parseDate(value, layout)
+}
+
+func text(s *goquery.Selection) string {
+	return strings.Join(strings.Fields(s.Text()), " ")
+}
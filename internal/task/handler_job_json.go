@@ -0,0 +1,257 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/hibiken/asynq"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type HandlerJobJSON struct {
+	listing
+}
+
+func (h *HandlerJobJSON) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	if task.Payload() == nil {
+		h.logger.Warn("task payload is empty")
+		return nil
+	}
+
+	var payload entity.JSONPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		h.logger.Error("error due to unmarshal json payload", "err", err, "payload", task.Payload())
+		return nil
+	}
+
+	ctx, r, err := h.begin(ctx, listingJob{
+		name:    entity.JobJSON,
+		jobID:   payload.JobID,
+		siteID:  payload.SiteID,
+		link:    payload.Link,
+		lang:    payload.Lang,
+		extract: payload.Extract,
+		rules:   payload.Rules,
+		force:   payload.Force,
+		workers: payload.WorkersCount(),
+		pages:   payload.PagesCount(),
+		headers: jsonHeaders(payload.Headers),
+	})
+	if r == nil {
+		return err
+	}
+	defer h.runs.Finish(r.run)
+
+	res, ok := h.fetch(ctx, r, payload.Link)
+	if !ok {
+		return nil
+	}
+
+	items, err := h.pages(ctx, r, payload.Link, res, func(_ context.Context, link string, res *fetched) (*listingPage, error) {
+		return parseJSONPage(res.body, link, payload)
+	})
+
+	return h.end(ctx, r, items, res.state, err)
+}
+
+func jsonHeaders(headers map[string]string) map[string]string {
+	result := map[string]string{"Accept": "application/json"}
+	for key, value := range headers {
+		result[key] = value
+	}
+	return result
+}
+
+// parseJSONPage maps the items of an API response to the article fields, links are resolved against the API link.
+func parseJSONPage(body []byte, link string, payload entity.JSONPayload) (*listingPage, error) {
+	var root any
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&root); err != nil {
+		return nil, fmt.Errorf("%s error: %w", OpServerParseJSON, err)
+	}
+
+	base, err := url.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("%s error: %w", OpServerParseJSON, err)
+	}
+
+	resolve := func(ref string) string {
+		if ref = strings.TrimSpace(ref); ref == "" {
+			return ""
+		}
+		u, err := base.Parse(ref)
+		if err != nil {
+			return ""
+		}
+		return u.String()
+	}
+
+	m := payload.Mapping
+	page := &listingPage{}
+	seen := make(map[string]struct{})
+
+	for _, value := range flatten(lookup(root, m.Items)) {
+		item := listingItem{link: resolve(first(value, m.Link))}
+		if item.link == "" {
+			continue
+		}
+		if _, ok := seen[item.link]; ok {
+			continue
+		}
+		seen[item.link] = struct{}{}
+
+		item.title = first(value, m.Title)
+		item.desc = first(value, m.Desc)
+		item.image = resolve(first(value, m.Image))
+		item.date = jsonDate(value, m.Date, m.DateFormat)
+
+		if m.Categories != "" {
+			for _, category := range flatten(lookup(value, m.Categories)) {
+				if c := scalar(category); c != "" {
+					item.categories = append(item.categories, c)
+				}
+			}
+		}
+
+		page.items = append(page.items, item)
+	}
+
+	page.next = nextJSONPage(root, base, payload)
+
+	return page, nil
+}
+
+// nextJSONPage returns the link of the page following the one of the base link.
+func nextJSONPage(root any, base *url.URL, payload entity.JSONPayload) string {
+	if !payload.Paged() {
+		return ""
+	}
+
+	next := *base
+	query := next.Query()
+
+	if payload.CursorParam != nil && *payload.CursorParam != "" && payload.CursorPath != nil && *payload.CursorPath != "" {
+		cursor := first(root, *payload.CursorPath)
+		if cursor == "" {
+			return ""
+		}
+		query.Set(*payload.CursorParam, cursor)
+	} else {
+		current, err := strconv.Atoi(query.Get(*payload.PageParam))
+		if err != nil || current < 1 {
+			current = 1
+		}
+		query.Set(*payload.PageParam, strconv.Itoa(current+1))
+	}
+
+	next.RawQuery = query.Encode()
+	return next.String()
+}
+
+// lookup returns the values found at the path, * iterates an array, an empty path returns the value itself.
+func lookup(value any, path string) []any {
+	if value == nil {
+		return nil
+	}
+
+	path = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(path), "$"), ".")
+	if path == "" {
+		return []any{value}
+	}
+
+	values := []any{value}
+	for _, key := range strings.Split(path, ".") {
+		next := make([]any, 0, len(values))
+		for _, v := range values {
+			switch t := v.(type) {
+			case map[string]any:
+				if child, ok := t[key]; ok && child != nil {
+					next = append(next, child)
+				}
+			case []any:
+				if key == "*" {
+					for _, child := range t {
+						if child != nil {
+							next = append(next, child)
+						}
+					}
+				} else if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(t) && t[i] != nil {
+					next = append(next, t[i])
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+// flatten unwraps the arrays found by a path into their elements.
+func flatten(values []any) []any {
+	result := make([]any, 0, len(values))
+	for _, value := range values {
+		if arr, ok := value.([]any); ok {
+			result = append(result, arr...)
+		} else {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// first returns the first scalar value found at the path.
+func first(value any, path string) string {
+	if path == "" {
+		return ""
+	}
+	for _, v := range flatten(lookup(value, path)) {
+		if s := scalar(v); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func scalar(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// jsonDate parses the date found at the path, numbers are unix timestamps in seconds or milliseconds.
+func jsonDate(value any, path, layout string) *time.Time {
+	if path == "" {
+		return nil
+	}
+
+	for _, v := range lookup(value, path) {
+		if n, ok := v.(json.Number); ok {
+			ts, err := n.Int64()
+			if err != nil || ts <= 0 {
+				return nil
+			}
+			var date time.Time
+			if ts > 1e12 {
+				date = time.UnixMilli(ts)
+			} else {
+				date = time.Unix(ts, 0)
+			}
+			return &date
+		}
+		return parseDate(scalar(v), &layout)
+	}
+	return nil
+}
@@ -1,34 +1,26 @@
 package task
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"github.com/abadojack/whatlanggo"
 	"github.com/goccy/go-json"
 	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
-	"github.com/otiai10/opengraph/v2"
 	"github.com/oxffaa/gopher-parse-sitemap"
-	"github.com/rumorsflow/rumors/v2/internal/common"
 	"github.com/rumorsflow/rumors/v2/internal/db"
 	"github.com/rumorsflow/rumors/v2/internal/entity"
-	"github.com/rumorsflow/rumors/v2/internal/model"
 	"github.com/rumorsflow/rumors/v2/pkg/errs"
 	"github.com/rumorsflow/rumors/v2/pkg/repository"
-	"github.com/rumorsflow/rumors/v2/pkg/util"
-	"golang.org/x/exp/slog"
 	"io"
-	"strings"
 	"time"
-	"unicode/utf8"
 )
 
 type HandlerJobSitemap struct {
-	logger      *slog.Logger
-	publisher   common.Pub
-	siteRepo    repository.ReadRepository[*entity.Site]
-	articleRepo repository.ReadWriteRepository[*entity.Article]
+	listing
+	client *Client
+	groups *Groups
 }
 
 func (h *HandlerJobSitemap) ProcessTask(ctx context.Context, task *asynq.Task) error {
@@ -43,193 +35,378 @@ func (h *HandlerJobSitemap) ProcessTask(ctx context.Context, task *asynq.Task) e
 		return nil
 	}
 
+	if !payload.IsChild() && !payload.Force && h.runs.Deferred(ctx, payload.JobID) {
+		h.logger.Debug("job is backed off, run skipped", "job_id", payload.JobID)
+		return nil
+	}
+
+	run := h.runs.Start(ctx, entity.JobSitemap, payload.JobID, payload.SiteID, payload.Link, payload.Group)
+	defer h.runs.Finish(run)
+
 	site, err := h.siteRepo.FindByID(ctx, payload.SiteID)
 	if err != nil {
+		run.Fail(err)
+
 		if errors.Is(err, repository.ErrEntityNotFound) {
 			h.logger.Error("error due to find site", "err", err, "id", payload.SiteID)
+			h.finish(payload, false)
 			return nil
 		}
 		return fmt.Errorf("%s find site %v error: %w", OpServerProcessTask, payload.SiteID, err)
 	}
 
+	if site.RespectsRobots() {
+		ctx = WithRobots(ctx)
+	}
+
+	if site.Extracts(payload.Extract) {
+		ctx = WithExtract(ctx)
+	}
+
+	ctx = WithRules(ctx, payload.Rules)
+
 	if payload.Lang == nil || *payload.Lang == "" {
 		if len(site.Languages) > 0 {
 			payload.Lang = &site.Languages[0]
 		} else {
 			h.logger.Warn("fallback language not found", "payload", payload)
+			run.Fail(errors.New("fallback language not found"))
+			h.finish(payload, false)
 			return nil
 		}
 	}
 
 	if payload.MatchLoc != nil && *payload.MatchLoc != "" {
 		if err = addRegex(payload.MatchLoc); err != nil {
+			run.Fail(err)
 			return fmt.Errorf("%s site %v -> compile payload regex match location error: %w", OpServerProcessTask, payload.SiteID, err)
 		}
 	}
 
 	if payload.SearchLoc != nil && *payload.SearchLoc != "" {
 		if err = addRegex(payload.SearchLoc); err != nil {
+			run.Fail(err)
 			return fmt.Errorf("%s site %v -> compile payload regex search location error: %w", OpServerProcessTask, payload.SiteID, err)
 		}
 	}
 
-	if payload.IsIndex() {
-		if err = sitemap.ParseIndexFromSite(ctx, payload.Link, func(e sitemap.IndexEntry) error {
-			payload.Link = e.GetLocation()
-			return h.process(ctx, payload, site)
-		}); !errs.IsCanceledOrDeadline(err) && !errors.Is(err, io.EOF) {
-			return fmt.Errorf("%s %w", OpServerProcessTask, err)
+	var state *entity.FetchState
+	if !payload.IsChild() {
+		state = fetchState(ctx, h.jobRepo, payload.JobID)
+	}
+
+	res, err := h.fetch(ctx, payload.Link, state)
+	run.Fetched(res)
+
+	if err != nil {
+		run.Fail(err)
+
+		if !errs.IsCanceledOrDeadline(err) {
+			h.logger.Error("error due to fetch sitemap", "err", err, "site_id", payload.SiteID, "sitemap_link", payload.Link)
 		}
+		h.finish(payload, false)
 		return nil
 	}
 
-	if err = h.process(ctx, payload, site); !errs.IsCanceledOrDeadline(err) && !errors.Is(err, io.EOF) {
-		return fmt.Errorf("%s %w", OpServerProcessTask, err)
+	if res.unchanged {
+		h.logger.Debug("sitemap not modified", "site_id", payload.SiteID, "sitemap_link", payload.Link)
+		h.saveFetchState(ctx, payload.JobID, res.state)
+		return nil
 	}
-	return nil
-}
 
-func (h *HandlerJobSitemap) process(ctx context.Context, payload entity.SitemapPayload, site *entity.Site) error {
-	if err := sitemap.ParseFromSite(ctx, payload.Link, func(e sitemap.Entry) error {
-		if matchByLoc(payload.MatchLoc, e.GetLocation()) {
-			if search := searchByLoc(payload.SearchLoc, e.GetLocation()); search != "" {
-				if payload.SearchLink != nil && *payload.SearchLink != "" {
-					search = fmt.Sprintf(*payload.SearchLink, search)
-				}
+	var failed int
+	if payload.IsIndex() {
+		err = h.fanOut(ctx, run, payload, res.body)
+	} else {
+		failed, err = h.process(ctx, run, payload, site, res.body)
+	}
 
-				if h.articleExists(ctx, site, search) {
-					if payload.StoppingOnDup() {
-						return io.EOF
-					}
-					return nil
-				}
-			}
+	if err == nil || errors.Is(err, io.EOF) {
+		if payload.IsChild() {
+			h.finish(payload, failed == 0)
+		} else {
+			h.commitFetchState(ctx, payload.JobID, res.state, failed)
+		}
+		return nil
+	}
 
-			err := h.processEntry(ctx, e, site, *payload.Lang)
-			if errors.Is(err, io.EOF) && !payload.StoppingOnDup() {
-				return nil
-			}
+	run.Fail(err)
 
-			return err
-		}
+	if errs.IsCanceledOrDeadline(err) {
+		h.finish(payload, false)
 		return nil
-	}); err != nil {
-		return fmt.Errorf("%s error: %w", OpServerParseSitemap, err)
 	}
-	return nil
+
+	if lastAttempt(ctx) {
+		h.finish(payload, false)
+	}
+
+	return fmt.Errorf("%s %w", OpServerProcessTask, err)
 }
 
-func (h *HandlerJobSitemap) processEntry(ctx context.Context, entry sitemap.Entry, site *entity.Site, fallbackLang string) error {
-	og, err := h.parseOpengraphMeta(ctx, entry.GetLocation())
-	if err != nil {
-		if errs.IsCanceledOrDeadline(err) {
-			return err
+// fanOut enqueues a child task per sitemap of the index, sitemaps not modified since the last run are skipped.
+func (h *HandlerJobSitemap) fanOut(ctx context.Context, run *Run, payload entity.SitemapPayload, body []byte) error {
+	group, _ := asynq.GetTaskID(ctx)
+	if group == "" {
+		group = uuid.NewString()
+	}
+
+	scope := lastModScope(payload)
+
+	var (
+		children []entity.SitemapPayload
+		skipped  int
+	)
+
+	if err := sitemap.ParseIndex(ctx, bytes.NewReader(body), func(e sitemap.IndexEntry) error {
+		lastMod := e.GetLastModified()
+		if lastMod != nil && !h.groups.Modified(ctx, scope, e.GetLocation(), *lastMod) {
+			skipped++
+			return nil
 		}
 
-		h.logger.Error("error due to parse sitemap location", "err", fmt.Errorf("%s %w", OpServerProcessTask, err), "entry", entry)
+		child := payload
+		child.Link = e.GetLocation()
+		child.Index = nil
+		child.Group = group
+		child.LastMod = lastMod
 
+		children = append(children, child)
 		return nil
+	}); err != nil {
+		return fmt.Errorf("%s error: %w", OpServerParseSitemap, err)
 	}
 
-	article := &entity.Article{
-		ID:     uuid.New(),
-		SiteID: site.ID,
-		Source: entity.SitemapSource,
-		Link:   entry.GetLocation(),
-		Title:  util.StripHTMLTags(og.Title),
-	}
+	run.Seen(len(children) + skipped)
 
-	if date := entry.GetLastModified(); date != nil {
-		article.PubDate = *date
+	if err := h.groups.Start(ctx, group, payload.JobID, len(children), skipped); err != nil {
+		h.logger.Error("error due to start sitemap group", "err", err, "group", group)
 	}
 
-	if entry.GetNews() != nil {
-		article.Title = entry.GetNews().Title
-		article.Lang = entry.GetNews().Publication.Language
-		if date := entry.GetNews().GetPublicationDate(); date != nil {
-			article.PubDate = *date
+	options := h.childOptions(ctx, payload.JobID)
+
+	for _, child := range children {
+		taskID := asynq.TaskID(group + ":" + checksum([]byte(child.Link))[:16])
+
+		if err := h.client.Enqueue(ctx, string(entity.JobSitemap), child, append(options, taskID)...); err != nil {
+			if errors.Is(err, asynq.ErrTaskIDConflict) {
+				continue
+			}
+			if errs.IsCanceledOrDeadline(err) {
+				return err
+			}
+
+			h.logger.Error("error due to enqueue child sitemap", "err", err, "group", group, "sitemap_link", child.Link)
+
+			if err = h.groups.Incr(ctx, group, groupFailed); err != nil {
+				h.logger.Error("error due to update sitemap group", "err", err, "group", group)
+			}
 		}
 	}
 
-	if article.Title == "" {
-		h.logger.Warn("article title not found", "entry", entry, "og", og)
-		return nil
+	h.logger.Debug("sitemap index fanned out", "group", group, "children", len(children), "skipped", skipped)
+
+	return nil
+}
+
+// childOptions inherits the queue and retry policy of the running task and the options of its job.
+func (h *HandlerJobSitemap) childOptions(ctx context.Context, jobID *uuid.UUID) []asynq.Option {
+	var options []asynq.Option
+
+	if queue, ok := asynq.GetQueueName(ctx); ok {
+		options = append(options, asynq.Queue(queue))
+	}
+	if maxRetry, ok := asynq.GetMaxRetry(ctx); ok {
+		options = append(options, asynq.MaxRetry(maxRetry))
 	}
 
-	if article.PubDate.IsZero() {
-		article.PubDate = time.Now()
+	if jobID == nil {
+		return options
 	}
 
-	if desc := util.StripHTMLTags(og.Description); utf8.RuneCountInString(desc) >= minShortDesc && !strings.EqualFold(article.Title, desc) {
-		article.SetDesc(desc)
+	job, err := h.jobRepo.FindByID(ctx, *jobID)
+	if err != nil || job.Options == nil {
+		return options
 	}
 
-	media := toMedia(og)
-	if len(og.Image) == 0 {
-		for _, i := range entry.GetImages() {
-			media = append(media, entity.Media{URL: i.ImageLocation, Type: entity.ImageType, Meta: map[string]any{
-				"alt": i.ImageTitle,
-			}})
+	for _, o := range *job.Options {
+		switch o.Type {
+		case entity.TaskIDOpt, entity.ProcessAtOpt, entity.ProcessInOpt:
+			continue
 		}
+		options = append(options, Option(o))
 	}
-	if len(media) > 0 {
-		article.SetMedia(media)
+
+	return options
+}
+
+// finish records the outcome of a child task in its group.
+func (h *HandlerJobSitemap) finish(payload entity.SitemapPayload, ok bool) {
+	if !payload.IsChild() {
+		return
 	}
 
-	if article.Lang == "" && article.Desc != nil {
-		article.Lang = whatlanggo.DetectLang(article.Title + " " + *article.Desc).Iso6391()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	field := groupFailed
+	if ok {
+		field = groupDone
+
+		if payload.LastMod != nil {
+			if err := h.groups.SetLastMod(ctx, lastModScope(payload), payload.Link, *payload.LastMod); err != nil {
+				h.logger.Error("error due to save sitemap lastmod", "err", err, "sitemap_link", payload.Link)
+			}
+		}
 	}
 
-	if !contains(site.Languages, article.Lang) {
-		article.Lang = fallbackLang
+	if err := h.groups.Incr(ctx, payload.Group, field); err != nil {
+		h.logger.Error("error due to update sitemap group", "err", err, "group", payload.Group)
 	}
+}
 
-	return h.saveArticle(ctx, article)
+func lastModScope(payload entity.SitemapPayload) string {
+	if payload.JobID != nil {
+		return payload.JobID.String()
+	}
+	return payload.SiteID.String()
 }
 
-func (h *HandlerJobSitemap) articleExists(ctx context.Context, site *entity.Site, search string) bool {
-	query := fmt.Sprintf("field.0.0=site_id&value.0.0=%s&field.1.0=link&cond.1.0=like&value.1.0=%s", site.ID, search)
-	criteria := db.BuildCriteria(query)
-	if n, err := h.articleRepo.Count(ctx, criteria.Filter); err == nil && n > 0 {
-		return true
+func (h *HandlerJobSitemap) fetch(ctx context.Context, link string, state *entity.FetchState) (*fetched, error) {
+	res, err := h.fetcher.Conditional(ctx, link, state)
+	if err != nil {
+		return res, fmt.Errorf("%s error: %w", OpServerParseSitemap, err)
 	}
-	return false
+
+	return res, nil
 }
 
-func (h *HandlerJobSitemap) saveArticle(ctx context.Context, article *entity.Article) error {
-	if err := h.articleRepo.Save(ctx, article); err != nil {
-		if errs.IsCanceledOrDeadline(err) {
-			return err
+// process returns the number of the entries failed for a transient reason.
+func (h *HandlerJobSitemap) process(ctx context.Context, run *Run, payload entity.SitemapPayload, site *entity.Site, body []byte) (int, error) {
+	var entries []sitemap.Entry
+
+	if err := sitemap.Parse(ctx, bytes.NewReader(body), func(e sitemap.Entry) error {
+		if matchByLoc(payload.MatchLoc, e.GetLocation()) {
+			if search := searchByLoc(payload.SearchLoc, e.GetLocation()); search != "" {
+				if payload.SearchLink != nil && *payload.SearchLink != "" {
+					search = fmt.Sprintf(*payload.SearchLink, search)
+				}
+
+				if h.articleExists(ctx, site, search) {
+					run.Seen(1)
+					run.Duplicates(1)
+
+					if payload.StoppingOnDup() {
+						return io.EOF
+					}
+					return nil
+				}
+			}
+
+			entries = append(entries, e)
 		}
+		return nil
+	}); err != nil && !errors.Is(err, io.EOF) {
+		return 0, fmt.Errorf("%s error: %w", OpServerParseSitemap, err)
+	}
 
-		if errors.Is(err, repository.ErrDuplicateKey) {
-			h.logger.Debug("error due to save article, duplicate key", "article", article)
+	run.Seen(len(entries))
 
-			return io.EOF
-		} else {
-			h.logger.Error("error due to save article", "err", err, "article", article)
+	var (
+		err    error
+		failed int
+	)
+
+	Ordered(ctx, h.pool, payload.WorkersCount(), entries, func(ctx context.Context, e sitemap.Entry) itemResult {
+		article, failed := h.processEntry(ctx, run, e, site, *payload.Lang)
+		return itemResult{article: article, failed: failed}
+	}, func(r itemResult) bool {
+		if r.article == nil {
+			if r.failed {
+				failed++
+			}
+			return true
+		}
+		switch err = h.save(ctx, run, r.article); {
+		case err == nil, errs.IsCanceledOrDeadline(err):
+		case errors.Is(err, io.EOF):
+			if !payload.StoppingOnDup() {
+				err = nil
+			}
+		default:
+			failed++
+			err = nil
 		}
+		return err == nil
+	})
 
-		return nil
+	if err == nil {
+		err = ctx.Err()
 	}
 
-	h.logger.Debug("article saved", "article", article)
+	return failed, err
+}
 
-	h.publisher.Articles(ctx, []model.Article{model.ArticleFromEntity(article)})
+// processEntry returns the article of the entry, it reports whether the entry failed for a transient reason.
+func (h *HandlerJobSitemap) processEntry(ctx context.Context, run *Run, entry sitemap.Entry, site *entity.Site, fallbackLang string) (*entity.Article, bool) {
+	article, err := h.article(ctx, entry, site, fallbackLang)
+	if err != nil {
+		var filtered *FilteredError
+
+		switch {
+		case errs.IsCanceledOrDeadline(err):
+			return nil, true
+		case errors.Is(err, ErrItemSkipped):
+			h.logger.Warn("sitemap entry skipped", "err", err, "entry", entry)
+		case errors.As(err, &filtered):
+			run.Filtered()
+			h.logger.Debug("sitemap entry filtered", "reason", filtered.Reason, "link", entry.GetLocation())
+			if err = saveFiltered(ctx, h.filteredRepo, run.JobID(), filtered); err != nil {
+				h.logger.Error("error due to save filtered sitemap entry", "err", err)
+			}
+		default:
+			run.OGFailure()
+			h.logger.Error("error due to parse sitemap location", "err", fmt.Errorf("%s %w", OpServerProcessTask, err), "entry", entry)
+			return nil, true
+		}
+		return nil, false
+	}
 
-	return nil
+	return article, false
 }
 
-func (h *HandlerJobSitemap) parseOpengraphMeta(ctx context.Context, link string) (*opengraph.OpenGraph, error) {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
+func (h *HandlerJobSitemap) article(ctx context.Context, entry sitemap.Entry, site *entity.Site, fallbackLang string) (*entity.Article, error) {
+	draft := &ArticleDraft{
+		Site:         site,
+		Source:       entity.SitemapSource,
+		Link:         entry.GetLocation(),
+		FallbackLang: fallbackLang,
+		PubDate:      entry.GetLastModified(),
+	}
 
-	og, err := openGraphFetch(ctx, link)
-	if err != nil {
-		return nil, fmt.Errorf("%s error: %w", OpServerParseArticle, err)
+	if news := entry.GetNews(); news != nil {
+		draft.Title = news.Title
+		draft.Lang = news.Publication.Language
+		draft.Categories = []string{news.Keywords}
+		if date := news.GetPublicationDate(); date != nil {
+			draft.PubDate = date
+		}
+	}
+
+	for _, i := range entry.GetImages() {
+		draft.Media = append(draft.Media, entity.Media{URL: i.ImageLocation, Type: entity.ImageType, Meta: map[string]any{
+			"alt": i.ImageTitle,
+		}})
 	}
 
-	h.logger.Debug("article link parsed", "article", og)
+	return h.processor.Process(ctx, draft)
+}
 
-	return og, nil
+func (h *HandlerJobSitemap) articleExists(ctx context.Context, site *entity.Site, search string) bool {
+	query := fmt.Sprintf("field.0.0=site_id&value.0.0=%s&field.1.0=link&cond.1.0=like&value.1.0=%s", site.ID, search)
+	criteria := db.BuildCriteria(query)
+	if n, err := h.articleRepo.Count(ctx, criteria.Filter); err == nil && n > 0 {
+		return true
+	}
+	return false
 }
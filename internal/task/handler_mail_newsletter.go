@@ -0,0 +1,397 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/rumorsflow/rumors/v2/pkg/repository"
+	"golang.org/x/net/html/charset"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxMailDepth   = 10
+	maxMailStories = 30
+	minStoryTitle  = 20
+)
+
+var (
+	webVersion  = regexp.MustCompile(`(?i)\b(view|read|open|see)\b.{0,30}\b(browser|online|web)\b|\bweb version\b`)
+	mailService = regexp.MustCompile(`(?i)unsubscribe|opt[-_ ]?out|preferences|manage.{0,20}subscription|update.{0,20}profile|privacy|forward.{0,20}friend`)
+	textLink    = regexp.MustCompile(`https?://[^\s<>"]+`)
+	socialHosts = []string{
+		"facebook.com", "twitter.com", "x.com", "instagram.com", "linkedin.com", "youtube.com",
+		"tiktok.com", "pinterest.com", "t.me", "wa.me", "threads.net", "reddit.com",
+	}
+)
+
+// NewsletterPayload is a mail received for the site, either the newsletter becomes an article
+// or each story linked by it does.
+type NewsletterPayload struct {
+	SiteID  uuid.UUID `json:"site_id"`
+	Stories bool      `json:"stories,omitempty"`
+	Message []byte    `json:"message"`
+}
+
+// HandlerMailNewsletter publishes the newsletters received by mail as the articles of the site of the recipient.
+type HandlerMailNewsletter struct {
+	listing
+}
+
+func (h *HandlerMailNewsletter) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	var payload NewsletterPayload
+	if err := unmarshal(task.Payload(), &payload); err != nil {
+		h.logger.Error("error due to unmarshal task payload", "err", err)
+		return nil
+	}
+
+	site, err := h.siteRepo.FindByID(ctx, payload.SiteID)
+	if err != nil {
+		if errors.Is(err, repository.ErrEntityNotFound) {
+			h.logger.Error("error due to find site", "err", err, "id", payload.SiteID)
+			return nil
+		}
+		return fmt.Errorf("%s find site %v error: %w", OpServerProcessTask, payload.SiteID, err)
+	}
+
+	if site.Enabled != nil && !*site.Enabled {
+		h.logger.Debug("newsletter skipped, the site is disabled", "site_id", site.ID)
+		return nil
+	}
+
+	lang, err := fallbackLang(nil, site)
+	if err != nil {
+		h.logger.Warn("fallback language not found", "site_id", site.ID)
+		return nil
+	}
+
+	letter, err := parseNewsletter(payload.Message)
+	if err != nil {
+		h.logger.Error("error due to parse newsletter", "err", fmt.Errorf("%s error: %w", OpServerParseMail, err), "site_id", site.ID)
+		return nil
+	}
+
+	var items []listingItem
+	if payload.Stories {
+		items = letter.stories()
+	} else if item, ok := letter.item(); ok {
+		items = []listingItem{item}
+	} else {
+		h.logger.Warn("newsletter skipped, its web version not found", "site_id", site.ID, "subject", letter.subject)
+		return nil
+	}
+
+	if len(items) == 0 {
+		h.logger.Debug("newsletter skipped, it links no stories", "site_id", site.ID, "subject", letter.subject)
+		return nil
+	}
+
+	if site.RespectsRobots() {
+		ctx = WithRobots(ctx)
+	}
+
+	if site.Extracts(nil) {
+		ctx = WithExtract(ctx)
+	}
+
+	scope := dedupScope(nil, site.ID)
+
+	h.keys(items)
+
+	if items, err = h.unseen(ctx, nil, scope, items); err != nil {
+		return fmt.Errorf("%s %w", OpServerProcessTask, err)
+	}
+
+	h.process(ctx, nil, scope, 0, site, items, lang)
+
+	return nil
+}
+
+type mailLink struct {
+	link  string
+	text  string
+	image string
+}
+
+type newsletter struct {
+	subject  string
+	date     *time.Time
+	content  string
+	archived string
+	service  []string
+	links    []mailLink
+	images   []string
+}
+
+// item maps the newsletter to an item linking its web version, the archived copy declared by the headers is preferred.
+func (n *newsletter) item() (listingItem, bool) {
+	item := listingItem{link: n.archived, title: n.subject, content: n.content, date: n.date}
+
+	for _, l := range n.links {
+		if item.link != "" {
+			break
+		}
+		if webVersion.MatchString(l.text) {
+			item.link = l.link
+		}
+	}
+
+	if len(n.images) > 0 {
+		item.image = n.images[0]
+	}
+
+	return item, item.link != ""
+}
+
+// stories maps the links of the newsletter to items, the links to the web version, to the mail service
+// and to the social networks are left out. The text of a link is its title when it is long enough to be a headline.
+func (n *newsletter) stories() []listingItem {
+	index := make(map[string]int, len(n.links))
+	items := make([]listingItem, 0, len(n.links))
+
+	for _, l := range n.links {
+		if !n.story(l) {
+			continue
+		}
+
+		title := l.text
+		if utf8.RuneCountInString(title) < minStoryTitle {
+			title = ""
+		}
+
+		if i, ok := index[l.link]; ok {
+			if items[i].title == "" {
+				items[i].title = title
+			}
+			if items[i].image == "" {
+				items[i].image = l.image
+			}
+			continue
+		}
+
+		if len(items) == maxMailStories {
+			continue
+		}
+
+		index[l.link] = len(items)
+		items = append(items, listingItem{link: l.link, title: title, image: l.image, date: n.date})
+	}
+
+	return items
+}
+
+func (n *newsletter) story(l mailLink) bool {
+	if l.link == n.archived || webVersion.MatchString(l.text) || mailService.MatchString(l.text) || mailService.MatchString(l.link) {
+		return false
+	}
+
+	for _, s := range n.service {
+		if l.link == s {
+			return false
+		}
+	}
+
+	u, err := url.Parse(l.link)
+	if err != nil {
+		return false
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	for _, social := range socialHosts {
+		if host == social || strings.HasSuffix(host, "."+social) {
+			return false
+		}
+	}
+	// the home pages of the senders are no stories
+	return (u.Path != "" && u.Path != "/") || u.RawQuery != ""
+}
+
+// parseNewsletter reads the subject, the date and the body of the message, the html body is preferred over the plain one.
+func parseNewsletter(data []byte) (*newsletter, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	dec := &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+	n := &newsletter{subject: msg.Header.Get("Subject")}
+	if subject, err := dec.DecodeHeader(n.subject); err == nil {
+		n.subject = subject
+	}
+	n.subject = strings.Join(strings.Fields(n.subject), " ")
+
+	if date, err := msg.Header.Date(); err == nil {
+		n.date = &date
+	}
+
+	if archived := mailURLs(msg.Header.Get("Archived-At")); len(archived) > 0 {
+		n.archived = archived[0]
+	}
+	n.service = mailURLs(msg.Header.Get("List-Unsubscribe"))
+
+	htmlBody, textBody, err := mailBody(textproto.MIMEHeader(msg.Header), msg.Body, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case htmlBody != "":
+		n.content = htmlBody
+		n.parseHTML()
+	case textBody != "":
+		n.content = strings.ReplaceAll(html.EscapeString(textBody), "\n", "<br>")
+		for _, link := range textLink.FindAllString(textBody, -1) {
+			if link = mailURL(strings.TrimRight(link, ".,;:!?)]")); link != "" {
+				n.links = append(n.links, mailLink{link: link})
+			}
+		}
+	default:
+		return nil, errors.New("message body not found")
+	}
+
+	return n, nil
+}
+
+func (n *newsletter) parseHTML() {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(n.content))
+	if err != nil {
+		return
+	}
+
+	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		link := mailURL(s.AttrOr("href", ""))
+		if link == "" {
+			return
+		}
+
+		l := mailLink{link: link, text: strings.Join(strings.Fields(s.Text()), " ")}
+		s.Find("img[src]").EachWithBreak(func(_ int, img *goquery.Selection) bool {
+			l.image = mailImage(img)
+			return l.image == ""
+		})
+		if l.text == "" {
+			l.text = strings.TrimSpace(s.AttrOr("title", ""))
+		}
+		n.links = append(n.links, l)
+	})
+
+	doc.Find("img[src]").Each(func(_ int, img *goquery.Selection) {
+		if image := mailImage(img); image != "" {
+			n.images = append(n.images, image)
+		}
+	})
+}
+
+// mailBody walks the parts of the message and returns its first html and plain text bodies decoded to UTF-8.
+func mailBody(header textproto.MIMEHeader, body io.Reader, depth int) (htmlBody string, textBody string, err error) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= maxMailDepth || params["boundary"] == "" {
+			return "", "", nil
+		}
+
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if errors.Is(err, io.EOF) {
+				return htmlBody, textBody, nil
+			}
+			if err != nil {
+				return htmlBody, textBody, err
+			}
+
+			if disposition, _, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition")); disposition == "attachment" {
+				continue
+			}
+
+			h, t, err := mailBody(part.Header, part, depth+1)
+			if err != nil {
+				return htmlBody, textBody, err
+			}
+			if htmlBody == "" {
+				htmlBody = h
+			}
+			if textBody == "" {
+				textBody = t
+			}
+		}
+	}
+
+	if mediaType != "text/html" && mediaType != "text/plain" {
+		return "", "", nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", "", err
+	}
+
+	data, err = io.ReadAll(decode(data, header.Get("Content-Type")))
+	if err != nil {
+		return "", "", err
+	}
+
+	if mediaType == "text/html" {
+		return string(data), "", nil
+	}
+	return "", strings.TrimSpace(strings.ReplaceAll(string(data), "\r\n", "\n")), nil
+}
+
+// mailImage returns the link of the image unless it is a tracking pixel.
+func mailImage(img *goquery.Selection) string {
+	for _, attr := range []string{"width", "height"} {
+		if size := strings.TrimSpace(img.AttrOr(attr, "")); size == "0" || size == "1" {
+			return ""
+		}
+	}
+	return mailURL(img.AttrOr("src", ""))
+}
+
+// mailURLs returns the http links of a header listing the links in angle brackets.
+func mailURLs(value string) []string {
+	var links []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if link := mailURL(strings.TrimSuffix(strings.TrimPrefix(part, "<"), ">")); link != "" {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+func mailURL(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	u.Fragment = ""
+	return u.String()
+}
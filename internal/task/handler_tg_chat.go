@@ -115,8 +115,12 @@ func (h *HandlerTgChat) toEntityChat(ctx context.Context, tgChat tgbotapi.Chat)
 }
 
 func (h *HandlerTgChat) find(ctx context.Context, chatID int64) (*entity.Chat, error) {
+	return findChat(ctx, h.chatRepo, chatID)
+}
+
+func findChat(ctx context.Context, repo repository.ReadRepository[*entity.Chat], chatID int64) (*entity.Chat, error) {
 	criteria := db.BuildCriteria(fmt.Sprintf("size=1&field.0.0=telegram_id&value.0.0=%d", chatID))
-	chats, err := h.chatRepo.Find(ctx, criteria)
+	chats, err := repo.Find(ctx, criteria)
 	if err != nil {
 		return nil, err
 	}
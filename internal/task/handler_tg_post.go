@@ -0,0 +1,206 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hibiken/asynq"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/pkg/repository"
+	"golang.org/x/exp/slices"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// HandlerTgPost publishes the posts of the channels linked to a site as the articles of the site.
+type HandlerTgPost struct {
+	listing
+	chatRepo repository.ReadRepository[*entity.Chat]
+}
+
+func (h *HandlerTgPost) ProcessTask(ctx context.Context, task *asynq.Task) error {
+	var message tgbotapi.Message
+	if err := unmarshal(task.Payload(), &message); err != nil {
+		h.logger.Error("error due to unmarshal task payload", "err", err)
+		return nil
+	}
+
+	if message.Chat == nil {
+		return nil
+	}
+
+	chat, err := findChat(ctx, h.chatRepo, message.Chat.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrEntityNotFound) {
+			h.logger.Debug("channel post skipped, the channel is unknown", "telegram_id", message.Chat.ID)
+			return nil
+		}
+		return fmt.Errorf("%s find chat %d error: %w", OpServerProcessTask, message.Chat.ID, err)
+	}
+
+	if !chat.IsSource() || chat.IsBlocked() || chat.IsDeleted() {
+		h.logger.Debug("channel post skipped, the channel is not a source", "telegram_id", message.Chat.ID)
+		return nil
+	}
+
+	site, err := h.siteRepo.FindByID(ctx, *chat.Source)
+	if err != nil {
+		if errors.Is(err, repository.ErrEntityNotFound) {
+			h.logger.Error("error due to find site", "err", err, "id", *chat.Source)
+			return nil
+		}
+		return fmt.Errorf("%s find site %v error: %w", OpServerProcessTask, *chat.Source, err)
+	}
+
+	if site.Enabled != nil && !*site.Enabled {
+		h.logger.Debug("channel post skipped, the site is disabled", "site_id", site.ID)
+		return nil
+	}
+
+	lang, err := fallbackLang(nil, site)
+	if err != nil {
+		h.logger.Warn("fallback language not found", "site_id", site.ID)
+		return nil
+	}
+
+	item := channelPost(&message)
+	if item.title == "" && item.link == postLink(message.Chat, message.MessageID) && len(message.Photo) == 0 {
+		h.logger.Debug("channel post skipped, it has neither text nor link", "telegram_id", message.Chat.ID, "message_id", message.MessageID)
+		return nil
+	}
+
+	if len(message.Photo) > 0 {
+		item.image = h.photo(ctx, &message, item.link)
+	}
+
+	if site.RespectsRobots() {
+		ctx = WithRobots(ctx)
+	}
+
+	if site.Extracts(nil) {
+		ctx = WithExtract(ctx)
+	}
+
+	items := []listingItem{item}
+	scope := dedupScope(nil, site.ID)
+
+	h.keys(items)
+
+	if items, err = h.unseen(ctx, nil, scope, items); err != nil {
+		return fmt.Errorf("%s %w", OpServerProcessTask, err)
+	}
+
+	h.process(ctx, nil, scope, 1, site, items, lang)
+
+	return nil
+}
+
+// photo returns the link of the photo of the post. Telegram serves the files to the bot only,
+// the photos of public channels are found in the meta of the post page. When the post page is the link
+// of the article, the metadata stage of the processor finds the photo itself.
+func (h *HandlerTgPost) photo(ctx context.Context, message *tgbotapi.Message, link string) string {
+	permalink := postLink(message.Chat, message.MessageID)
+	if message.Chat.UserName == "" || link == permalink {
+		return ""
+	}
+
+	og, _, err := h.fetcher.OpenGraph(ctx, permalink)
+	if err != nil {
+		h.logger.Warn("error due to find channel post photo", "err", err, "link", permalink)
+		return ""
+	}
+
+	for _, image := range og.Image {
+		if image.URL != "" {
+			return image.URL
+		}
+	}
+	return ""
+}
+
+// channelPost maps the post to an item, the first line of the text is the title and the rest is the description.
+// The first link of the text is the link of the item, the post itself otherwise. The hashtags are the categories.
+func channelPost(message *tgbotapi.Message) listingItem {
+	text, entities := message.Text, message.Entities
+	if text == "" {
+		text, entities = message.Caption, message.CaptionEntities
+	}
+
+	date := message.Time()
+	permalink := postLink(message.Chat, message.MessageID)
+	item := listingItem{link: permalink, date: &date}
+
+	var written []string
+	units := utf16.Encode([]rune(text))
+
+	for _, e := range entities {
+		switch {
+		case e.Type == "hashtag":
+			if tag := strings.TrimPrefix(entityText(units, e), "#"); tag != "" {
+				item.categories = append(item.categories, tag)
+			}
+		case e.IsTextLink(), e.IsURL():
+			link := e.URL
+			if e.IsURL() {
+				link = entityText(units, e)
+				written = append(written, link)
+			}
+			if link = postURL(link); link != "" && item.link == permalink {
+				item.link = link
+			}
+		}
+	}
+
+	title, desc, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	title, desc = strings.TrimSpace(title), strings.TrimSpace(desc)
+
+	// a title which is a link only is left to the page of the link
+	if slices.Contains(written, title) {
+		title = ""
+	}
+
+	if utf8.RuneCountInString(title) > 100 {
+		title, desc = strings.TrimSuffix(string([]rune(title)[:97]), ".")+"...", strings.TrimSpace(text)
+	}
+
+	item.title, item.desc = title, desc
+
+	return item
+}
+
+// entityText returns the part of the text the entity refers to, the offsets of the entities are in UTF-16 code units.
+func entityText(units []uint16, e tgbotapi.MessageEntity) string {
+	if e.Offset < 0 || e.Length <= 0 || e.Offset+e.Length > len(units) {
+		return ""
+	}
+	return strings.TrimSpace(string(utf16.Decode(units[e.Offset : e.Offset+e.Length])))
+}
+
+// postURL returns the http link written in a post, the links written without a scheme are https links.
+func postURL(link string) string {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return ""
+	}
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !strings.Contains(u.Host, ".") {
+		return ""
+	}
+	return u.String()
+}
+
+// postLink returns the permalink of the post, the posts of private channels are addressed by the channel id without its -100 prefix.
+func postLink(chat *tgbotapi.Chat, messageID int) string {
+	if chat.UserName != "" {
+		return fmt.Sprintf("https://t.me/%s/%d", chat.UserName, messageID)
+	}
+	return fmt.Sprintf("https://t.me/c/%s/%d", strings.TrimPrefix(strconv.FormatInt(chat.ID, 10), "-100"), messageID)
+}
@@ -0,0 +1,124 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/internal/model"
+	"time"
+)
+
+// Deferred reports whether the job is backed off and the current run must be skipped.
+func (r *Runs) Deferred(ctx context.Context, jobID *uuid.UUID) bool {
+	if r == nil || jobID == nil {
+		return false
+	}
+
+	job, err := r.jobRepo.FindByID(ctx, *jobID)
+	if err != nil || job.Health == nil {
+		return false
+	}
+
+	return time.Now().Before(job.Health.NextRunAt)
+}
+
+func (r *Runs) health(ctx context.Context, jobRun entity.JobRun) error {
+	job, err := r.jobRepo.FindByID(ctx, jobRun.JobID)
+	if err != nil {
+		return fmt.Errorf("%s find job %v error: %w", OpServerProcessTask, jobRun.JobID, err)
+	}
+
+	health := entity.JobHealth{Status: entity.JobHealthy}
+	if job.Health != nil && !(job.Health.Status == entity.JobDisabled && job.Active()) {
+		health = *job.Health
+	}
+
+	prev := health.Status
+	update := &entity.Job{ID: job.ID}
+	now := time.Now()
+
+	if jobRun.Failed() {
+		health.Failures++
+		health.NextRunAt = time.Time{}
+
+		switch {
+		case r.cfg.DisableAfter > 0 && health.Failures >= r.cfg.DisableAfter:
+			health.Status = entity.JobDisabled
+			update.SetEnabled(false)
+		case health.Failures >= r.cfg.DegradeAfter:
+			health.Status = entity.JobDegraded
+			if r.cfg.Backoff {
+				health.NextRunAt = r.nextRunAt(job.CronExpr, jobRun.StartedAt, health.Failures)
+			}
+		}
+	} else {
+		if job.Health == nil || (health.Failures == 0 && health.NextRunAt.IsZero()) {
+			return nil
+		}
+
+		health = entity.JobHealth{Status: entity.JobHealthy}
+	}
+
+	if health.Status != prev || health.ChangedAt.IsZero() {
+		health.ChangedAt = now
+	}
+
+	if err = r.jobRepo.Update(ctx, update.SetHealth(health)); err != nil {
+		return fmt.Errorf("%s save job %v health error: %w", OpServerProcessTask, job.ID, err)
+	}
+
+	if health.Status == prev {
+		return nil
+	}
+
+	status := health.Status
+	if status == entity.JobHealthy {
+		if prev != entity.JobDegraded && prev != entity.JobDisabled {
+			return nil
+		}
+		status = entity.JobRecovered
+	}
+
+	r.logger.Warn("job health changed", "job_id", job.ID, "status", status, "failures", health.Failures)
+
+	event := model.JobHealth{
+		JobID:    job.ID.String(),
+		Name:     string(job.Name),
+		Link:     jobRun.Link,
+		Status:   string(status),
+		Failures: health.Failures,
+		Error:    jobRun.Error,
+	}
+	if !health.NextRunAt.IsZero() {
+		event.NextRunAt = &health.NextRunAt
+	}
+
+	r.publisher.Telegram(ctx, model.Message{View: model.ViewJobHealth, Data: event})
+
+	return nil
+}
+
+// nextRunAt stretches the cron interval exponentially with every failure after the job has degraded.
+func (r *Runs) nextRunAt(expr string, startedAt time.Time, failures int) time.Time {
+	interval := time.Minute
+	if schedule, err := cron.ParseStandard(expr); err == nil {
+		next := schedule.Next(startedAt)
+		if d := schedule.Next(next).Sub(next); d > 0 {
+			interval = d
+		}
+	}
+
+	exp := failures - r.cfg.DegradeAfter + 1
+	if exp > 16 {
+		exp = 16
+	}
+
+	d := interval << exp
+	if d <= 0 || d > r.cfg.MaxBackoff {
+		d = r.cfg.MaxBackoff
+	}
+
+	return startedAt.Add(d - interval/2)
+}
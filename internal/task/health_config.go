@@ -0,0 +1,26 @@
+package task
+
+import "time"
+
+type HealthConfig struct {
+	// DegradeAfter consecutive failures the job is reported as degraded and backed off.
+	DegradeAfter int `mapstructure:"degrade_after"`
+	// DisableAfter consecutive failures the job is disabled, a negative value never disables it.
+	DisableAfter int           `mapstructure:"disable_after"`
+	Backoff      bool          `mapstructure:"backoff"`
+	MaxBackoff   time.Duration `mapstructure:"max_backoff"`
+}
+
+func (cfg *HealthConfig) Init() {
+	if cfg.DegradeAfter <= 0 {
+		cfg.DegradeAfter = 3
+	}
+
+	if cfg.DisableAfter == 0 {
+		cfg.DisableAfter = 10
+	}
+
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 24 * time.Hour
+	}
+}
@@ -0,0 +1,183 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const limiterPrefix = "rumors:limiter:"
+
+var (
+	ErrHostBlocked = errors.New("host is temporarily blocked")
+	ErrLimiterWait = errors.New("limiter wait time exceeded")
+)
+
+// reserveScript books the next request slot for a host and returns how long the caller must wait for it.
+// KEYS: next slot, crawl delay, block. ARGV: now, interval, max wait (ms).
+var reserveScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local delay = tonumber(redis.call("GET", KEYS[2]) or "0")
+if delay > interval then
+	interval = delay
+end
+local next = tonumber(redis.call("GET", KEYS[1]) or "0")
+if next < now then
+	next = now
+end
+local blocked = redis.call("PTTL", KEYS[3])
+if blocked > 0 and next < now + blocked then
+	next = now + blocked
+end
+local wait = next - now
+if wait > tonumber(ARGV[3]) then
+	return -wait
+end
+if interval > 0 then
+	redis.call("SET", KEYS[1], next + interval, "PX", wait + interval + 1000)
+end
+return wait
+`)
+
+// acquireScript takes a slot of a per host semaphore, stale slots of crashed workers expire after ttl.
+// KEYS: semaphore. ARGV: now, ttl (ms), max, token.
+var acquireScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local ttl = tonumber(ARGV[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - ttl)
+if redis.call("ZCARD", KEYS[1]) < tonumber(ARGV[3]) then
+	redis.call("ZADD", KEYS[1], now, ARGV[4])
+	redis.call("PEXPIRE", KEYS[1], ttl)
+	return 1
+end
+return 0
+`)
+
+type Limiter struct {
+	rdb redis.UniversalClient
+	cfg *FetcherConfig
+}
+
+func NewLimiter(rdb redis.UniversalClient, cfg *FetcherConfig) *Limiter {
+	return &Limiter{rdb: rdb, cfg: cfg}
+}
+
+func (l *Limiter) Acquire(ctx context.Context, host string) (func(), error) {
+	host = strings.ToLower(host)
+	rps, maxConcurrent := l.cfg.Limits(host)
+	release := func() {}
+
+	ctx, cancel := context.WithTimeout(ctx, l.cfg.Limiter.MaxWait)
+	defer cancel()
+
+	if maxConcurrent > 0 {
+		key := limiterPrefix + "conc:" + host
+		token := uuid.NewString()
+		ttl := l.cfg.RequestTimeout(host) + l.cfg.Limiter.MaxWait
+
+		for {
+			ok, err := acquireScript.Run(ctx, l.rdb, []string{key}, time.Now().UnixMilli(), ttl.Milliseconds(), maxConcurrent, token).Int()
+			if err != nil {
+				return nil, fmt.Errorf("%s %s error: %w", OpLimiterAcquire, host, err)
+			}
+			if ok == 1 {
+				break
+			}
+			if err = sleep(ctx, 200*time.Millisecond); err != nil {
+				return nil, fmt.Errorf("%s %s %w", OpLimiterAcquire, host, ErrLimiterWait)
+			}
+		}
+
+		release = func() {
+			_ = l.rdb.ZRem(context.Background(), key, token).Err()
+		}
+	}
+
+	var interval int64
+	if rps > 0 {
+		interval = int64(float64(time.Second.Milliseconds()) / rps)
+	}
+
+	keys := []string{limiterPrefix + "next:" + host, limiterPrefix + "delay:" + host, limiterPrefix + "block:" + host}
+
+	wait, err := reserveScript.Run(ctx, l.rdb, keys, time.Now().UnixMilli(), interval, l.cfg.Limiter.MaxWait.Milliseconds()).Int64()
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("%s %s error: %w", OpLimiterAcquire, host, err)
+	}
+
+	if wait < 0 {
+		release()
+		return nil, fmt.Errorf("%s %s %w for %s", OpLimiterAcquire, host, ErrHostBlocked, time.Duration(-wait)*time.Millisecond)
+	}
+
+	if err = sleep(ctx, time.Duration(wait)*time.Millisecond); err != nil {
+		release()
+		return nil, fmt.Errorf("%s %s error: %w", OpLimiterAcquire, host, err)
+	}
+
+	return release, nil
+}
+
+func (l *Limiter) Block(ctx context.Context, host string, d time.Duration) error {
+	if d > l.cfg.Limiter.MaxRetryAfter {
+		d = l.cfg.Limiter.MaxRetryAfter
+	}
+	if d <= 0 {
+		return nil
+	}
+	return l.rdb.Set(ctx, limiterPrefix+"block:"+strings.ToLower(host), 1, d).Err()
+}
+
+func (l *Limiter) CrawlDelay(ctx context.Context, host string, d time.Duration) error {
+	return l.rdb.Set(ctx, limiterPrefix+"delay:"+strings.ToLower(host), d.Milliseconds(), l.cfg.Robots.TTL).Err()
+}
+
+func (l *Limiter) Observe(ctx context.Context, host string, res *http.Response) error {
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusServiceUnavailable {
+		return nil
+	}
+
+	d := retryAfter(res.Header.Get("Retry-After"))
+	if d <= 0 {
+		d = l.cfg.Limiter.RetryAfter
+	}
+
+	return l.Block(ctx, host, d)
+}
+
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
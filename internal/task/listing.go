@@ -0,0 +1,451 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rumorsflow/rumors/v2/internal/common"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/internal/model"
+	"github.com/rumorsflow/rumors/v2/pkg/errs"
+	"github.com/rumorsflow/rumors/v2/pkg/repository"
+	"github.com/spf13/cast"
+	"golang.org/x/exp/slog"
+	"io"
+	"time"
+)
+
+// listingItem is an article found on a listing page or in an API response.
+type listingItem struct {
+	key        string
+	link       string
+	title      string
+	desc       string
+	content    string
+	date       *time.Time
+	image      string
+	categories []string
+}
+
+// listing holds what the jobs scraping lists of links share: the dedup of the items by link,
+// the processing of the new ones and the save and publish of the articles. The feed and sitemap jobs
+// share the save of the articles and of the fetch state.
+type listing struct {
+	logger       *slog.Logger
+	publisher    common.Pub
+	fetcher      *Fetcher
+	pool         *Pool
+	siteRepo     repository.ReadRepository[*entity.Site]
+	articleRepo  repository.ReadWriteRepository[*entity.Article]
+	jobRepo      repository.ReadWriteRepository[*entity.Job]
+	runs         *Runs
+	dedup        *Dedup
+	processor    *ArticleProcessor
+	filteredRepo repository.WriteRepository[*entity.FilteredArticle]
+	source       entity.Source
+}
+
+// named returns a copy of the listing for the job logging to the logger and creating articles of the source.
+func (l listing) named(logger *slog.Logger, source entity.Source) listing {
+	l.logger = logger
+	l.source = source
+	return l
+}
+
+// listingJob is what the payloads of the listing jobs share.
+type listingJob struct {
+	name    entity.JobName
+	jobID   *uuid.UUID
+	siteID  uuid.UUID
+	link    string
+	lang    *string
+	extract *bool
+	rules   *entity.ContentRules
+	force   bool
+	workers int
+	pages   int
+	headers map[string]string
+}
+
+// listingRun is a started run of a listing job, partial reports a next page failed to be fetched.
+type listingRun struct {
+	listingJob
+	run     *Run
+	site    *entity.Site
+	lang    string
+	scope   string
+	partial bool
+}
+
+// listingPage is a parsed page of a listing, next is the link of the following page, stop ends the paging at the page.
+type listingPage struct {
+	items []listingItem
+	next  string
+	stop  bool
+}
+
+// begin starts the run of the job unless the job is backed off, the settings of the site and of the job are put
+// in the context. A nil run is returned when the run is skipped or failed, the run is finished then.
+func (l *listing) begin(ctx context.Context, job listingJob) (context.Context, *listingRun, error) {
+	if !job.force && l.runs.Deferred(ctx, job.jobID) {
+		l.logger.Debug("job is backed off, run skipped", "job_id", job.jobID)
+		return ctx, nil, nil
+	}
+
+	r := &listingRun{
+		listingJob: job,
+		run:        l.runs.Start(ctx, job.name, job.jobID, job.siteID, job.link, ""),
+		scope:      dedupScope(job.jobID, job.siteID),
+	}
+
+	site, err := l.siteRepo.FindByID(ctx, job.siteID)
+	if err != nil {
+		r.run.Fail(err)
+		l.runs.Finish(r.run)
+
+		if errors.Is(err, repository.ErrEntityNotFound) {
+			l.logger.Error("error due to find site", "err", err, "id", job.siteID)
+			return ctx, nil, nil
+		}
+		return ctx, nil, fmt.Errorf("%s find site %v error: %w", OpServerProcessTask, job.siteID, err)
+	}
+
+	if r.lang, err = fallbackLang(job.lang, site); err != nil {
+		l.logger.Warn("fallback language not found", "job_id", job.jobID, "site_id", job.siteID)
+		r.run.Fail(err)
+		l.runs.Finish(r.run)
+		return ctx, nil, nil
+	}
+
+	if site.RespectsRobots() {
+		ctx = WithRobots(ctx)
+	}
+
+	if site.Extracts(job.extract) {
+		ctx = WithExtract(ctx)
+	}
+
+	r.site = site
+
+	return WithRules(ctx, job.rules), r, nil
+}
+
+// fetch requests the first page of the listing, it reports whether the page changed since the last run.
+func (l *listing) fetch(ctx context.Context, r *listingRun, link string) (*fetched, bool) {
+	res, err := l.fetcher.conditional(ctx, link, fetchState(ctx, l.jobRepo, r.jobID), r.headers)
+	r.run.Fetched(res)
+
+	if err != nil {
+		r.run.Fail(err)
+
+		if !errs.IsCanceledOrDeadline(err) {
+			l.logger.Error("error due to fetch listing", "err", err, "site_id", r.siteID, "link", link)
+		}
+		return res, false
+	}
+
+	if res.unchanged {
+		l.logger.Debug("listing not modified", "site_id", r.siteID, "link", link)
+		l.saveFetchState(ctx, r.jobID, res.state)
+		return res, false
+	}
+
+	return res, true
+}
+
+// pages collects the unseen items of the pages of the listing, the first one is fetched already. The next pages
+// are requested while they have unseen items. The items are returned oldest first, the way listings are ordered
+// the other way around.
+func (l *listing) pages(ctx context.Context, r *listingRun, link string, res *fetched, parse func(ctx context.Context, link string, res *fetched) (*listingPage, error)) ([]listingItem, error) {
+	var items []listingItem
+
+	visited := map[string]struct{}{link: {}}
+
+	for i := 0; i < r.pages; i++ {
+		if i > 0 {
+			var err error
+			if res, err = l.fetcher.conditional(ctx, link, nil, r.headers); err != nil {
+				if errs.IsCanceledOrDeadline(err) {
+					return nil, err
+				}
+				l.logger.Warn("error due to fetch next listing page", "err", err, "page_link", link)
+				r.partial = true
+				break
+			}
+		}
+
+		page, err := parse(ctx, link, res)
+		if err != nil {
+			return nil, err
+		}
+
+		r.run.Seen(len(page.items))
+
+		l.keys(page.items)
+
+		unseen, err := l.unseen(ctx, r.run, r.scope, page.items)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, unseen...)
+
+		// a page of nothing but left out entries, like the replies of an outbox, is no reason to stop
+		if page.stop || (len(page.items) > 0 && len(unseen) == 0) || page.next == "" {
+			break
+		}
+		if _, ok := visited[page.next]; ok {
+			break
+		}
+
+		visited[page.next] = struct{}{}
+		link = page.next
+	}
+
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+
+	return items, nil
+}
+
+// end processes the items collected by the run, the state of the fetch is saved unless items failed.
+func (l *listing) end(ctx context.Context, r *listingRun, items []listingItem, state entity.FetchState, err error) error {
+	if err != nil {
+		r.run.Fail(err)
+
+		if errs.IsCanceledOrDeadline(err) {
+			return nil
+		}
+		return fmt.Errorf("%s %w", OpServerProcessTask, err)
+	}
+
+	failed := l.process(ctx, r.run, r.scope, r.workers, r.site, items, r.lang)
+
+	l.commitFetchState(ctx, r.jobID, state, failed)
+
+	r.run.Fail(ctx.Err())
+
+	return nil
+}
+
+// keys sets the identities of the items, the normalized links.
+func (l *listing) keys(items []listingItem) {
+	for i := range items {
+		items[i].key = "link:" + l.fetcher.Canonical(items[i].link, nil)
+	}
+}
+
+// unseen drops the items already processed, the seen-set is checked first and the rest is looked up by link.
+func (l *listing) unseen(ctx context.Context, run *Run, scope string, items []listingItem) ([]listingItem, error) {
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = item.key
+	}
+
+	seen, err := l.dedup.Has(ctx, scope, keys)
+	if err != nil {
+		l.logger.Warn("error due to check seen items", "err", err, "scope", scope)
+	}
+
+	var keysKnown []string
+	candidates := make([]listingItem, 0, len(items))
+	links := make([]string, 0, len(items))
+
+	for i, item := range items {
+		if seen[i] {
+			keysKnown = append(keysKnown, item.key)
+		} else {
+			candidates = append(candidates, item)
+			links = append(links, item.link)
+		}
+	}
+
+	unseen := make([]listingItem, 0, len(candidates))
+
+	if len(candidates) > 0 {
+		existing, err := findExisting(ctx, l.articleRepo, l.fetcher, links)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range candidates {
+			if _, ok := existing[item.link]; ok {
+				keysKnown = append(keysKnown, item.key)
+			} else if _, ok = existing[l.fetcher.Canonical(item.link, nil)]; ok {
+				keysKnown = append(keysKnown, item.key)
+			} else {
+				unseen = append(unseen, item)
+			}
+		}
+	}
+
+	run.Duplicates(len(keysKnown))
+
+	// known items are added again to keep them in the current generation of the seen-set
+	l.markSeen(ctx, scope, keysKnown...)
+
+	return unseen, nil
+}
+
+// process builds the articles of the items in their order, then saves and publishes them.
+// It returns the number of the items failed for a transient reason, the next run retries them.
+func (l *listing) process(ctx context.Context, run *Run, scope string, workers int, site *entity.Site, items []listingItem, fallbackLang string) (failed int) {
+	Ordered(ctx, l.pool, workers, items, func(ctx context.Context, item listingItem) itemResult {
+		article, failed := l.processItem(ctx, run, scope, site, item, fallbackLang)
+		return itemResult{key: item.key, article: article, failed: failed}
+	}, func(r itemResult) bool {
+		switch {
+		case r.article != nil && l.saveArticle(ctx, run, r.article):
+			l.markSeen(ctx, scope, r.key)
+		case r.article != nil || r.failed:
+			failed++
+		}
+		return true
+	})
+	return failed
+}
+
+// processItem returns the article of the item, it reports whether the item failed for a transient reason.
+func (l *listing) processItem(ctx context.Context, run *Run, scope string, site *entity.Site, item listingItem, fallbackLang string) (*entity.Article, bool) {
+	article, err := l.article(ctx, site, item, fallbackLang)
+	if err != nil {
+		var filtered *FilteredError
+
+		switch {
+		case errs.IsCanceledOrDeadline(err):
+			return nil, true
+		case errors.Is(err, ErrItemSkipped):
+			l.markSeen(ctx, scope, item.key)
+			l.logger.Warn("item skipped", "err", err, "link", item.link)
+		case errors.As(err, &filtered):
+			run.Filtered()
+			l.markSeen(ctx, scope, item.key)
+			l.logger.Debug("item filtered", "reason", filtered.Reason, "link", item.link)
+			if err = saveFiltered(ctx, l.filteredRepo, run.JobID(), filtered); err != nil {
+				l.logger.Error("error due to save filtered item", "err", err)
+			}
+		default:
+			run.OGFailure()
+			l.logger.Error("error due to parse item's link", "err", fmt.Errorf("%s error: %w", OpServerProcessTask, err), "link", item.link)
+			return nil, true
+		}
+		return nil, false
+	}
+
+	return article, false
+}
+
+func (l *listing) article(ctx context.Context, site *entity.Site, item listingItem, fallbackLang string) (*entity.Article, error) {
+	draft := &ArticleDraft{
+		Site:         site,
+		Source:       l.source,
+		Link:         item.link,
+		Title:        item.title,
+		Desc:         item.desc,
+		Content:      item.content,
+		FallbackLang: fallbackLang,
+		PubDate:      item.date,
+		Categories:   item.categories,
+	}
+
+	if item.image != "" {
+		draft.Media = []entity.Media{{URL: item.image, Type: entity.ImageType}}
+	}
+
+	return l.processor.Process(ctx, draft)
+}
+
+// saveArticle reports whether the article is stored, either by this call or before it.
+func (l *listing) saveArticle(ctx context.Context, run *Run, article *entity.Article) bool {
+	err := l.save(ctx, run, article)
+	return err == nil || errors.Is(err, io.EOF)
+}
+
+// save stores and publishes the article, io.EOF is returned for a duplicate article, any other error means
+// the article is not stored.
+func (l *listing) save(ctx context.Context, run *Run, article *entity.Article) error {
+	if err := l.articleRepo.Save(ctx, article); err != nil {
+		if errs.IsCanceledOrDeadline(err) {
+			return err
+		}
+
+		if errors.Is(err, repository.ErrDuplicateKey) {
+			run.Duplicates(1)
+			l.logger.Debug("error due to save article, duplicate key", "article", article)
+			return io.EOF
+		}
+
+		l.logger.Error("error due to save article", "err", err, "article", article)
+		return err
+	}
+
+	l.logger.Debug("article saved", "article", article)
+
+	run.Saved()
+
+	l.publisher.Articles(ctx, []model.Article{model.ArticleFromEntity(article)})
+
+	return nil
+}
+
+func (l *listing) saveFetchState(ctx context.Context, jobID *uuid.UUID, state entity.FetchState) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err := saveFetchState(ctx, l.jobRepo, jobID, state); err != nil {
+		l.logger.Error("error due to save fetch state", "err", err, "job_id", jobID)
+	}
+}
+
+// commitFetchState saves the state of the fetch unless items failed, the previous state is kept then,
+// otherwise the next run would find the source unchanged and never retry them.
+func (l *listing) commitFetchState(ctx context.Context, jobID *uuid.UUID, state entity.FetchState, failed int) {
+	if failed > 0 {
+		l.logger.Debug("fetch state kept, items failed", "failed", failed, "job_id", jobID)
+		return
+	}
+	l.saveFetchState(ctx, jobID, state)
+}
+
+func (l *listing) markSeen(ctx context.Context, scope string, keys ...string) {
+	if err := l.dedup.Add(ctx, scope, keys...); err != nil {
+		l.logger.Warn("error due to mark items as seen", "err", err, "scope", scope)
+	}
+}
+
+// fallbackLang returns the language of the job, the first language of the site otherwise.
+func fallbackLang(lang *string, site *entity.Site) (string, error) {
+	if lang != nil && *lang != "" {
+		return *lang, nil
+	}
+	if len(site.Languages) == 0 {
+		return "", errors.New("fallback language not found")
+	}
+	return site.Languages[0], nil
+}
+
+// parseDate parses the value by the layout when one is given, common formats are tried otherwise.
+func parseDate(value string, layout *string) *time.Time {
+	if value == "" {
+		return nil
+	}
+
+	var (
+		date time.Time
+		err  error
+	)
+
+	if layout != nil && *layout != "" {
+		date, err = time.Parse(*layout, value)
+	} else {
+		date, err = cast.ToTimeE(value)
+	}
+
+	if err != nil || date.IsZero() {
+		return nil
+	}
+	return &date
+}
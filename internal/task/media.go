@@ -0,0 +1,171 @@
+package task
+
+import (
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"strconv"
+	"strings"
+)
+
+// feedMedia maps Media RSS, enclosures, the item image and iTunes artwork into media.
+func feedMedia(item *gofeed.Item) []entity.Media {
+	var media []entity.Media
+	seen := make(map[string]struct{})
+
+	add := func(m entity.Media) {
+		if m.URL == "" || m.Type == "" {
+			return
+		}
+		if _, ok := seen[m.URL]; ok {
+			return
+		}
+		seen[m.URL] = struct{}{}
+		media = append(media, m)
+	}
+
+	if mrss, ok := item.Extensions["media"]; ok {
+		for _, m := range mediaRSS(mrss) {
+			add(m)
+		}
+		for _, group := range mrss["group"] {
+			for _, m := range mediaRSS(group.Children) {
+				add(m)
+			}
+		}
+	}
+
+	var duration int
+	if item.ITunesExt != nil {
+		duration = seconds(item.ITunesExt.Duration)
+	}
+
+	for _, e := range item.Enclosures {
+		if e == nil {
+			continue
+		}
+		m := entity.Media{URL: strings.TrimSpace(e.URL), Type: mediaType("", e.Type), Meta: map[string]any{}}
+		setMeta(m.Meta, "mime", e.Type)
+		setMeta(m.Meta, "size", number(e.Length))
+		if m.Type != entity.ImageType {
+			setMeta(m.Meta, "duration", duration)
+		}
+		add(m)
+	}
+
+	if item.Image != nil {
+		m := entity.Media{URL: strings.TrimSpace(item.Image.URL), Type: entity.ImageType, Meta: map[string]any{}}
+		setMeta(m.Meta, "alt", item.Image.Title)
+		add(m)
+	}
+
+	if item.ITunesExt != nil {
+		add(entity.Media{URL: strings.TrimSpace(item.ITunesExt.Image), Type: entity.ImageType})
+	}
+
+	return media
+}
+
+// mediaRSS maps media:content and media:thumbnail elements, a thumbnail nested in content is mapped as well.
+func mediaRSS(elements map[string][]ext.Extension) []entity.Media {
+	var media []entity.Media
+
+	for _, c := range elements["content"] {
+		mime := c.Attrs["type"]
+		m := entity.Media{URL: strings.TrimSpace(c.Attrs["url"]), Type: mediaType(c.Attrs["medium"], mime), Meta: map[string]any{}}
+		setMeta(m.Meta, "mime", mime)
+		setMeta(m.Meta, "size", number(c.Attrs["fileSize"]))
+		setMeta(m.Meta, "duration", seconds(c.Attrs["duration"]))
+		setMeta(m.Meta, "width", int(number(c.Attrs["width"])))
+		setMeta(m.Meta, "height", int(number(c.Attrs["height"])))
+		if title := c.Children["title"]; len(title) > 0 {
+			setMeta(m.Meta, "alt", strings.TrimSpace(title[0].Value))
+		}
+		media = append(media, m)
+
+		media = append(media, mediaThumbnails(c.Children["thumbnail"])...)
+	}
+
+	return append(media, mediaThumbnails(elements["thumbnail"])...)
+}
+
+func mediaThumbnails(elements []ext.Extension) []entity.Media {
+	media := make([]entity.Media, 0, len(elements))
+	for _, t := range elements {
+		m := entity.Media{URL: strings.TrimSpace(t.Attrs["url"]), Type: entity.ImageType, Meta: map[string]any{}}
+		setMeta(m.Meta, "width", int(number(t.Attrs["width"])))
+		setMeta(m.Meta, "height", int(number(t.Attrs["height"])))
+		media = append(media, m)
+	}
+	return media
+}
+
+// mergeMedia appends the feed media of every type the page metadata has none of.
+func mergeMedia(media, fallback []entity.Media) []entity.Media {
+	types := make(map[entity.MediaType]struct{}, 3)
+	for _, m := range media {
+		types[m.Type] = struct{}{}
+	}
+
+	for _, m := range fallback {
+		if _, ok := types[m.Type]; !ok {
+			media = append(media, m)
+		}
+	}
+	return media
+}
+
+func mediaType(medium, mime string) entity.MediaType {
+	switch medium = strings.ToLower(strings.TrimSpace(medium)); medium {
+	case "image", "video", "audio":
+		return entity.MediaType(medium)
+	}
+
+	mime = strings.ToLower(strings.TrimSpace(mime))
+	switch {
+	case strings.HasPrefix(mime, "image/"):
+		return entity.ImageType
+	case strings.HasPrefix(mime, "video/"), mime == "application/x-shockwave-flash", mime == "application/x-mpegurl":
+		return entity.VideoType
+	case strings.HasPrefix(mime, "audio/"):
+		return entity.AudioType
+	}
+	return ""
+}
+
+// seconds parses durations given as seconds or as [hh:]mm:ss.
+func seconds(value string) int {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	var total int
+	for _, part := range strings.Split(value, ":") {
+		total = total*60 + int(number(strings.Split(part, ".")[0]))
+	}
+	return total
+}
+
+func number(value string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	return n
+}
+
+func setMeta(meta map[string]any, key string, value any) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return
+		}
+	case int:
+		if v <= 0 {
+			return
+		}
+	case int64:
+		if v <= 0 {
+			return
+		}
+	}
+	meta[key] = value
+}
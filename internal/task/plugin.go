@@ -3,6 +3,7 @@ package task
 import (
 	"context"
 	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
 	"github.com/roadrunner-server/endure/v2/dep"
 	"github.com/roadrunner-server/errors"
 	"github.com/rumorsflow/rumors/v2/internal/common"
@@ -18,6 +19,10 @@ const (
 
 	sectionScheduler = "task.scheduler"
 	sectionServer    = "task.server"
+	sectionFetcher   = "task.fetcher"
+	sectionHealth    = "task.health"
+	sectionDedup     = "task.dedup"
+	sectionWebSub    = "task.websub"
 )
 
 type Plugin struct {
@@ -26,12 +31,21 @@ type Plugin struct {
 	scheduler *Scheduler
 	metrics   *Metrics
 	handler   asynq.Handler
+	rdb       redis.UniversalClient
+	fetcher   *Fetcher
+}
+
+// FetcherProvider shares the fetcher of the task plugin with the plugins requesting the sources of the jobs,
+// the requests are limited, guarded and cached the same way.
+type FetcherProvider interface {
+	Fetcher() *Fetcher
 }
 
 func (p *Plugin) Init(
 	cfg config.Configurer,
 	uow common.UnitOfWork,
 	redisConnOpt asynq.RedisConnOpt,
+	rdbMaker common.RedisMaker,
 	pub common.Pub,
 	log logger.Logger,
 ) error {
@@ -45,6 +59,19 @@ func (p *Plugin) Init(
 
 	p.client = NewClient(redisConnOpt, l.WithGroup("client"))
 
+	fc, err := LoadFetcherConfig(cfg)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	if p.rdb, err = rdbMaker.Make(); err != nil {
+		return errors.E(op, err)
+	}
+
+	if p.fetcher, err = NewFetcher(fc, p.rdb); err != nil {
+		return errors.E(op, err)
+	}
+
 	if cfg.Has(sectionServer) {
 		var c ServerConfig
 		if err := cfg.UnmarshalKey(sectionServer, &c); err != nil {
@@ -55,6 +82,27 @@ func (p *Plugin) Init(
 			c.GracefulTimeout = cfg.GracefulTimeout()
 		}
 
+		var hc HealthConfig
+		if cfg.Has(sectionHealth) {
+			if err := cfg.UnmarshalKey(sectionHealth, &hc); err != nil {
+				return errors.E(op, err)
+			}
+		}
+		hc.Init()
+
+		var dc DedupConfig
+		if cfg.Has(sectionDedup) {
+			if err := cfg.UnmarshalKey(sectionDedup, &dc); err != nil {
+				return errors.E(op, err)
+			}
+		}
+		dc.Init()
+
+		wc, err := LoadWebSubConfig(cfg)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
 		siteAny, err := uow.Repository((*entity.Site)(nil))
 		if err != nil {
 			return errors.E(op, err)
@@ -70,9 +118,27 @@ func (p *Plugin) Init(
 			return errors.E(op, err)
 		}
 
+		jobAny, err := uow.Repository((*entity.Job)(nil))
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		jobRunAny, err := uow.Repository((*entity.JobRun)(nil))
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		filteredAny, err := uow.Repository((*entity.FilteredArticle)(nil))
+		if err != nil {
+			return errors.E(op, err)
+		}
+
 		siteRepo := siteAny.(repository.ReadWriteRepository[*entity.Site])
 		chatRepo := chatAny.(repository.ReadWriteRepository[*entity.Chat])
 		articleRepo := articleAny.(repository.ReadWriteRepository[*entity.Article])
+		jobRepo := jobAny.(repository.ReadWriteRepository[*entity.Job])
+		jobRunRepo := jobRunAny.(repository.ReadWriteRepository[*entity.JobRun])
+		filteredRepo := filteredAny.(repository.ReadWriteRepository[*entity.FilteredArticle])
 
 		ls := l.WithGroup("server")
 		muxLog := ls.WithGroup("mux")
@@ -81,22 +147,56 @@ func (p *Plugin) Init(
 		cmdLog := tgLog.WithGroup("cmd")
 
 		p.server = NewServer(&c, redisConnOpt, ls)
+		pool := NewPool(c.ItemWorkers)
+		runs := NewRuns(&hc, jobRunRepo, jobRepo, pub, hLog.WithGroup("job").WithGroup("runs"))
+		processor := NewArticleProcessor(p.fetcher, hLog.WithGroup("job").WithGroup("processor"))
+		dedup := NewDedup(&dc, p.rdb)
+		lists := listing{
+			publisher:    pub,
+			fetcher:      p.fetcher,
+			pool:         pool,
+			siteRepo:     siteRepo,
+			articleRepo:  articleRepo,
+			jobRepo:      jobRepo,
+			runs:         runs,
+			dedup:        dedup,
+			processor:    processor,
+			filteredRepo: filteredRepo,
+		}
 
 		mux := asynq.NewServeMux()
 		mux.Use(LoggingMiddleware(muxLog))
 
 		mux.Handle(string(entity.JobFeed), &HandlerJobFeed{
-			logger:      hLog.WithGroup("job").WithGroup("feed"),
-			publisher:   pub,
-			siteRepo:    siteRepo,
-			articleRepo: articleRepo,
+			listing: lists.named(hLog.WithGroup("job").WithGroup("feed"), entity.FeedSource),
+			websub:  NewWebSub(wc, p.fetcher, jobRepo, p.client, hLog.WithGroup("job").WithGroup("websub")),
 		})
 
 		mux.Handle(string(entity.JobSitemap), &HandlerJobSitemap{
-			logger:      hLog.WithGroup("job").WithGroup("sitemap"),
-			publisher:   pub,
-			siteRepo:    siteRepo,
-			articleRepo: articleRepo,
+			listing: lists.named(hLog.WithGroup("job").WithGroup("sitemap"), entity.SitemapSource),
+			client:  p.client,
+			groups:  NewGroups(p.rdb),
+		})
+
+		mux.Handle(string(entity.JobHTML), &HandlerJobHTML{
+			listing: lists.named(hLog.WithGroup("job").WithGroup("html"), entity.HTMLSource),
+		})
+
+		mux.Handle(string(entity.JobJSON), &HandlerJobJSON{
+			listing: lists.named(hLog.WithGroup("job").WithGroup("json"), entity.JSONSource),
+		})
+
+		mux.Handle(TelegramPost, &HandlerTgPost{
+			listing:  lists.named(tgLog.WithGroup("post"), entity.TelegramSource),
+			chatRepo: chatRepo,
+		})
+
+		mux.Handle(string(entity.JobActivityPub), &HandlerJobActivityPub{
+			listing: lists.named(hLog.WithGroup("job").WithGroup("activitypub"), entity.ActivityPubSource),
+		})
+
+		mux.Handle(MailNewsletter, &HandlerMailNewsletter{
+			listing: lists.named(hLog.WithGroup("mail").WithGroup("newsletter"), entity.MailSource),
 		})
 
 		mux.Handle(TelegramChat, &HandlerTgChat{
@@ -186,6 +286,10 @@ func (p *Plugin) Stop(ctx context.Context) (err error) {
 
 	g.Go(p.client.Close)
 
+	if p.rdb != nil {
+		g.Go(p.rdb.Close)
+	}
+
 	if p.metrics != nil {
 		g.Go(func() error {
 			p.metrics.Unregister()
@@ -218,8 +322,17 @@ func (p *Plugin) Client() common.Client {
 	return p.client
 }
 
+func (p *Plugin) Fetcher() *Fetcher {
+	return p.fetcher
+}
+
+func (p *Plugin) FetcherProvider() FetcherProvider {
+	return p
+}
+
 func (p *Plugin) Provides() []*dep.Out {
 	return []*dep.Out{
 		dep.Bind((*common.Client)(nil), p.Client),
+		dep.Bind((*FetcherProvider)(nil), p.FetcherProvider),
 	}
 }
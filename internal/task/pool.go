@@ -0,0 +1,92 @@
+package task
+
+import (
+	"context"
+	"sync"
+)
+
+const DefaultItemWorkers = 4
+
+type Pool struct {
+	sem chan struct{}
+}
+
+func NewPool(size int) *Pool {
+	if size <= 0 {
+		size = DefaultItemWorkers
+	}
+	return &Pool{sem: make(chan struct{}, size)}
+}
+
+func (p *Pool) Size() int {
+	return cap(p.sem)
+}
+
+// Ordered processes items with at most workers goroutines, all of them share the pool bounds,
+// and hands results to done in the items order. It stops as soon as done returns false.
+func Ordered[T, R any](ctx context.Context, p *Pool, workers int, items []T, process func(context.Context, T) R, done func(R) bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if workers <= 0 || workers > p.Size() {
+		workers = p.Size()
+	}
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	results := make([]chan R, len(items))
+	for i := range results {
+		results[i] = make(chan R, 1)
+	}
+
+	queue := make(chan int)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+
+			for i := range queue {
+				select {
+				case p.sem <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+
+				results[i] <- process(ctx, items[i])
+				<-p.sem
+			}
+		}()
+	}
+
+	go func() {
+		defer close(queue)
+
+		for i := range items {
+			select {
+			case queue <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	for i := range results {
+		select {
+		case r := <-results[i]:
+			if !done(r) {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
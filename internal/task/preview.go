@@ -0,0 +1,388 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mmcdole/gofeed"
+	"github.com/oxffaa/gopher-parse-sitemap"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/internal/model"
+	"github.com/rumorsflow/rumors/v2/pkg/repository"
+	"golang.org/x/exp/slog"
+	"time"
+)
+
+const DefaultPreviewLimit = 10
+
+var ErrPreviewNotSupported = errors.New("job preview is not supported")
+
+// Previewer runs the parse and open graph steps of a job without saving or publishing anything.
+type Previewer struct {
+	fetcher  *Fetcher
+	pool     *Pool
+	siteRepo repository.ReadRepository[*entity.Site]
+	feed     *HandlerJobFeed
+	sitemap  *HandlerJobSitemap
+	html     *HandlerJobHTML
+	json     *HandlerJobJSON
+	activity *HandlerJobActivityPub
+}
+
+func NewPreviewer(fetcher *Fetcher, siteRepo repository.ReadRepository[*entity.Site], logger *slog.Logger) *Previewer {
+	pool := NewPool(DefaultItemWorkers)
+	processor := NewArticleProcessor(fetcher, logger.WithGroup("processor"))
+
+	return &Previewer{
+		fetcher:  fetcher,
+		pool:     pool,
+		siteRepo: siteRepo,
+		feed: &HandlerJobFeed{
+			listing: listing{logger: logger.WithGroup("feed"), fetcher: fetcher, pool: pool, siteRepo: siteRepo, processor: processor, source: entity.FeedSource},
+		},
+		sitemap: &HandlerJobSitemap{
+			listing: listing{logger: logger.WithGroup("sitemap"), fetcher: fetcher, pool: pool, siteRepo: siteRepo, processor: processor, source: entity.SitemapSource},
+		},
+		html: &HandlerJobHTML{
+			listing: listing{logger: logger.WithGroup("html"), fetcher: fetcher, processor: processor, source: entity.HTMLSource},
+		},
+		json: &HandlerJobJSON{
+			listing: listing{logger: logger.WithGroup("json"), fetcher: fetcher, processor: processor, source: entity.JSONSource},
+		},
+		activity: &HandlerJobActivityPub{
+			listing: listing{logger: logger.WithGroup("activitypub"), fetcher: fetcher, processor: processor, source: entity.ActivityPubSource},
+		},
+	}
+}
+
+func (p *Previewer) Preview(ctx context.Context, job *entity.Job, limit int) (*model.JobPreview, error) {
+	if limit <= 0 {
+		limit = DefaultPreviewLimit
+	}
+
+	switch payload := job.Payload.(type) {
+	case *entity.FeedPayload:
+		return p.previewFeed(ctx, *payload, limit)
+	case *entity.SitemapPayload:
+		return p.previewSitemap(ctx, *payload, limit)
+	case *entity.HTMLPayload:
+		return p.previewHTML(ctx, *payload, limit)
+	case *entity.JSONPayload:
+		return p.previewJSON(ctx, *payload, limit)
+	case *entity.ActivityPubPayload:
+		return p.previewActivityPub(ctx, *payload, limit)
+	}
+
+	return nil, fmt.Errorf("%s %s %w", OpPreview, job.Name, ErrPreviewNotSupported)
+}
+
+func (p *Previewer) previewFeed(ctx context.Context, payload entity.FeedPayload, limit int) (*model.JobPreview, error) {
+	ctx, site, _, err := p.begin(ctx, listingJob{siteID: payload.SiteID, extract: payload.Extract, rules: payload.Rules}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &model.JobPreview{Link: payload.Link}
+
+	body, err := p.fetch(ctx, preview, payload.Link)
+	if err != nil {
+		return preview, nil
+	}
+
+	parsed, err := p.feed.parseFeed(body)
+	if err != nil {
+		preview.Error = err.Error()
+		return preview, nil
+	}
+
+	items := parsed.Items
+	preview.Total = len(items)
+	if len(items) > limit {
+		items = items[len(items)-limit:]
+	}
+
+	collect(ctx, p.pool, preview, items, func(item *gofeed.Item) string {
+		return item.Link
+	}, func(ctx context.Context, item *gofeed.Item) (*entity.Article, error) {
+		return p.feed.article(ctx, site, item)
+	})
+
+	return preview, nil
+}
+
+func (p *Previewer) previewSitemap(ctx context.Context, payload entity.SitemapPayload, limit int) (*model.JobPreview, error) {
+	ctx, site, lang, err := p.begin(ctx, listingJob{siteID: payload.SiteID, lang: payload.Lang, extract: payload.Extract, rules: payload.Rules}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, expr := range []*string{payload.MatchLoc, payload.SearchLoc} {
+		if expr != nil && *expr != "" {
+			if err = addRegex(expr); err != nil {
+				return nil, fmt.Errorf("%s compile payload regex error: %w", OpPreview, err)
+			}
+		}
+	}
+
+	preview := &model.JobPreview{Link: payload.Link}
+
+	body, err := p.fetch(ctx, preview, payload.Link)
+	if err != nil {
+		return preview, nil
+	}
+
+	if payload.IsIndex() {
+		// the index itself has no articles, the most recently modified sitemap is previewed instead
+		var (
+			link    string
+			lastMod time.Time
+		)
+
+		if err = sitemap.ParseIndex(ctx, bytes.NewReader(body), func(e sitemap.IndexEntry) error {
+			if date := e.GetLastModified(); link == "" || (date != nil && date.After(lastMod)) {
+				link = e.GetLocation()
+				if date != nil {
+					lastMod = *date
+				}
+			}
+			return nil
+		}); err != nil {
+			preview.Error = err.Error()
+			return preview, nil
+		}
+
+		if link == "" {
+			return preview, nil
+		}
+
+		preview.Link = link
+		if body, err = p.fetch(ctx, preview, link); err != nil {
+			return preview, nil
+		}
+	}
+
+	var entries []sitemap.Entry
+
+	if err = sitemap.Parse(ctx, bytes.NewReader(body), func(e sitemap.Entry) error {
+		if matchByLoc(payload.MatchLoc, e.GetLocation()) {
+			entries = append(entries, e)
+		}
+		return nil
+	}); err != nil {
+		preview.Error = err.Error()
+		return preview, nil
+	}
+
+	preview.Total = len(entries)
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	collect(ctx, p.pool, preview, entries, func(e sitemap.Entry) string {
+		return e.GetLocation()
+	}, func(ctx context.Context, e sitemap.Entry) (*entity.Article, error) {
+		return p.sitemap.article(ctx, e, site, lang)
+	})
+
+	return preview, nil
+}
+
+func (p *Previewer) previewHTML(ctx context.Context, payload entity.HTMLPayload, limit int) (*model.JobPreview, error) {
+	if err := CheckSelectors(&payload); err != nil {
+		return nil, fmt.Errorf("%s %w", OpPreview, err)
+	}
+
+	ctx, site, lang, err := p.begin(ctx, listingJob{siteID: payload.SiteID, lang: payload.Lang, extract: payload.Extract, rules: payload.Rules}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &model.JobPreview{Link: payload.Link}
+
+	res, err := p.fetcher.Conditional(ctx, payload.Link, nil)
+	if res != nil {
+		preview.Status = res.state.Status
+	}
+	if err != nil {
+		preview.Error = err.Error()
+		return preview, nil
+	}
+
+	// only the first page is previewed, it shows whether the selectors match
+	page, err := parseListing(res.body, res.contentType, payload.Link, payload)
+	if err != nil {
+		preview.Error = err.Error()
+		return preview, nil
+	}
+
+	items := page.items
+	preview.Total = len(items)
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	collect(ctx, p.pool, preview, items, func(item listingItem) string {
+		return item.link
+	}, func(ctx context.Context, item listingItem) (*entity.Article, error) {
+		return p.html.article(ctx, site, item, lang)
+	})
+
+	return preview, nil
+}
+
+func (p *Previewer) previewJSON(ctx context.Context, payload entity.JSONPayload, limit int) (*model.JobPreview, error) {
+	ctx, site, lang, err := p.begin(ctx, listingJob{siteID: payload.SiteID, lang: payload.Lang, extract: payload.Extract, rules: payload.Rules}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &model.JobPreview{Link: payload.Link}
+
+	res, err := p.fetcher.conditional(ctx, payload.Link, nil, jsonHeaders(payload.Headers))
+	if res != nil {
+		preview.Status = res.state.Status
+	}
+	if err != nil {
+		preview.Error = err.Error()
+		return preview, nil
+	}
+
+	// only the first page is previewed, it shows whether the mapping matches
+	page, err := parseJSONPage(res.body, payload.Link, payload)
+	if err != nil {
+		preview.Error = err.Error()
+		return preview, nil
+	}
+
+	items := page.items
+	preview.Total = len(items)
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	collect(ctx, p.pool, preview, items, func(item listingItem) string {
+		return item.link
+	}, func(ctx context.Context, item listingItem) (*entity.Article, error) {
+		return p.json.article(ctx, site, item, lang)
+	})
+
+	return preview, nil
+}
+
+func (p *Previewer) previewActivityPub(ctx context.Context, payload entity.ActivityPubPayload, limit int) (*model.JobPreview, error) {
+	ctx, site, lang, err := p.begin(ctx, listingJob{siteID: payload.SiteID, lang: payload.Lang, extract: payload.Extract, rules: payload.Rules}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &model.JobPreview{Link: payload.Actor}
+
+	outbox, err := p.activity.outbox(ctx, payload.Actor)
+	if err != nil {
+		preview.Error = err.Error()
+		return preview, nil
+	}
+	preview.Link = outbox
+
+	res, err := p.fetcher.conditional(ctx, outbox, nil, activityHeaders())
+	if res != nil {
+		preview.Status = res.state.Status
+	}
+	if err != nil {
+		preview.Error = err.Error()
+		return preview, nil
+	}
+
+	// only the first page is previewed, it shows whether the actor posts what the site publishes
+	activities, _, err := p.activity.page(ctx, outbox, res.body)
+	if err != nil {
+		preview.Error = err.Error()
+		return preview, nil
+	}
+
+	items, _ := p.activity.items(ctx, activities, "")
+	preview.Total = len(items)
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	collect(ctx, p.pool, preview, items, func(item listingItem) string {
+		return item.link
+	}, func(ctx context.Context, item listingItem) (*entity.Article, error) {
+		return p.activity.article(ctx, site, item, lang)
+	})
+
+	return preview, nil
+}
+
+// begin finds the site of the job and puts the settings of the site and of the job in the context, the way
+// listing.begin does for the runs. The fallback language is resolved for the jobs having one, the feeds have none.
+func (p *Previewer) begin(ctx context.Context, job listingJob, fallback bool) (context.Context, *entity.Site, string, error) {
+	site, err := p.siteRepo.FindByID(ctx, job.siteID)
+	if err != nil {
+		return ctx, nil, "", fmt.Errorf("%s find site %v error: %w", OpPreview, job.siteID, err)
+	}
+
+	var lang string
+	if fallback {
+		if lang, err = fallbackLang(job.lang, site); err != nil {
+			return ctx, nil, "", fmt.Errorf("%s site %v %w", OpPreview, site.ID, err)
+		}
+	}
+
+	if site.RespectsRobots() {
+		ctx = WithRobots(ctx)
+	}
+
+	if site.Extracts(job.extract) {
+		ctx = WithExtract(ctx)
+	}
+
+	return WithRules(ctx, job.rules), site, lang, nil
+}
+
+func (p *Previewer) fetch(ctx context.Context, preview *model.JobPreview, link string) ([]byte, error) {
+	res, err := p.fetcher.Conditional(ctx, link, nil)
+	if res != nil {
+		preview.Status = res.state.Status
+	}
+	if err != nil {
+		preview.Error = err.Error()
+		return nil, err
+	}
+	return res.body, nil
+}
+
+type previewResult struct {
+	link    string
+	article *entity.Article
+	err     error
+}
+
+func collect[T any](
+	ctx context.Context,
+	pool *Pool,
+	preview *model.JobPreview,
+	items []T,
+	link func(T) string,
+	build func(context.Context, T) (*entity.Article, error),
+) {
+	preview.Articles = make([]model.Article, 0, len(items))
+
+	Ordered(ctx, pool, 0, items, func(ctx context.Context, item T) previewResult {
+		article, err := build(ctx, item)
+		return previewResult{link: link(item), article: article, err: err}
+	}, func(r previewResult) bool {
+		if r.err != nil {
+			preview.Errors = append(preview.Errors, model.JobPreviewError{Link: r.link, Error: r.err.Error()})
+		} else {
+			preview.Articles = append(preview.Articles, model.ArticleFromEntity(r.article))
+		}
+		return true
+	})
+
+	if err := ctx.Err(); err != nil {
+		preview.Error = err.Error()
+	}
+}
@@ -0,0 +1,317 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"github.com/abadojack/whatlanggo"
+	"github.com/google/uuid"
+	"github.com/otiai10/opengraph/v2"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/pkg/util"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	StageLink     = "link"
+	StageMetadata = "metadata"
+	StageText     = "text"
+	StageLang     = "lang"
+	StageMedia    = "media"
+	StageFilter   = "filter"
+	StageTag      = "tag"
+)
+
+var (
+	DefaultStages = []string{StageLink, StageMetadata, StageText, StageLang, StageMedia, StageFilter, StageTag}
+
+	// MandatoryStages run for every site in this order, the stages of a site add the optional ones between them.
+	MandatoryStages = []string{StageLink, StageText, StageLang, StageFilter}
+)
+
+// CheckStages reports the first stage of a site which is unknown or listed twice.
+func CheckStages(stages []string) error {
+	seen := make(map[string]struct{}, len(stages))
+	for _, name := range stages {
+		if !slices.Contains(DefaultStages, name) {
+			return fmt.Errorf("stage %s is unknown", name)
+		}
+		if _, ok := seen[name]; ok {
+			return fmt.Errorf("stage %s is listed twice", name)
+		}
+		seen[name] = struct{}{}
+	}
+	return nil
+}
+
+// ArticleDraft holds what a source knows about an item, the stages of the processor build the article from it.
+type ArticleDraft struct {
+	Site         *entity.Site
+	Source       entity.Source
+	Link         string
+	Title        string
+	Desc         string
+	Content      string
+	Lang         string
+	FallbackLang string
+	PubDate      *time.Time
+	UpdatedAt    *time.Time
+	Categories   []string
+	Authors      []string
+	Media        []entity.Media
+	Digest       string
+
+	OG        *opengraph.OpenGraph
+	Extracted *entity.ArticleContent
+	Article   *entity.Article
+}
+
+type ArticleStage interface {
+	Process(ctx context.Context, draft *ArticleDraft) error
+}
+
+type ArticleStageFunc func(ctx context.Context, draft *ArticleDraft) error
+
+func (fn ArticleStageFunc) Process(ctx context.Context, draft *ArticleDraft) error {
+	return fn(ctx, draft)
+}
+
+// ArticleProcessor runs the drafts of all sources through the same ordered stages,
+// a site may choose the optional stages it runs and their order, DefaultStages are run otherwise.
+type ArticleProcessor struct {
+	logger  *slog.Logger
+	fetcher *Fetcher
+	stages  map[string]ArticleStage
+}
+
+func NewArticleProcessor(fetcher *Fetcher, logger *slog.Logger) *ArticleProcessor {
+	p := &ArticleProcessor{logger: logger, fetcher: fetcher, stages: make(map[string]ArticleStage)}
+
+	p.Use(StageLink, ArticleStageFunc(p.link))
+	p.Use(StageMetadata, ArticleStageFunc(p.metadata))
+	p.Use(StageText, ArticleStageFunc(p.text))
+	p.Use(StageLang, ArticleStageFunc(p.lang))
+	p.Use(StageMedia, ArticleStageFunc(p.media))
+	p.Use(StageFilter, ArticleStageFunc(p.filter))
+	p.Use(StageTag, ArticleStageFunc(p.tag))
+
+	return p
+}
+
+// Use registers the stage under the name, a registered stage is replaced.
+func (p *ArticleProcessor) Use(name string, stage ArticleStage) {
+	p.stages[name] = stage
+}
+
+func (p *ArticleProcessor) Process(ctx context.Context, draft *ArticleDraft) (*entity.Article, error) {
+	draft.OG = opengraph.New(draft.Link)
+	draft.Article = &entity.Article{
+		ID:              uuid.New(),
+		SiteID:          draft.Site.ID,
+		Source:          draft.Source,
+		Digest:          draft.Digest,
+		SourceUpdatedAt: draft.UpdatedAt,
+	}
+	draft.Article.SetLink(draft.Link, draft.Link)
+
+	if draft.PubDate != nil && !draft.PubDate.IsZero() {
+		draft.Article.PubDate = *draft.PubDate
+	} else {
+		draft.Article.PubDate = time.Now()
+	}
+
+	for _, name := range p.chain(draft.Site) {
+		if err := p.stages[name].Process(ctx, draft); err != nil {
+			return nil, err
+		}
+	}
+
+	return draft.Article, nil
+}
+
+// chain returns the stages of the site with the mandatory ones in their order, an optional stage runs
+// after the mandatory stages listed before it by the site, at least after the link stage.
+func (p *ArticleProcessor) chain(site *entity.Site) []string {
+	if len(site.Stages) == 0 {
+		return DefaultStages
+	}
+
+	chain := make([]string, 0, len(MandatoryStages)+len(site.Stages))
+	next := 0
+
+	flush := func(until string) {
+		for next < len(MandatoryStages) {
+			chain = append(chain, MandatoryStages[next])
+			if next++; MandatoryStages[next-1] == until {
+				return
+			}
+		}
+	}
+
+	for _, name := range site.Stages {
+		switch {
+		case slices.Contains(chain, name):
+		case slices.Contains(MandatoryStages, name):
+			flush(name)
+		case p.stages[name] != nil:
+			if next == 0 {
+				flush(StageLink)
+			}
+			chain = append(chain, name)
+		}
+	}
+	flush("")
+
+	return chain
+}
+
+func (p *ArticleProcessor) link(_ context.Context, draft *ArticleDraft) error {
+	draft.Link = strings.TrimSpace(draft.Link)
+
+	u, err := url.Parse(draft.Link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: article link %s is invalid", ErrItemSkipped, draft.Link)
+	}
+
+	draft.Article.SetLink(p.fetcher.Canonical(draft.Link, nil), draft.Link)
+	return nil
+}
+
+func (p *ArticleProcessor) metadata(ctx context.Context, draft *ArticleDraft) error {
+	og, content, err := p.fetcher.OpenGraph(ctx, draft.Link)
+	if err != nil {
+		return fmt.Errorf("%s error: %w", OpServerParseArticle, err)
+	}
+
+	p.logger.Debug("article link parsed", "article", og)
+
+	draft.OG, draft.Extracted = og, content
+	draft.Article.SetLink(p.fetcher.Canonical(draft.Link, og), draft.Link)
+
+	if content != nil {
+		draft.Article.SetContent(*content)
+	}
+	return nil
+}
+
+// text picks the description from the page meta, the extracted content or the source,
+// the title from the source, the page meta or the description.
+func (p *ArticleProcessor) text(_ context.Context, draft *ArticleDraft) error {
+	article := draft.Article
+
+	desc := util.StripHTMLTags(draft.OG.Description)
+	if utf8.RuneCountInString(desc) < minShortDesc {
+		desc = ""
+		if draft.Extracted != nil {
+			desc = summary(draft.Extracted.Text)
+		} else if source := sourceDesc(draft); utf8.RuneCountInString(source) >= minSourceDesc {
+			desc = source
+		}
+	}
+
+	if article.Title = util.StripHTMLTags(draft.Title); article.Title == "" {
+		if article.Title = util.StripHTMLTags(draft.OG.Title); article.Title == "" {
+			if article.Title = desc; utf8.RuneCountInString(article.Title) > 100 {
+				article.Title = strings.TrimSuffix(string([]rune(article.Title)[:97]), ".") + "..."
+			}
+		}
+	}
+
+	if article.Title == "" {
+		return fmt.Errorf("%w: article title not found", ErrItemSkipped)
+	}
+
+	article.Hash = contentHash(draft.Site.ID, article.Title)
+
+	if desc != "" && !strings.EqualFold(article.Title, desc) {
+		article.SetDesc(desc)
+	}
+
+	if longDesc := util.SanitizeHTML(draft.Content); longDesc != "" {
+		article.SetLongDesc(longDesc)
+	} else if draft.Extracted != nil {
+		article.SetLongDesc(draft.Extracted.HTML)
+	}
+
+	return nil
+}
+
+// lang keeps the language declared by the source when the site publishes in it,
+// otherwise the language is detected and the fallback language of the draft or of the site is used.
+func (p *ArticleProcessor) lang(_ context.Context, draft *ArticleDraft) error {
+	article := draft.Article
+	site := draft.Site
+
+	if article.Lang = baseLang(draft.Lang); contains(site.Languages, article.Lang) {
+		return nil
+	}
+
+	text := article.Title
+	if article.Desc != nil {
+		text += " " + *article.Desc
+	}
+	text += " " + sourceDesc(draft)
+
+	if article.Lang = whatlanggo.DetectLang(text).Iso6391(); contains(site.Languages, article.Lang) {
+		return nil
+	}
+
+	if draft.FallbackLang != "" {
+		article.Lang = draft.FallbackLang
+	} else if len(site.Languages) > 0 {
+		article.Lang = site.Languages[0]
+	}
+	return nil
+}
+
+// media prefers the media of the page meta, the media of the source fill in the types the page has none of.
+func (p *ArticleProcessor) media(_ context.Context, draft *ArticleDraft) error {
+	if media := mergeMedia(toMedia(draft.OG), draft.Media); len(media) > 0 {
+		draft.Article.SetMedia(media)
+	}
+	return nil
+}
+
+// filter skips the articles in the languages the site does not publish in, the fallback language
+// of the draft is let through, and rejects the ones the content rules of the job or of the site do not let through.
+func (p *ArticleProcessor) filter(ctx context.Context, draft *ArticleDraft) error {
+	if (draft.FallbackLang == "" || draft.Article.Lang != draft.FallbackLang) && !contains(draft.Site.Languages, draft.Article.Lang) {
+		return fmt.Errorf("%w: article lang %s is not published by the site", ErrItemSkipped, draft.Article.Lang)
+	}
+
+	rules := contentRules(ctx, draft.Site)
+	c := categories(draft.Categories)
+
+	if reason := checkRules(rules, draft.Article, c); reason != "" {
+		return &FilteredError{Article: draft.Article, Categories: c, Reason: reason, Keep: rules.KeepFiltered}
+	}
+	return nil
+}
+
+func (p *ArticleProcessor) tag(_ context.Context, draft *ArticleDraft) error {
+	if c := categories(draft.Categories); len(c) > 0 {
+		draft.Article.SetCategories(c)
+	}
+
+	if len(draft.Authors) > 0 {
+		draft.Article.SetAuthors(draft.Authors)
+	}
+	return nil
+}
+
+// sourceDesc returns the plain description given by the source, its content is used when it has none.
+func sourceDesc(draft *ArticleDraft) string {
+	desc := draft.Desc
+	if desc == "" {
+		desc = draft.Content
+	}
+
+	if desc = util.StripHTMLTags(desc); utf8.RuneCountInString(desc) > maxShortDesc {
+		desc = strings.TrimSuffix(string([]rune(desc)[:maxShortDesc-3]), ".") + "..."
+	}
+	return desc
+}
@@ -0,0 +1,255 @@
+package task
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/cast"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const robotsPrefix = "rumors:robots:"
+
+var ErrRobotsDisallowed = errors.New("disallowed by robots.txt")
+
+type robotsKey struct{}
+
+func WithRobots(ctx context.Context) context.Context {
+	return context.WithValue(ctx, robotsKey{}, true)
+}
+
+func respectRobots(ctx context.Context) bool {
+	v, _ := ctx.Value(robotsKey{}).(bool)
+	return v
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+type Robots struct {
+	delay    time.Duration
+	rules    []robotsRule
+	sitemaps []string
+}
+
+func ParseRobots(r io.Reader, agent string) *Robots {
+	type group struct {
+		agents []string
+		robots Robots
+	}
+
+	var (
+		groups   []*group
+		current  *group
+		rules    bool
+		sitemaps []string
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i > -1 {
+			line = line[:i]
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if current == nil || rules {
+				current = &group{}
+				groups = append(groups, current)
+				rules = false
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if current == nil {
+				continue
+			}
+			rules = true
+			if value != "" {
+				current.robots.rules = append(current.robots.rules, robotsRule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			if current == nil {
+				continue
+			}
+			rules = true
+			current.robots.delay = time.Duration(cast.ToFloat64(value) * float64(time.Second))
+		case "sitemap":
+			if value != "" {
+				sitemaps = append(sitemaps, value)
+			}
+		}
+	}
+
+	agent = strings.ToLower(agent)
+
+	var (
+		matched  *Robots
+		wildcard *Robots
+		length   int
+	)
+
+	for _, g := range groups {
+		for _, a := range g.agents {
+			if a == "*" {
+				if wildcard == nil {
+					wildcard = &g.robots
+				}
+			} else if strings.Contains(agent, a) && len(a) > length {
+				matched = &g.robots
+				length = len(a)
+			}
+		}
+	}
+
+	robots := &Robots{}
+	if matched != nil {
+		robots = matched
+	} else if wildcard != nil {
+		robots = wildcard
+	}
+	robots.sitemaps = sitemaps
+	return robots
+}
+
+func (r *Robots) CrawlDelay() time.Duration {
+	return r.delay
+}
+
+// Sitemaps returns the sitemap links listed in robots.txt, they do not depend on the user agent.
+func (r *Robots) Sitemaps() []string {
+	return r.sitemaps
+}
+
+func (r *Robots) Allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+
+	allow, length := true, -1
+	for _, rule := range r.rules {
+		if robotsMatch(rule.pattern, path) {
+			if n := len(rule.pattern); n > length || (n == length && rule.allow) {
+				allow, length = rule.allow, n
+			}
+		}
+	}
+	return allow
+}
+
+func robotsMatch(pattern, path string) bool {
+	end := strings.HasSuffix(pattern, "$")
+	if end {
+		pattern = pattern[:len(pattern)-1]
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+
+	path = path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !end || path == ""
+	}
+
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(path, part)
+		if i < 0 {
+			return false
+		}
+		path = path[i+len(part):]
+	}
+
+	if last := parts[len(parts)-1]; end {
+		return strings.HasSuffix(path, last)
+	} else {
+		return strings.Contains(path, last)
+	}
+}
+
+func (f *Fetcher) Robots(ctx context.Context, u *url.URL) (*Robots, error) {
+	origin := u.Scheme + "://" + u.Host
+	key := robotsPrefix + strings.ToLower(origin)
+
+	data, err := f.rdb.Get(ctx, key).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("%s %s error: %w", OpFetcherRobots, origin, err)
+	}
+
+	if errors.Is(err, redis.Nil) {
+		if data, err = f.fetchRobots(ctx, origin+"/robots.txt"); err != nil {
+			return nil, fmt.Errorf("%s %s error: %w", OpFetcherRobots, origin, err)
+		}
+		if err = f.rdb.Set(ctx, key, data, f.cfg.Robots.TTL).Err(); err != nil {
+			return nil, fmt.Errorf("%s %s error: %w", OpFetcherRobots, origin, err)
+		}
+	}
+
+	robots := ParseRobots(strings.NewReader(data), f.cfg.Robots.Agent)
+
+	if robots.delay > 0 && f.limiter != nil {
+		if err = f.limiter.CrawlDelay(ctx, u.Hostname(), robots.delay); err != nil {
+			return nil, fmt.Errorf("%s %s error: %w", OpFetcherRobots, origin, err)
+		}
+	}
+
+	return robots, nil
+}
+
+func (f *Fetcher) fetchRobots(ctx context.Context, link string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := f.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 500:
+		return "", fmt.Errorf("error due to request %s with response status code %d", link, res.StatusCode)
+	case res.StatusCode >= 400:
+		return "", nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, 512<<10))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (f *Fetcher) allowed(ctx context.Context, u *url.URL) error {
+	if f.rdb == nil || !respectRobots(ctx) {
+		return nil
+	}
+
+	robots, err := f.Robots(ctx, u)
+	if err != nil {
+		return err
+	}
+
+	if !robots.Allowed(u.RequestURI()) {
+		return fmt.Errorf("%s %w", u, ErrRobotsDisallowed)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dlclark/regexp2"
+	"github.com/google/uuid"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/pkg/repository"
+	"net/url"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const regexTimeout = 100 * time.Millisecond
+
+var ErrItemFiltered = errors.New("item filtered")
+
+var rulesRegex sync.Map
+
+// FilteredError reports an article rejected by the content rules.
+type FilteredError struct {
+	Article    *entity.Article
+	Categories []string
+	Reason     string
+	Keep       bool
+}
+
+func (e *FilteredError) Error() string {
+	return ErrItemFiltered.Error() + ": " + e.Reason
+}
+
+func (e *FilteredError) Unwrap() error {
+	return ErrItemFiltered
+}
+
+type rulesKey struct{}
+
+// WithRules overrides the content rules of the site by the rules of the job.
+func WithRules(ctx context.Context, rules *entity.ContentRules) context.Context {
+	if rules == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, rulesKey{}, rules)
+}
+
+func contentRules(ctx context.Context, site *entity.Site) *entity.ContentRules {
+	if rules, ok := ctx.Value(rulesKey{}).(*entity.ContentRules); ok {
+		return rules
+	}
+	return site.Rules
+}
+
+// CompileRule checks the pattern of the rule, compiled patterns are cached.
+func CompileRule(pattern string) (*regexp2.Regexp, error) {
+	if re, ok := rulesRegex.Load(pattern); ok {
+		return re.(*regexp2.Regexp), nil
+	}
+
+	re, err := regexp2.Compile(pattern, regexp2.IgnoreCase)
+	if err != nil {
+		return nil, err
+	}
+	re.MatchTimeout = regexTimeout
+
+	rulesRegex.Store(pattern, re)
+	return re, nil
+}
+
+// checkRules returns the reason the article is rejected for, an empty reason means the article passes.
+func checkRules(rules *entity.ContentRules, article *entity.Article, categories []string) string {
+	if rules == nil {
+		return ""
+	}
+
+	var desc string
+	if article.Desc != nil {
+		desc = *article.Desc
+	}
+
+	if rules.MinDescLen > 0 && utf8.RuneCountInString(desc) < rules.MinDescLen {
+		return fmt.Sprintf("desc is shorter than %d", rules.MinDescLen)
+	}
+
+	var path string
+	if u, err := url.Parse(article.Link); err == nil {
+		path = u.Path
+	}
+
+	values := map[entity.RuleField][]string{
+		entity.RuleTitle:    {article.Title},
+		entity.RuleDesc:     {desc},
+		entity.RulePath:     {path},
+		entity.RuleCategory: categories,
+	}
+
+	for _, rule := range rules.Exclude {
+		if matchRule(rule, values[rule.Field]) {
+			return fmt.Sprintf("%s matches exclude rule %s", rule.Field, rule.Pattern)
+		}
+	}
+
+	if len(rules.Include) == 0 {
+		return ""
+	}
+
+	for _, rule := range rules.Include {
+		if matchRule(rule, values[rule.Field]) {
+			return ""
+		}
+	}
+	return "no include rule matches"
+}
+
+func matchRule(rule entity.ContentRule, values []string) bool {
+	re, err := CompileRule(rule.Pattern)
+	if err != nil {
+		return false
+	}
+
+	for _, value := range values {
+		if ok, _ := re.MatchString(value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func saveFiltered(ctx context.Context, repo repository.WriteRepository[*entity.FilteredArticle], jobID *uuid.UUID, err *FilteredError) error {
+	if !err.Keep || repo == nil {
+		return nil
+	}
+
+	filtered := entity.NewFilteredArticle(err.Article, jobID, err.Categories, err.Reason)
+	if err := repo.Save(ctx, filtered); err != nil {
+		return fmt.Errorf("%s save filtered article %s error: %w", OpServerProcessTask, filtered.Link, err)
+	}
+	return nil
+}
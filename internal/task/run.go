@@ -0,0 +1,191 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/rumorsflow/rumors/v2/internal/common"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/pkg/repository"
+	"golang.org/x/exp/slog"
+	"sync"
+	"time"
+)
+
+// Run collects the outcome of a single job execution, it is safe for concurrent use and nil safe.
+type Run struct {
+	mu       sync.Mutex
+	run      entity.JobRun
+	canceled bool
+}
+
+func (r *Run) update(fn func(s *entity.JobRunStats)) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fn(&r.run.JobRunStats)
+}
+
+func (r *Run) Status(code int) {
+	r.update(func(s *entity.JobRunStats) { s.Status = code })
+}
+
+func (r *Run) Seen(n int) {
+	r.update(func(s *entity.JobRunStats) { s.Seen += n })
+}
+
+func (r *Run) Saved() {
+	r.update(func(s *entity.JobRunStats) { s.Saved++ })
+}
+
+func (r *Run) Updated() {
+	r.update(func(s *entity.JobRunStats) { s.Updated++ })
+}
+
+func (r *Run) Filtered() {
+	r.update(func(s *entity.JobRunStats) { s.Filtered++ })
+}
+
+func (r *Run) Duplicates(n int) {
+	r.update(func(s *entity.JobRunStats) { s.Duplicates += n })
+}
+
+func (r *Run) OGFailure() {
+	r.update(func(s *entity.JobRunStats) { s.OGFailures++ })
+}
+
+func (r *Run) Unchanged() {
+	r.update(func(s *entity.JobRunStats) { s.Unchanged = true })
+}
+
+func (r *Run) Fail(err error) {
+	if err == nil {
+		return
+	}
+	r.update(func(s *entity.JobRunStats) { s.Error = err.Error() })
+
+	if errors.Is(err, context.Canceled) {
+		r.mu.Lock()
+		r.canceled = true
+		r.mu.Unlock()
+	}
+}
+
+func (r *Run) Fetched(res *fetched) {
+	if res != nil {
+		r.Status(res.state.Status)
+		if res.unchanged {
+			r.Unchanged()
+		}
+	}
+}
+
+func (r *Run) JobID() *uuid.UUID {
+	if r == nil {
+		return nil
+	}
+	return &r.run.JobID
+}
+
+func (r *Run) Stats() entity.JobRunStats {
+	if r == nil {
+		return entity.JobRunStats{}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.run.JobRunStats
+}
+
+// Runs stores the history of job executions, the last run summary and the health of jobs.
+type Runs struct {
+	cfg       *HealthConfig
+	logger    *slog.Logger
+	publisher common.Pub
+	runRepo   repository.WriteRepository[*entity.JobRun]
+	jobRepo   repository.ReadWriteRepository[*entity.Job]
+}
+
+func NewRuns(
+	cfg *HealthConfig,
+	runRepo repository.WriteRepository[*entity.JobRun],
+	jobRepo repository.ReadWriteRepository[*entity.Job],
+	publisher common.Pub,
+	logger *slog.Logger,
+) *Runs {
+	return &Runs{cfg: cfg, logger: logger, publisher: publisher, runRepo: runRepo, jobRepo: jobRepo}
+}
+
+// Start returns nil for tasks enqueued without a job, such runs are not recorded.
+func (r *Runs) Start(ctx context.Context, name entity.JobName, jobID *uuid.UUID, siteID uuid.UUID, link, group string) *Run {
+	if r == nil || jobID == nil {
+		return nil
+	}
+
+	taskID, _ := asynq.GetTaskID(ctx)
+
+	return &Run{run: entity.JobRun{
+		ID:          uuid.New(),
+		JobID:       *jobID,
+		SiteID:      siteID,
+		Name:        name,
+		TaskID:      taskID,
+		Group:       group,
+		Link:        link,
+		JobRunStats: entity.JobRunStats{StartedAt: time.Now()},
+	}}
+}
+
+func (r *Runs) Finish(run *Run) {
+	if r == nil || run == nil {
+		return
+	}
+
+	run.update(func(s *entity.JobRunStats) { s.FinishedAt = time.Now() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := r.save(ctx, run); err != nil {
+		r.logger.Error("error due to save job run", "err", err, "job_id", run.run.JobID)
+	}
+
+	run.mu.Lock()
+	jobRun, canceled := run.run, run.canceled
+	run.mu.Unlock()
+
+	if jobRun.Group != "" || canceled {
+		return
+	}
+
+	if err := r.health(ctx, jobRun); err != nil {
+		r.logger.Error("error due to update job health", "err", err, "job_id", jobRun.JobID)
+	}
+}
+
+func (r *Runs) save(ctx context.Context, run *Run) error {
+	run.mu.Lock()
+	jobRun := run.run
+	run.mu.Unlock()
+
+	if err := r.runRepo.Save(ctx, &jobRun); err != nil {
+		return fmt.Errorf("%s save job %v run error: %w", OpServerProcessTask, jobRun.JobID, err)
+	}
+
+	if jobRun.Group != "" {
+		return nil
+	}
+
+	if err := r.jobRepo.Update(ctx, (&entity.Job{ID: jobRun.JobID}).SetLastRun(jobRun.JobRunStats)); err != nil {
+		return fmt.Errorf("%s save job %v last run error: %w", OpServerProcessTask, jobRun.JobID, err)
+	}
+
+	return nil
+}
@@ -151,14 +151,7 @@ func (s *Scheduler) add(job *entity.Job) (err error) {
 
 	var payload []byte
 	if job.Payload != nil {
-		switch p := job.Payload.(type) {
-		case *entity.FeedPayload:
-			p.JobID = &id
-		case *entity.SitemapPayload:
-			p.JobID = &id
-		}
-
-		payload, err = json.Marshal(job.Payload)
+		payload, err = json.Marshal(job.TaskPayload())
 		if err != nil {
 			return fmt.Errorf(
 				"%s job %v -> marshal `%s` payload with expr `%s` error: %w",
@@ -172,7 +165,7 @@ func (s *Scheduler) add(job *entity.Job) (err error) {
 	}
 	task := asynq.NewTask(string(job.Name), payload)
 
-	entryID, err := s.s.Register(job.CronExpr, task, opts(job)...)
+	entryID, err := s.s.Register(job.CronExpr, task, Options(job)...)
 	if err != nil {
 		return fmt.Errorf(
 			"%s job %v -> failed to register job `%s` with expr `%s` error: %w",
@@ -255,18 +248,36 @@ func (s *Scheduler) sync(ctx context.Context) error {
 	return nil
 }
 
-func opts(job *entity.Job) []asynq.Option {
+func Options(job *entity.Job) []asynq.Option {
 	if job.Options != nil {
 		options := make([]asynq.Option, len(*job.Options))
 		for i, o := range *job.Options {
-			options[i] = asynqOpt(o)
+			options[i] = Option(o)
 		}
 		return options
 	}
 	return nil
 }
 
-func asynqOpt(o entity.JobOption) asynq.Option {
+// RunOptions returns the options of the job for a task enqueued out of its schedule, like a manual or a pushed run.
+// The options identifying or delaying the scheduled task are left out, the run would conflict with it otherwise.
+func RunOptions(job *entity.Job) []asynq.Option {
+	if job.Options == nil {
+		return nil
+	}
+
+	var options []asynq.Option
+	for _, o := range *job.Options {
+		switch o.Type {
+		case entity.TaskIDOpt, entity.UniqueOpt, entity.ProcessAtOpt, entity.ProcessInOpt:
+		default:
+			options = append(options, Option(o))
+		}
+	}
+	return options
+}
+
+func Option(o entity.JobOption) asynq.Option {
 	switch o.Type {
 	case entity.QueueOpt:
 		return asynq.Queue(o.Value)
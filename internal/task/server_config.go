@@ -14,6 +14,7 @@ type ServerConfig struct {
 	GroupMaxDelay            time.Duration  `mapstructure:"group_max_delay"`
 	GroupMaxSize             int            `mapstructure:"group_max_size"`
 	GracefulTimeout          time.Duration  `mapstructure:"graceful_timeout"`
+	ItemWorkers              int            `mapstructure:"item_workers"`
 }
 
 func (cfg *ServerConfig) Init() {
@@ -24,4 +25,8 @@ func (cfg *ServerConfig) Init() {
 	if _, ok := cfg.Queues[DefaultQueue]; !ok {
 		cfg.Queues[DefaultQueue] = 1
 	}
+
+	if cfg.ItemWorkers == 0 {
+		cfg.ItemWorkers = DefaultItemWorkers
+	}
 }
@@ -1,19 +1,20 @@
 package task
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"github.com/dlclark/regexp2"
 	"github.com/goccy/go-json"
+	"github.com/mmcdole/gofeed"
 	"github.com/otiai10/opengraph/v2"
 	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/pkg/util"
 	"github.com/spf13/cast"
 	"golang.org/x/exp/slices"
 	"golang.org/x/net/html"
-	"net/http"
 	"strings"
 	"sync"
+	"unicode/utf8"
 )
 
 const (
@@ -22,11 +23,27 @@ const (
 	OpMetricsRegister = "task.metrics: register ->"
 	OpMetricsClose    = "task.metrics: close ->"
 
-	OpServerStart        = "task.server: start ->"
-	OpServerProcessTask  = "task.server: process task ->"
-	OpServerParseFeed    = "task.server: parse feed link ->"
-	OpServerParseSitemap = "task.server: parse sitemap link ->"
-	OpServerParseArticle = "task.server: parse article link ->"
+	OpServerStart         = "task.server: start ->"
+	OpServerProcessTask   = "task.server: process task ->"
+	OpServerParseFeed     = "task.server: parse feed link ->"
+	OpServerParseSitemap  = "task.server: parse sitemap link ->"
+	OpServerParseArticle  = "task.server: parse article link ->"
+	OpServerParseListing  = "task.server: parse listing page ->"
+	OpServerParseJSON     = "task.server: parse json api response ->"
+	OpServerParseMail     = "task.server: parse mail message ->"
+	OpServerParseActivity = "task.server: parse activitypub object ->"
+
+	OpFetcherNew     = "task.fetcher: new ->"
+	OpFetcherRobots  = "task.fetcher: robots ->"
+	OpLimiterAcquire = "task.limiter: acquire ->"
+	OpGroup          = "task.group:"
+	OpDedup          = "task.dedup:"
+	OpPreview        = "task.preview: job ->"
+	OpDiscover       = "task.discover: site ->"
+
+	OpWebSubSubscribe = "task.websub: subscribe ->"
+	OpWebSubVerify    = "task.websub: verify ->"
+	OpWebSubReceive   = "task.websub: receive ->"
 
 	OpSchedulerStart  = "task.scheduler: start ->"
 	OpSchedulerSync   = "task.scheduler: sync ->"
@@ -37,6 +54,8 @@ const (
 	OpUnmarshal = "task: unmarshal payload ->"
 )
 
+var ErrItemSkipped = errors.New("item skipped")
+
 const (
 	TgCmdStart  = "start"
 	TgCmdRumors = "rumors"
@@ -57,9 +76,10 @@ const (
 	TelegramChat      = TelegramPrefix + "chat:"
 	TelegramChatNew   = TelegramChat + "new"
 	TelegramChatEdit  = TelegramChat + "edit"
+	TelegramPost      = TelegramPrefix + "post"
 )
 
-const userAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:109.0) Gecko/20100101 Firefox/111.0"
+const MailNewsletter = "mail:newsletter"
 
 var regexMap sync.Map
 
@@ -108,40 +128,6 @@ func searchByLoc(r *string, str string) string {
 	return ""
 }
 
-func openGraphFetch(ctx context.Context, url string) (*opengraph.OpenGraph, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", userAgent)
-
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-
-	if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") {
-		return nil, errors.New("content type must be text/html")
-	}
-
-	if res.StatusCode >= 400 {
-		return nil, fmt.Errorf("open graph error due to request %s with response status code %d", url, res.StatusCode)
-	}
-
-	og := opengraph.New(url)
-	og.Intent.TrustedTags = []string{opengraph.HTMLMetaTag, opengraph.HTMLTitleTag, opengraph.HTMLLinkTag}
-	node, err := html.Parse(res.Body)
-	if err != nil {
-		return nil, err
-	}
-	if err = walk(og, node); err != nil {
-		return nil, err
-	}
-
-	return og, nil
-}
-
 func walk(og *opengraph.OpenGraph, node *html.Node) error {
 	if node.Type == html.ElementNode {
 		switch {
@@ -150,7 +136,7 @@ func walk(og *opengraph.OpenGraph, node *html.Node) error {
 		case node.Data == opengraph.HTMLTitleTag && trust(og, node.Data):
 			return opengraph.TitleTag(node).Contribute(og)
 		case node.Data == opengraph.HTMLLinkTag && trust(og, node.Data):
-			return opengraph.LinkTag(node).Contribute(og)
+			return LinkTag(node).Contribute(og)
 		}
 	}
 
@@ -219,6 +205,47 @@ func pagination(args string) (i uint64, s uint64, search string) {
 	return
 }
 
+func categories(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	result := make([]string, 0, len(values))
+
+	for _, value := range values {
+		for _, category := range strings.Split(value, ",") {
+			category = strings.ToLower(util.StripHTMLTags(category))
+			if category == "" || utf8.RuneCountInString(category) > 100 {
+				continue
+			}
+			if _, ok := seen[category]; !ok {
+				seen[category] = struct{}{}
+				result = append(result, category)
+			}
+		}
+	}
+
+	return result
+}
+
+func authors(people []*gofeed.Person) []string {
+	result := make([]string, 0, len(people))
+
+	for _, person := range people {
+		if person == nil {
+			continue
+		}
+
+		name := util.StripHTMLTags(person.Name)
+		if name == "" {
+			name = person.Email
+		}
+
+		if name != "" && !slices.Contains(result, name) {
+			result = append(result, name)
+		}
+	}
+
+	return result
+}
+
 func contains(data []string, el string) bool {
 	return slices.ContainsFunc(data, func(s string) bool {
 		return strings.EqualFold(s, el)
@@ -259,3 +286,22 @@ func (meta *Meta) Contribute(og *opengraph.OpenGraph) (err error) {
 	}
 	return nil
 }
+
+type Link struct {
+	*opengraph.Link
+}
+
+func LinkTag(node *html.Node) *Link {
+	return &Link{Link: opengraph.LinkTag(node)}
+}
+
+// Contribute keeps og:url over the canonical link when a page has both.
+func (link *Link) Contribute(og *opengraph.OpenGraph) error {
+	if strings.EqualFold(link.Rel, "canonical") {
+		if og.URL == "" {
+			og.URL = link.Href
+		}
+		return nil
+	}
+	return link.Link.Contribute(og)
+}
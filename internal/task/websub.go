@@ -0,0 +1,446 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rumorsflow/rumors/v2/internal/common"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	"github.com/rumorsflow/rumors/v2/pkg/repository"
+	"github.com/spf13/cast"
+	"golang.org/x/exp/slog"
+	"golang.org/x/net/html/charset"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrWebSubIntent    = errors.New("websub intent is not confirmed")
+	ErrWebSubSignature = errors.New("websub signature is not valid")
+)
+
+// WebSub subscribes the feed jobs to the hubs their feeds advertise, verifies the intents of the hubs
+// and enqueues the feeds the hubs deliver. A job pushed by its hub is polled once per fallback period only.
+type WebSub struct {
+	cfg     *WebSubConfig
+	fetcher *Fetcher
+	jobRepo repository.ReadWriteRepository[*entity.Job]
+	client  common.Client
+	logger  *slog.Logger
+}
+
+func NewWebSub(cfg *WebSubConfig, fetcher *Fetcher, jobRepo repository.ReadWriteRepository[*entity.Job], client common.Client, logger *slog.Logger) *WebSub {
+	return &WebSub{
+		cfg:     cfg,
+		fetcher: fetcher,
+		jobRepo: jobRepo,
+		client:  client,
+		logger:  logger,
+	}
+}
+
+func (s *WebSub) Enabled() bool {
+	return s != nil && s.cfg.Callback != ""
+}
+
+// Polls reports whether the scheduled run of the feed job fetches the feed, the subscription is renewed
+// before its lease is over and requested again when it lapsed or the hub did not verify it for long.
+func (s *WebSub) Polls(ctx context.Context, jobID *uuid.UUID) bool {
+	if !s.Enabled() || jobID == nil {
+		return true
+	}
+
+	job, err := s.jobRepo.FindByID(ctx, *jobID)
+	if err != nil || job.WebSub == nil {
+		return true
+	}
+
+	ws := job.WebSub
+	now := time.Now()
+
+	switch {
+	case ws.Active(now):
+		if ws.ExpiresAt.Sub(now) < s.cfg.RenewBefore && ws.ChangedAt.Before(ws.ExpiresAt.Add(-s.cfg.RenewBefore)) {
+			s.subscribe(ctx, job.ID, ws.Hub, ws.Topic)
+		}
+		return job.FetchState == nil || now.Sub(job.FetchState.CheckedAt) >= s.cfg.Fallback
+	case ws.Status == entity.WebSubActive:
+		s.logger.Info("websub subscription lapsed", "job_id", job.ID, "hub", ws.Hub, "topic", ws.Topic)
+		s.subscribe(ctx, job.ID, ws.Hub, ws.Topic)
+	case now.Sub(ws.ChangedAt) >= s.cfg.Fallback:
+		s.subscribe(ctx, job.ID, ws.Hub, ws.Topic)
+	}
+
+	return true
+}
+
+// Discover subscribes the feed job to the hub advertised by the fetched feed,
+// unless the job is already subscribed to the same hub and topic.
+func (s *WebSub) Discover(ctx context.Context, jobID *uuid.UUID, link string, res *fetched) {
+	if !s.Enabled() || jobID == nil || res == nil {
+		return
+	}
+
+	hub, topic := hubLinks(link, res.header, res.body)
+	if hub == "" {
+		return
+	}
+	if topic == "" {
+		topic = link
+	}
+
+	job, err := s.jobRepo.FindByID(ctx, *jobID)
+	if err != nil {
+		return
+	}
+
+	if ws := job.WebSub; ws != nil && ws.Hub == hub && ws.Topic == topic {
+		return
+	}
+
+	s.subscribe(ctx, job.ID, hub, topic)
+}
+
+func (s *WebSub) subscribe(ctx context.Context, jobID uuid.UUID, hub, topic string) {
+	if err := s.Subscribe(ctx, jobID, hub, topic); err != nil {
+		s.logger.Warn("error due to subscribe to websub hub", "err", err, "job_id", jobID, "hub", hub, "topic", topic)
+	}
+}
+
+// Subscribe requests the hub to deliver the topic to the callback of the job. An active subscription
+// to the same hub and topic stays active while it is renewed, the secret of the hub and topic is kept.
+func (s *WebSub) Subscribe(ctx context.Context, jobID uuid.UUID, hub, topic string) error {
+	job, err := s.jobRepo.FindByID(ctx, jobID)
+	if err != nil {
+		return fmt.Errorf("%s find job %v error: %w", OpWebSubSubscribe, jobID, err)
+	}
+
+	now := time.Now()
+	ws := entity.WebSub{Hub: hub, Topic: topic, Status: entity.WebSubPending, ChangedAt: now}
+
+	if prev := job.WebSub; prev != nil && prev.Hub == hub && prev.Topic == topic {
+		if prev.Active(now) {
+			ws = *prev
+			ws.Reason = ""
+			ws.ChangedAt = now
+		}
+		ws.Secret = prev.Secret
+	}
+
+	if ws.Secret == "" {
+		if ws.Secret, err = secret(); err != nil {
+			return fmt.Errorf("%s secret error: %w", OpWebSubSubscribe, err)
+		}
+	}
+
+	// the state is saved first, hubs may verify the intent before they answer the request
+	if err = s.save(ctx, jobID, ws); err != nil {
+		return fmt.Errorf("%s %w", OpWebSubSubscribe, err)
+	}
+
+	form := url.Values{
+		"hub.mode":          {"subscribe"},
+		"hub.topic":         {topic},
+		"hub.callback":      {s.callback(jobID)},
+		"hub.lease_seconds": {strconv.Itoa(int(s.cfg.Lease.Seconds()))},
+		"hub.secret":        {ws.Secret},
+	}
+
+	if err = s.request(ctx, hub, form); err == nil {
+		s.logger.Info("websub subscription requested", "job_id", jobID, "hub", hub, "topic", topic)
+		return nil
+	}
+
+	if ws.Status != entity.WebSubActive {
+		ws.Status = entity.WebSubDenied
+	}
+	ws.Reason = err.Error()
+
+	if e := s.save(ctx, jobID, ws); e != nil {
+		s.logger.Error("error due to save websub state", "err", e, "job_id", jobID)
+	}
+
+	return fmt.Errorf("%s %w", OpWebSubSubscribe, err)
+}
+
+func (s *WebSub) request(ctx context.Context, hub string, form url.Values) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := s.fetcher.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		reason, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("hub %s responded with status code %d %s", hub, res.StatusCode, strings.TrimSpace(string(reason)))
+	}
+	return nil
+}
+
+// Verify confirms the intent of the hub and returns the challenge to echo. A subscription is confirmed
+// when it is requested for the topic of the job, an unsubscription when the job is not subscribed to the topic.
+// The denial of a subscription returns no challenge.
+func (s *WebSub) Verify(ctx context.Context, jobID uuid.UUID, query url.Values) (string, error) {
+	mode := query.Get("hub.mode")
+	topic := query.Get("hub.topic")
+	challenge := query.Get("hub.challenge")
+
+	// the intents for a removed job are unknown, except for the unsubscription confirmed by the removal
+	job, err := s.jobRepo.FindByID(ctx, jobID)
+	if err != nil && !errors.Is(err, repository.ErrEntityNotFound) {
+		return "", fmt.Errorf("%s find job %v error: %w", OpWebSubVerify, jobID, err)
+	}
+
+	now := time.Now()
+
+	switch mode {
+	case "subscribe":
+		if job == nil {
+			return "", ErrWebSubIntent
+		}
+
+		ws := job.WebSub
+		if challenge == "" || !job.Active() || ws == nil || ws.Topic != topic || (ws.Status != entity.WebSubPending && ws.Status != entity.WebSubActive) {
+			return "", ErrWebSubIntent
+		}
+
+		lease := cast.ToInt(query.Get("hub.lease_seconds"))
+		if lease <= 0 {
+			lease = int(s.cfg.Lease.Seconds())
+		}
+
+		state := *ws
+		state.Status = entity.WebSubActive
+		state.LeaseSeconds = lease
+		state.ExpiresAt = now.Add(time.Duration(lease) * time.Second)
+		state.Reason = ""
+		state.ChangedAt = now
+
+		if err = s.save(ctx, jobID, state); err != nil {
+			return "", fmt.Errorf("%s %w", OpWebSubVerify, err)
+		}
+
+		s.logger.Info("websub subscription verified", "job_id", jobID, "topic", topic, "lease_seconds", lease)
+
+		return challenge, nil
+	case "unsubscribe":
+		if challenge == "" || (job != nil && job.Active() && job.WebSub.Active(now) && job.WebSub.Topic == topic) {
+			return "", ErrWebSubIntent
+		}
+		return challenge, nil
+	case "denied":
+		if job == nil || job.WebSub == nil || job.WebSub.Topic != topic {
+			return "", ErrWebSubIntent
+		}
+
+		state := *job.WebSub
+		state.Status = entity.WebSubDenied
+		state.Reason = query.Get("hub.reason")
+		state.ChangedAt = now
+
+		if err = s.save(ctx, jobID, state); err != nil {
+			return "", fmt.Errorf("%s %w", OpWebSubVerify, err)
+		}
+
+		s.logger.Warn("websub subscription denied", "job_id", jobID, "topic", topic, "reason", state.Reason)
+
+		return "", nil
+	}
+
+	return "", ErrWebSubIntent
+}
+
+// ReadBody reads the content delivered by a hub, at most MaxBodySize bytes of the fetcher.
+func (s *WebSub) ReadBody(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, s.fetcher.cfg.MaxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > s.fetcher.cfg.MaxBodySize {
+		return nil, fmt.Errorf("%w: websub content exceeds %d bytes", ErrBodyTooLarge, s.fetcher.cfg.MaxBodySize)
+	}
+	return data, nil
+}
+
+// Receive enqueues the feed job with the content delivered by the hub, the content must be signed by the secret of the subscription.
+func (s *WebSub) Receive(ctx context.Context, jobID uuid.UUID, body []byte, signature string) error {
+	job, err := s.jobRepo.FindByID(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, repository.ErrEntityNotFound) {
+			return ErrWebSubIntent
+		}
+		return fmt.Errorf("%s find job %v error: %w", OpWebSubReceive, jobID, err)
+	}
+
+	payload, ok := job.Payload.(*entity.FeedPayload)
+	if !ok || !job.Active() || job.WebSub == nil || job.WebSub.Status != entity.WebSubActive {
+		return ErrWebSubIntent
+	}
+
+	if !validSignature(job.WebSub.Secret, signature, body) {
+		return ErrWebSubSignature
+	}
+
+	if len(body) == 0 {
+		return nil
+	}
+
+	payload.Pushed = body
+
+	if err = s.client.Enqueue(ctx, string(entity.JobFeed), job.TaskPayload(), RunOptions(job)...); err != nil {
+		return fmt.Errorf("%s %w", OpWebSubReceive, err)
+	}
+
+	s.logger.Debug("websub content received", "job_id", jobID, "size", len(body))
+
+	return nil
+}
+
+func (s *WebSub) callback(jobID uuid.UUID) string {
+	return s.cfg.Callback + "/websub/" + jobID.String()
+}
+
+func (s *WebSub) save(ctx context.Context, jobID uuid.UUID, ws entity.WebSub) error {
+	if err := s.jobRepo.Update(ctx, (&entity.Job{ID: jobID}).SetWebSub(ws)); err != nil {
+		return fmt.Errorf("save job %v websub state error: %w", jobID, err)
+	}
+	return nil
+}
+
+// validSignature checks the X-Hub-Signature of the content, method=hex HMAC of the content by the secret.
+func validSignature(secret, signature string, body []byte) bool {
+	method, sum, ok := strings.Cut(strings.TrimSpace(signature), "=")
+	if !ok || secret == "" {
+		return false
+	}
+
+	var fn func() hash.Hash
+
+	switch strings.ToLower(method) {
+	case "sha1":
+		fn = sha1.New
+	case "sha256":
+		fn = sha256.New
+	case "sha384":
+		fn = sha512.New384
+	case "sha512":
+		fn = sha512.New
+	default:
+		return false
+	}
+
+	expected, err := hex.DecodeString(sum)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(fn, []byte(secret))
+	mac.Write(body)
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+func secret() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// hubLinks returns the hub and the self links advertised by the Link header or by the feed links of the document,
+// the header takes precedence. Relative links are resolved against the feed link.
+func hubLinks(link string, header http.Header, body []byte) (hub, self string) {
+	base, err := url.Parse(link)
+	if err != nil {
+		return "", ""
+	}
+
+	set := func(rel, href string) {
+		u, err := base.Parse(strings.TrimSpace(href))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return
+		}
+		switch {
+		case hub == "" && strings.EqualFold(rel, "hub"):
+			hub = u.String()
+		case self == "" && strings.EqualFold(rel, "self"):
+			self = u.String()
+		}
+	}
+
+	for _, value := range header.Values("Link") {
+		for _, part := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(part), ";")
+			target = strings.TrimSpace(target)
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				key, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(strings.TrimSpace(key), "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(val), `"`)) {
+					set(rel, target[1:len(target)-1])
+				}
+			}
+		}
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "item", "entry":
+			// the links of the items are not the links of the feed
+			return
+		case "link":
+			var rel, href string
+			for _, attr := range start.Attr {
+				switch attr.Name.Local {
+				case "rel":
+					rel = attr.Value
+				case "href":
+					href = attr.Value
+				}
+			}
+			for _, r := range strings.Fields(rel) {
+				set(r, href)
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package task
+
+import (
+	"github.com/rumorsflow/rumors/v2/pkg/config"
+	"strings"
+	"time"
+)
+
+type WebSubConfig struct {
+	// Callback is the public base URL of the http plugin the hubs deliver to, an empty value disables WebSub.
+	Callback string        `mapstructure:"callback"`
+	Lease    time.Duration `mapstructure:"lease"`
+	// RenewBefore is how long before the lease is over the subscription is renewed.
+	RenewBefore time.Duration `mapstructure:"renew_before"`
+	// Fallback is how often a feed pushed by a hub is still polled, a pending or denied subscription is retried as often.
+	Fallback time.Duration `mapstructure:"fallback"`
+}
+
+// LoadWebSubConfig reads the websub section, defaults are applied when the section is absent.
+func LoadWebSubConfig(cfg config.Configurer) (*WebSubConfig, error) {
+	var wc WebSubConfig
+	if cfg.Has(sectionWebSub) {
+		if err := cfg.UnmarshalKey(sectionWebSub, &wc); err != nil {
+			return nil, err
+		}
+	}
+	wc.Init()
+
+	return &wc, nil
+}
+
+func (cfg *WebSubConfig) Init() {
+	cfg.Callback = strings.TrimRight(strings.TrimSpace(cfg.Callback), "/")
+
+	if cfg.Lease <= 0 {
+		cfg.Lease = 10 * 24 * time.Hour
+	}
+
+	if cfg.RenewBefore <= 0 {
+		cfg.RenewBefore = 24 * time.Hour
+	}
+
+	if cfg.Fallback <= 0 {
+		cfg.Fallback = 24 * time.Hour
+	}
+}
@@ -0,0 +1,327 @@
+package task_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/gowool/wool"
+	"github.com/hibiken/asynq"
+	"github.com/rumorsflow/rumors/v2/internal/common"
+	"github.com/rumorsflow/rumors/v2/internal/entity"
+	rumorshttp "github.com/rumorsflow/rumors/v2/internal/http"
+	"github.com/rumorsflow/rumors/v2/internal/task"
+	"golang.org/x/exp/slog"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// news serves an Atom feed advertising the hub, the newest entry first.
+type news struct {
+	mu       sync.Mutex
+	base     string
+	hub      string
+	entries  []int
+	requests int
+}
+
+func (n *news) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	n.mu.Lock()
+	n.requests++
+	n.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/atom+xml")
+	_, _ = io.WriteString(w, n.feed())
+}
+
+func (n *news) topic() string {
+	return n.base + "/feed.atom"
+}
+
+func (n *news) post(entry int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.entries = append([]int{entry}, n.entries...)
+}
+
+func (n *news) fetched() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.requests
+}
+
+func (n *news) feed() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var b strings.Builder
+
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><feed xmlns="http://www.w3.org/2005/Atom"><title>News</title>`)
+	fmt.Fprintf(&b, `<link rel="hub" href="%s"/><link rel="self" href="%s"/>`, n.hub, n.topic())
+
+	for _, entry := range n.entries {
+		fmt.Fprintf(&b, `<entry><id>%[1]s/stories/%[2]d</id><title>The harbour story number %[2]d is told here</title>`+
+			`<link href="%[1]s/stories/%[2]d"/><updated>2026-10-%02[2]dT10:00:00Z</updated></entry>`, n.base, entry)
+	}
+
+	b.WriteString(`</feed>`)
+
+	return b.String()
+}
+
+// hub stands in for a WebSub hub, it verifies the intent of a subscriber by a request to its callback
+// before it accepts the subscription and pushes signed content to the callback.
+type hub struct {
+	t        *testing.T
+	mu       sync.Mutex
+	requests []url.Values
+}
+
+func (h *hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	h.requests = append(h.requests, r.PostForm)
+	h.mu.Unlock()
+
+	challenge := uuid.NewString()
+
+	query := url.Values{
+		"hub.mode":          {r.PostForm.Get("hub.mode")},
+		"hub.topic":         {r.PostForm.Get("hub.topic")},
+		"hub.challenge":     {challenge},
+		"hub.lease_seconds": {"3600"},
+	}
+
+	status, body := h.verify(r.PostForm.Get("hub.callback"), query)
+	if status != http.StatusOK || body != challenge {
+		h.t.Errorf("intent verification answered %d %q, want 200 and the challenge echoed", status, body)
+		http.Error(w, "intent not verified", http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *hub) verify(callback string, query url.Values) (int, string) {
+	res, err := http.Get(callback + "?" + query.Encode())
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+
+	return res.StatusCode, string(body)
+}
+
+func (h *hub) push(callback, secret, content string) int {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(content))
+
+	req, err := http.NewRequest(http.MethodPost, callback, strings.NewReader(content))
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/atom+xml")
+	req.Header.Set("X-Hub-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	return res.StatusCode
+}
+
+func (h *hub) subscriptions() []url.Values {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.requests
+}
+
+// queue records the tasks enqueued instead of sending them.
+type queue struct {
+	common.Client
+	mu    sync.Mutex
+	tasks []*asynq.Task
+}
+
+func (q *queue) Enqueue(_ context.Context, name string, data any, _ ...asynq.Option) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.tasks = append(q.tasks, asynq.NewTask(name, payload))
+	return nil
+}
+
+func (q *queue) enqueued() []*asynq.Task {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.tasks
+}
+
+func TestWebSub(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// the routes are mounted once the websub exists, it needs the address of the callback server
+	routes := http.NewServeMux()
+	callbackSrv := httptest.NewServer(routes)
+	t.Cleanup(callbackSrv.Close)
+
+	h := &hub{t: t}
+	hubSrv := httptest.NewServer(h)
+	t.Cleanup(hubSrv.Close)
+
+	n := &news{hub: hubSrv.URL}
+	newsSrv := httptest.NewServer(n)
+	t.Cleanup(newsSrv.Close)
+	n.base = newsSrv.URL
+
+	cfg := &task.WebSubConfig{Callback: callbackSrv.URL}
+	cfg.Init()
+
+	q := &queue{}
+	fixture := task.NewFeedFixture(t, newsSrv, cfg, q)
+
+	w := wool.New(logger, wool.WithErrorTransform(rumorshttp.ErrorTransform))
+	rumorshttp.NewWebSub(fixture.WebSub, logger).Register(w)
+	routes.Handle("/", w)
+
+	jobID := uuid.New()
+	job := (&entity.Job{
+		ID:      jobID,
+		Name:    entity.JobFeed,
+		Payload: &entity.FeedPayload{SiteID: fixture.Site.ID, Link: n.topic()},
+	}).SetEnabled(true)
+	_ = fixture.Jobs.Save(ctx, job)
+
+	data, _ := json.Marshal(job.TaskPayload())
+	polled := asynq.NewTask(string(entity.JobFeed), data)
+
+	callback := callbackSrv.URL + "/websub/" + jobID.String()
+
+	// the first run discovers the hub, subscribes to it and the hub verifies the intent at the callback
+	n.post(1)
+
+	if err := fixture.Handler.ProcessTask(ctx, polled); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+
+	requests := h.subscriptions()
+	if len(requests) != 1 {
+		t.Fatalf("hub got %d subscription requests, want 1", len(requests))
+	}
+
+	form := requests[0]
+	if form.Get("hub.mode") != "subscribe" || form.Get("hub.topic") != n.topic() || form.Get("hub.callback") != callback {
+		t.Errorf("subscription request = %v, want to subscribe the callback of the job to the self link", form)
+	}
+
+	secret := form.Get("hub.secret")
+
+	job, _ = fixture.Jobs.FindByID(ctx, jobID)
+	if job.WebSub == nil || !job.WebSub.Active(time.Now()) || job.WebSub.Hub != hubSrv.URL {
+		t.Fatalf("websub state = %+v, want the subscription active", job.WebSub)
+	}
+
+	if articles, _ := fixture.Articles.Find(ctx, nil); len(articles) != 1 {
+		t.Fatalf("first run saved %d articles, want 1", len(articles))
+	}
+
+	// a subscribed feed is not polled until the fallback period is over
+	if err := fixture.Handler.ProcessTask(ctx, polled); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if requested := n.fetched(); requested != 1 {
+		t.Errorf("feed requested %d times, want 1, the subscribed feed is not polled", requested)
+	}
+
+	// the content signed by the secret of the subscription enqueues the feed job with the content
+	n.post(2)
+
+	if status := h.push(callback, secret, n.feed()); status != http.StatusAccepted {
+		t.Fatalf("signed push answered %d, want %d", status, http.StatusAccepted)
+	}
+
+	tasks := q.enqueued()
+	if len(tasks) != 1 || tasks[0].Type() != string(entity.JobFeed) {
+		t.Fatalf("enqueued %v, want the feed job", tasks)
+	}
+
+	if err := fixture.Handler.ProcessTask(ctx, tasks[0]); err != nil {
+		t.Fatalf("pushed run: %v", err)
+	}
+
+	articles, _ := fixture.Articles.Find(ctx, nil)
+	if len(articles) != 2 {
+		t.Fatalf("pushed run saved %d articles, want 1", len(articles)-1)
+	}
+	if want := n.base + "/stories/2"; articles[1].Link != want {
+		t.Errorf("pushed article link = %s, want %s", articles[1].Link, want)
+	}
+	if requested := n.fetched(); requested != 1 {
+		t.Errorf("feed requested %d times, want 1, the pushed content is processed as it is", requested)
+	}
+
+	// the content signed by another secret is acknowledged as the spec requires, yet ignored
+	if status := h.push(callback, "forged", n.feed()); status != http.StatusAccepted {
+		t.Errorf("forged push answered %d, want %d", status, http.StatusAccepted)
+	}
+	if tasks = q.enqueued(); len(tasks) != 1 {
+		t.Errorf("enqueued %d tasks, want the forged content left out", len(tasks))
+	}
+
+	// the intents and the content for an unknown job are not found
+	unknown := callbackSrv.URL + "/websub/" + uuid.NewString()
+
+	if status, _ := h.verify(unknown, url.Values{"hub.mode": {"subscribe"}, "hub.topic": {n.topic()}, "hub.challenge": {"challenge"}}); status != http.StatusNotFound {
+		t.Errorf("intent for an unknown job answered %d, want %d", status, http.StatusNotFound)
+	}
+	if status := h.push(unknown, secret, n.feed()); status != http.StatusNotFound {
+		t.Errorf("push for an unknown job answered %d, want %d", status, http.StatusNotFound)
+	}
+
+	// a lapsed lease falls back to polling and the subscription is requested again with the same secret
+	job.WebSub.ExpiresAt = time.Now().Add(-time.Minute)
+
+	if err := fixture.Handler.ProcessTask(ctx, polled); err != nil {
+		t.Fatalf("lapsed run: %v", err)
+	}
+
+	if requested := n.fetched(); requested != 2 {
+		t.Errorf("feed requested %d times, want 2, the lapsed subscription polls the feed", requested)
+	}
+
+	if requests = h.subscriptions(); len(requests) != 2 || requests[1].Get("hub.secret") != secret {
+		t.Fatalf("hub got %v, want the subscription requested again with the same secret", requests)
+	}
+
+	job, _ = fixture.Jobs.FindByID(ctx, jobID)
+	if !job.WebSub.Active(time.Now()) {
+		t.Errorf("websub state = %+v, want the renewed subscription active", job.WebSub)
+	}
+}
@@ -119,7 +119,7 @@ func (p *Poller) listen(done <-chan struct{}) {
 			} else if update.EditedMessage != nil {
 				p.message(ctx, update.EditedMessage, update.UpdateID)
 			} else if update.ChannelPost != nil {
-				p.message(ctx, update.ChannelPost, update.UpdateID)
+				p.post(ctx, update.ChannelPost, update.UpdateID)
 			} else if update.EditedChannelPost != nil {
 				p.message(ctx, update.EditedChannelPost, update.UpdateID)
 			} else if update.MyChatMember != nil {
@@ -131,6 +131,18 @@ func (p *Poller) listen(done <-chan struct{}) {
 	}
 }
 
+// post enqueues the channel posts which are not commands, the posts of the channels linked to a site become articles.
+func (p *Poller) post(ctx context.Context, message *tgbotapi.Message, updateID int) {
+	if message.IsCommand() {
+		p.message(ctx, message, updateID)
+		return
+	}
+
+	if message.Chat != nil {
+		p.client.EnqueueTgPost(ctx, message, updateID)
+	}
+}
+
 func (p *Poller) message(ctx context.Context, message *tgbotapi.Message, updateID int) {
 	if message == nil || message.Chat == nil || !message.IsCommand() {
 		return
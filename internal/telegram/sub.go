@@ -68,9 +68,11 @@ func (s *Subscriber) run(done <-chan struct{}) {
 
 	telegramSub := s.sub.Telegram(ctx)
 	articlesSub := s.sub.Articles(ctx)
+	updatesSub := s.sub.ArticleUpdated(ctx)
 
 	telegramCh := telegramSub.Channel()
 	articlesCh := articlesSub.Channel()
+	updatesCh := updatesSub.Channel()
 
 	defer func() {
 		if err := s.bot.Send(model.Message{View: model.ViewAppStop}); err != nil {
@@ -119,33 +121,7 @@ func (s *Subscriber) run(done <-chan struct{}) {
 			for i := len(articles) - 1; i >= 0; i-- {
 				article := articles[i]
 
-				site, err := s.siteRepo.FindByID(ctx, article.SiteID)
-				if err != nil {
-					err = fmt.Errorf("%s error: %w", OpFindSite, err)
-					s.logger.Error("error due to find site", "err", err, "channel", data.Channel, "article", article.ID, "site", article.SiteID)
-					continue
-				}
-
-				if !*site.Enabled {
-					err = fmt.Errorf("%s site not found", OpFindSite)
-					s.logger.Debug("error due to find site", "err", err, "channel", data.Channel, "article", article.ID, "site", article.SiteID)
-					continue
-				}
-
-				chats, err := s.chatRepo.Find(ctx, db.BuildCriteria(fmt.Sprintf(chatQuery, article.SiteID)))
-				if err != nil {
-					err = fmt.Errorf("%s error: %w", OpFindChats, err)
-					s.logger.Error("error due to find chats", "err", err, "channel", data.Channel, "article", article.ID, "site", article.SiteID)
-					continue
-				}
-
-				if len(chats) == 0 {
-					err = fmt.Errorf("%s chats not found", OpFindChats)
-					s.logger.Debug("error due to find chats", "err", err, "channel", data.Channel, "article", article.ID, "site", article.SiteID)
-					continue
-				}
-
-				for _, chat := range chats {
+				for _, chat := range s.chats(ctx, article, data.Channel) {
 					s.send(model.Message{
 						ChatID:   chat.TelegramID,
 						ImageURL: article.Image,
@@ -155,10 +131,67 @@ func (s *Subscriber) run(done <-chan struct{}) {
 					}, data.Channel)
 				}
 			}
+
+		case data := <-updatesCh:
+			if data == nil {
+				continue
+			}
+
+			var update model.ArticleUpdate
+			if err := json.Unmarshal(util.StringToBytes(data.Payload), &update); err != nil {
+				err = fmt.Errorf("%s error: %w", OpUnmarshalArticles, err)
+				s.logger.Error("error due to unmarshal article update", "err", err, "channel", data.Channel, "payload", data.Payload)
+				continue
+			}
+
+			s.logger.Debug("article update received", "channel", data.Channel, "update", update)
+
+			// only corrected headlines are worth a new message, other changes reach the web clients
+			if !update.Changed("title") {
+				continue
+			}
+
+			for _, chat := range s.chats(ctx, update.Article, data.Channel) {
+				s.send(model.Message{
+					ChatID: chat.TelegramID,
+					View:   model.ViewArticleUpdated,
+					Data:   update,
+					Delay:  true,
+				}, data.Channel)
+			}
 		}
 	}
 }
 
+func (s *Subscriber) chats(ctx context.Context, article model.Article, channel string) []*entity.Chat {
+	site, err := s.siteRepo.FindByID(ctx, article.SiteID)
+	if err != nil {
+		err = fmt.Errorf("%s error: %w", OpFindSite, err)
+		s.logger.Error("error due to find site", "err", err, "channel", channel, "article", article.ID, "site", article.SiteID)
+		return nil
+	}
+
+	if !*site.Enabled {
+		err = fmt.Errorf("%s site not found", OpFindSite)
+		s.logger.Debug("error due to find site", "err", err, "channel", channel, "article", article.ID, "site", article.SiteID)
+		return nil
+	}
+
+	chats, err := s.chatRepo.Find(ctx, db.BuildCriteria(fmt.Sprintf(chatQuery, article.SiteID)))
+	if err != nil {
+		err = fmt.Errorf("%s error: %w", OpFindChats, err)
+		s.logger.Error("error due to find chats", "err", err, "channel", channel, "article", article.ID, "site", article.SiteID)
+		return nil
+	}
+
+	if len(chats) == 0 {
+		err = fmt.Errorf("%s chats not found", OpFindChats)
+		s.logger.Debug("error due to find chats", "err", err, "channel", channel, "article", article.ID, "site", article.SiteID)
+	}
+
+	return chats
+}
+
 func (s *Subscriber) send(message model.Message, channel string) {
 	chunks, err := chattableList(message, view, s.bot.OwnerID())
 	if err != nil {
@@ -31,6 +31,19 @@ func Save(ctx context.Context, c *mongo.Collection, filter any, update any, opts
 	}
 }
 
+func Update(ctx context.Context, c *mongo.Collection, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	if result, err := c.UpdateOne(ctx, filter, update, opts...); err != nil {
+		return nil, fmt.Errorf(ErrMsgQuery, err)
+	} else if result.MatchedCount == 0 {
+		return nil, fmt.Errorf(ErrMsgQuery, mongo.ErrNoDocuments)
+	} else {
+		return result, nil
+	}
+}
+
 func SaveMany(ctx context.Context, c *mongo.Collection, filter any, update any, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
 	ctx, cancel := context.WithTimeout(ctx, Timeout)
 	defer cancel()
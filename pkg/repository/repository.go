@@ -22,6 +22,7 @@ const (
 	OpFindByID = "repository: find by ID ->"
 	OpCount    = "repository: count ->"
 	OpSave     = "repository: save ->"
+	OpUpdate   = "repository: update ->"
 	OpRemove   = "repository: remove ->"
 	OpIndexes  = "repository: indexes ->"
 
@@ -38,6 +39,8 @@ type ReadRepository[T Entity] interface {
 
 type WriteRepository[T Entity] interface {
 	Save(ctx context.Context, entity T) error
+	// Update sets the fields of the existing entity, unlike Save it never inserts one.
+	Update(ctx context.Context, entity T) error
 	Remove(ctx context.Context, id uuid.UUID) error
 }
 
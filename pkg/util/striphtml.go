@@ -12,8 +12,9 @@ const newLine = rune('\n')
 const space = rune(' ')
 
 var (
-	p  *bluemonday.Policy
-	mu sync.Mutex
+	p   *bluemonday.Policy
+	ugc *bluemonday.Policy
+	mu  sync.Mutex
 )
 
 func init() {
@@ -21,6 +22,7 @@ func init() {
 	defer mu.Unlock()
 
 	p = bluemonday.StrictPolicy()
+	ugc = bluemonday.UGCPolicy()
 }
 
 func StripNewLine(s string, maxNewLine int) string {
@@ -63,3 +65,10 @@ func StripHTMLTags(s string) string {
 
 	return strings.TrimSpace(s)
 }
+
+func SanitizeHTML(s string) string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return strings.TrimSpace(ugc.Sanitize(s))
+}